@@ -41,6 +41,13 @@ type Streamer interface {
 	OverheadSize() int
 }
 
+// HeadFilter filters heads received by a Streamer
+type HeadFilter interface {
+	// Accept returns false when given head should be rejected, for
+	// example when it has been received before
+	Accept(head []byte) bool
+}
+
 // Stream interface represents an object can be use to wrap and unwrap
 // data
 type Stream interface {
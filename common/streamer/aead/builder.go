@@ -0,0 +1,164 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package aead
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+
+	"github.com/nickrio/coward/common/codec"
+	"github.com/nickrio/coward/common/types"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Cipher creates a cipher.AEAD with given key
+type Cipher func(key []byte) (cipher.AEAD, error)
+
+// builder will build an AEAD crypter
+type builder struct {
+	key      []byte
+	cipher   Cipher
+	overhead int
+}
+
+// New creates a new AEAD chiper
+func New(key []byte, c Cipher) (codec.Streamer, error) {
+	// Build a cipher with the raw key to find out whether or not
+	// the key is acceptable by the cipher, and also how many bytes
+	// will be added by it
+	testCipher, testCipherErr := c(key)
+
+	if testCipherErr != nil {
+		return nil, testCipherErr
+	}
+
+	return &builder{
+		key:      key,
+		cipher:   c,
+		overhead: testCipher.Overhead(),
+	}, nil
+}
+
+// NewAESGCM creates a new AES-GCM chiper. AES-128-GCM or
+// AES-256-GCM will be selected according to the length of the key
+func NewAESGCM(key []byte) (codec.Streamer, error) {
+	return New(key, func(k []byte) (cipher.AEAD, error) {
+		blockCipher, blockCipherErr := aes.NewCipher(k)
+
+		if blockCipherErr != nil {
+			return nil, blockCipherErr
+		}
+
+		return cipher.NewGCM(blockCipher)
+	})
+}
+
+// NewChaCha20Poly1305 creates a new ChaCha20-Poly1305 chiper
+func NewChaCha20Poly1305(key []byte) (codec.Streamer, error) {
+	return New(key, chacha20poly1305.New)
+}
+
+// OverheadSize returns the maximum size of overhead
+// if this driver
+func (c *builder) OverheadSize() int {
+	return MetaSize + c.overhead + c.overhead + PaddingMaxSize
+}
+
+// sessionCipher derives a session key from the salt and build
+// a cipher with it
+func (c *builder) sessionCipher(salt []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, c.key)
+
+	_, wErr := mac.Write(salt)
+
+	if wErr != nil {
+		return nil, wErr
+	}
+
+	sessionKey := mac.Sum(nil)
+
+	if len(sessionKey) < len(c.key) {
+		return nil, ErrKeyTooShort
+	}
+
+	return c.cipher(sessionKey[:len(c.key)])
+}
+
+// crypter creates a new crypter with given head
+func (c *builder) crypter(head [HeadSize]byte) (*crypter, error) {
+	random, randomErr := types.NewRandom(32)
+
+	if randomErr != nil {
+		return nil, randomErr
+	}
+
+	aead, aeadErr := c.sessionCipher(head[:])
+
+	if aeadErr != nil {
+		return nil, aeadErr
+	}
+
+	return &crypter{
+		head:     head,
+		aead:     aead,
+		overhead: c.overhead,
+		nonce:    [NonceSize]byte{},
+		random:   random,
+		meta:     [MetaSize]byte{},
+		sealed:   make([]byte, 0, codec.BufferSize+c.overhead),
+		data:     [codec.BufferSize]byte{},
+	}, nil
+}
+
+// New creates an empty crypter
+func (c *builder) New() (codec.Stream, error) {
+	head := [HeadSize]byte{}
+
+	rLen, rErr := rand.Read(head[:])
+
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	if rLen != HeadSize {
+		return nil, ErrFailedFullyReadHead
+	}
+
+	return c.crypter(head)
+}
+
+// Init initials a crypter from reader data
+func (c *builder) Init(reader io.Reader) (codec.Stream, error) {
+	head := [HeadSize]byte{}
+
+	_, rErr := io.ReadFull(reader, head[:])
+
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	return c.crypter(head)
+}
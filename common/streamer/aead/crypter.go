@@ -0,0 +1,243 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package aead
+
+import (
+	"crypto/cipher"
+	"io"
+
+	"github.com/nickrio/coward/common/codec"
+	"github.com/nickrio/coward/common/types"
+)
+
+// crypter seals and opens data according to the setting
+type crypter struct {
+	head     [HeadSize]byte
+	aead     cipher.AEAD
+	overhead int
+	nonce    [NonceSize]byte
+	random   types.Random
+	meta     [MetaSize]byte
+	sealed   []byte
+	data     [codec.BufferSize]byte
+}
+
+// nextNonce increases the nonce counter by one so every segment
+// will be sealed with a different nonce
+func (c *crypter) nextNonce() {
+	for idx := range c.nonce {
+		c.nonce[idx]++
+
+		if c.nonce[idx] != 0 {
+			return
+		}
+	}
+}
+
+// seal seals the input and write result to the writer
+func (c *crypter) seal(input []byte, w io.Writer, failErr error) error {
+	c.sealed = c.aead.Seal(c.sealed[:0], c.nonce[:], input, nil)
+
+	c.nextNonce()
+
+	wLen, wErr := w.Write(c.sealed)
+
+	if wErr != nil {
+		return wErr
+	}
+
+	if wLen != len(c.sealed) {
+		return failErr
+	}
+
+	return nil
+}
+
+// open reads sealed data from the reader and opens it
+func (c *crypter) open(
+	size int,
+	r io.Reader,
+	failErr error,
+	invalidErr error,
+) ([]byte, error) {
+	sealedSize := size + c.overhead
+
+	if sealedSize > len(c.data) {
+		return nil, ErrSizeTooLarge
+	}
+
+	rLen, rErr := io.ReadFull(r, c.data[:sealedSize])
+
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	if rLen != sealedSize {
+		return nil, failErr
+	}
+
+	opened, openErr := c.aead.Open(
+		c.data[:0], c.nonce[:], c.data[:sealedSize], nil)
+
+	if openErr != nil {
+		return nil, invalidErr
+	}
+
+	c.nextNonce()
+
+	return opened, nil
+}
+
+// Head build the head of this codec driver
+func (c *crypter) Head(writer io.Writer) error {
+	wLen, wErr := writer.Write(c.head[:])
+
+	if wErr != nil {
+		return wErr
+	}
+
+	if wLen != HeadSize {
+		return ErrFailedFullyWriteHead
+	}
+
+	return nil
+}
+
+// Stream seals the data and write result to the writer
+func (c *crypter) Stream(input []byte, w io.Writer) error {
+	// Segment format (Before seal):
+	//
+	// +------+---------+   +------+---------+
+	// | SIZE | PADSIZE |   | DATA | PADDING |
+	// +------+---------+   +------+---------+
+	// |  2   |    1    |   | SIZE | PADSIZE |
+	// +------+---------+   +------+---------+
+	//
+	// Both parts are sealed separately, so the size and the
+	// padding will be authenticated as well as the data
+	inputLen := len(input)
+
+	if inputLen <= 0 {
+		return ErrSizeTooSmall
+	}
+
+	paddingLen, paddingErr := c.random.GetMax(PaddingMaxSize)
+
+	if paddingErr != nil {
+		return paddingErr
+	}
+
+	if inputLen+int(paddingLen) > len(c.data) {
+		return ErrSizeTooLarge
+	}
+
+	// Meta
+	encodeableSize := types.EncodableUint16(inputLen)
+
+	sizeEncodeErr := encodeableSize.EncodeBytes(c.meta[:SizeSize])
+
+	if sizeEncodeErr != nil {
+		return sizeEncodeErr
+	}
+
+	c.meta[SizeSize] = paddingLen
+
+	metaErr := c.seal(c.meta[:], w, ErrFailedFullyWriteMeta)
+
+	if metaErr != nil {
+		return metaErr
+	}
+
+	// Data + Padding. Padding is all zero, but will become random
+	// looking after it get sealed
+	copy(c.data[:inputLen], input)
+
+	for idx := inputLen; idx < inputLen+int(paddingLen); idx++ {
+		c.data[idx] = 0
+	}
+
+	return c.seal(
+		c.data[:inputLen+int(paddingLen)], w, ErrFailedFullyWriteData)
+}
+
+// Encode writes sealed data to the writer. The data has already been
+// sealed by Stream, so nothing will be changed here
+func (c *crypter) Encode(input []byte, w io.Writer) error {
+	wLen, wErr := w.Write(input)
+
+	if wErr != nil {
+		return wErr
+	}
+
+	if wLen != len(input) {
+		return ErrFailedFullyWriteData
+	}
+
+	return nil
+}
+
+// Decode returns the input as is, opening will be done by Unstream
+func (c *crypter) Decode(input []byte) ([]byte, error) {
+	return input, nil
+}
+
+// Unstream opens sealed data segment and return the meaningful data
+func (c *crypter) Unstream(input io.Reader) (output []byte, err error) {
+	// Meta
+	meta, metaErr := c.open(
+		MetaSize, input, ErrFailedFullyReadMeta, ErrInvalidMetaReaded)
+
+	if metaErr != nil {
+		return nil, metaErr
+	}
+
+	size := types.EncodableUint16(0)
+
+	sizeDecodeErr := size.DecodeBytes(meta[:SizeSize])
+
+	if sizeDecodeErr != nil {
+		return nil, sizeDecodeErr
+	}
+
+	paddingLen := int(meta[SizeSize])
+
+	if size <= 0 {
+		return nil, ErrSizeTooSmall
+	}
+
+	if paddingLen >= PaddingMaxSize {
+		return nil, ErrSizeTooLarge
+	}
+
+	// Data + Padding
+	data, dataErr := c.open(
+		int(size)+paddingLen,
+		input,
+		ErrFailedFullyReadData,
+		ErrInvalidDataReaded,
+	)
+
+	if dataErr != nil {
+		return nil, dataErr
+	}
+
+	return data[:size], nil
+}
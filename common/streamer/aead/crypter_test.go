@@ -0,0 +1,178 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package aead
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/nickrio/coward/common/codec"
+)
+
+func testMustGetStreams(key []byte) (codec.Stream, codec.Stream, *bytes.Buffer) {
+	b, bErr := NewAESGCM(key)
+
+	if bErr != nil {
+		panic(bErr)
+	}
+
+	c, cErr := b.New()
+
+	if cErr != nil {
+		panic(cErr)
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, 4096))
+
+	headErr := c.Head(buf)
+
+	if headErr != nil {
+		panic(headErr)
+	}
+
+	d, dErr := b.Init(bytes.NewBuffer(buf.Next(HeadSize)))
+
+	if dErr != nil {
+		panic(dErr)
+	}
+
+	return c, d, buf
+}
+
+func TestCrypterStreamUnstream(t *testing.T) {
+	c, d, buf := testMustGetStreams([]byte("0123456789ABCDEF"))
+	testData := make([]byte, 128)
+
+	_, randErr := rand.Read(testData)
+
+	if randErr != nil {
+		t.Error("Failed to generate random seed for this test")
+
+		return
+	}
+
+	for i := 0; i < 3; i++ {
+		encodeErr := c.Stream(testData, buf)
+
+		if encodeErr != nil {
+			t.Error("Can't encode due to error:", encodeErr)
+
+			return
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		decoded, decodeErr := d.Unstream(buf)
+
+		if decodeErr != nil {
+			t.Error("Can't decode due to error:", decodeErr)
+
+			return
+		}
+
+		if !bytes.Equal(decoded, testData) {
+			t.Errorf("Can't encode or decode data.\r\nExpecting %v\r\nGot       %v",
+				testData, decoded)
+
+			return
+		}
+	}
+}
+
+func TestCrypterTamperedMeta(t *testing.T) {
+	c, d, buf := testMustGetStreams([]byte("0123456789ABCDEF"))
+
+	encodeErr := c.Stream([]byte("Hello World"), buf)
+
+	if encodeErr != nil {
+		t.Error("Can't encode due to error:", encodeErr)
+
+		return
+	}
+
+	// Flip one bit of the sealed size
+	buf.Bytes()[0] ^= 1
+
+	_, decodeErr := d.Unstream(buf)
+
+	if decodeErr != ErrInvalidMetaReaded {
+		t.Errorf("Expecting error %s, got %s",
+			ErrInvalidMetaReaded, decodeErr)
+
+		return
+	}
+}
+
+func TestCrypterTamperedData(t *testing.T) {
+	c, d, buf := testMustGetStreams([]byte("0123456789ABCDEF"))
+
+	encodeErr := c.Stream([]byte("Hello World"), buf)
+
+	if encodeErr != nil {
+		t.Error("Can't encode due to error:", encodeErr)
+
+		return
+	}
+
+	// Flip one bit of the last byte, which belongs to the sealed data
+	buf.Bytes()[buf.Len()-1] ^= 1
+
+	_, decodeErr := d.Unstream(buf)
+
+	if decodeErr != ErrInvalidDataReaded {
+		t.Errorf("Expecting error %s, got %s",
+			ErrInvalidDataReaded, decodeErr)
+
+		return
+	}
+}
+
+func TestCrypterReordered(t *testing.T) {
+	c, d, buf := testMustGetStreams([]byte("0123456789ABCDEF"))
+	first := bytes.NewBuffer(make([]byte, 0, 4096))
+
+	encodeErr := c.Stream([]byte("First"), first)
+
+	if encodeErr != nil {
+		t.Error("Can't encode due to error:", encodeErr)
+
+		return
+	}
+
+	encodeErr = c.Stream([]byte("Second"), buf)
+
+	if encodeErr != nil {
+		t.Error("Can't encode due to error:", encodeErr)
+
+		return
+	}
+
+	// Read the second segment first
+	_, decodeErr := d.Unstream(buf)
+
+	if decodeErr != ErrInvalidMetaReaded {
+		t.Errorf("Expecting error %s, got %s",
+			ErrInvalidMetaReaded, decodeErr)
+
+		return
+	}
+}
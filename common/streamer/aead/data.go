@@ -0,0 +1,98 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package aead
+
+import "github.com/nickrio/coward/common/codec"
+
+const (
+	// HeadSize is the byte length of head data (the salt which will be
+	// used to derive the session key)
+	HeadSize = 16
+
+	// NonceSize is the byte length of the AEAD nonce
+	NonceSize = 12
+
+	// PaddingMaxSize is maximum padding size: Padding length
+	// cannot larger than this
+	PaddingMaxSize = 32
+
+	// SizeSize is the byte length of the data size (an uint16 data)
+	SizeSize = 2
+
+	// MetaSize is the byte length of meta data (Data size + Padding
+	// size in this case)
+	MetaSize = SizeSize + 1
+)
+
+var (
+	// ErrFailedFullyReadHead is throwed when Head is not fully
+	// readed
+	ErrFailedFullyReadHead = codec.Fail(
+		"Failed to fully read head")
+
+	// ErrFailedFullyWriteHead is throwed when Head is not fully
+	// written
+	ErrFailedFullyWriteHead = codec.Fail(
+		"Failed to fully write head")
+
+	// ErrFailedFullyReadMeta is throwed when Meta is not fully
+	// readed
+	ErrFailedFullyReadMeta = codec.Fail(
+		"Failed to fully read meta")
+
+	// ErrFailedFullyWriteMeta is throwed when Meta is not fully
+	// written
+	ErrFailedFullyWriteMeta = codec.Fail(
+		"Failed to fully write meta")
+
+	// ErrFailedFullyReadData is throwed when Data is not fully
+	// readed
+	ErrFailedFullyReadData = codec.Fail(
+		"Failed to fully read data")
+
+	// ErrFailedFullyWriteData is throwed when Data is not fully
+	// written
+	ErrFailedFullyWriteData = codec.Fail(
+		"Failed to fully write data")
+
+	// ErrInvalidMetaReaded is throwed when readed meta data can't
+	// pass the authentication
+	ErrInvalidMetaReaded = codec.Fail(
+		"Invalid meta readed")
+
+	// ErrInvalidDataReaded is throwed when readed data can't pass
+	// the authentication
+	ErrInvalidDataReaded = codec.Fail(
+		"Invalid data readed")
+
+	// ErrSizeTooLarge is throwed when Size is abnormal
+	ErrSizeTooLarge = codec.Fail(
+		"Data length is excceed the limit")
+
+	// ErrSizeTooSmall is throwed when Size is 0
+	ErrSizeTooSmall = codec.Fail(
+		"Data length is too small")
+
+	// ErrKeyTooShort is throwed when given key is too short to derive
+	// a session key
+	ErrKeyTooShort = codec.Fail(
+		"Key is too short")
+)
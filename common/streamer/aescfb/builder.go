@@ -38,11 +38,19 @@ type builder struct {
 	readRand    types.Random
 	writeRand   types.Random
 	blockCipher cipher.Block
+	filter      codec.HeadFilter
 	head        [HeadSize]byte
 }
 
 // New creates a new AES-CFB chiper
 func New(key []byte) (codec.Streamer, error) {
+	return NewFiltered(key, nil)
+}
+
+// NewFiltered creates a new AES-CFB chiper which will reject
+// incoming streams when their head is not accepted by the filter
+func NewFiltered(
+	key []byte, filter codec.HeadFilter) (codec.Streamer, error) {
 	readRandom, readRandomErr := types.NewRandom(32)
 
 	if readRandomErr != nil {
@@ -66,6 +74,7 @@ func New(key []byte) (codec.Streamer, error) {
 		readRand:    readRandom,
 		writeRand:   writeRandom,
 		blockCipher: blockCipher,
+		filter:      filter,
 		head:        [HeadSize]byte{},
 	}, nil
 }
@@ -117,6 +126,10 @@ func (c *builder) Init(reader io.Reader) (codec.Stream, error) {
 		return nil, ErrFailedFullyWriteHead
 	}
 
+	if c.filter != nil && !c.filter.Accept(c.head[:rLen]) {
+		return nil, ErrReplayedHead
+	}
+
 	return &crypter{
 		head:    c.head,
 		decoder: cipher.NewCFBDecrypter(c.blockCipher, c.head[:rLen]),
@@ -23,6 +23,7 @@ package aescfb
 import (
 	"crypto/cipher"
 	"crypto/hmac"
+	"encoding/binary"
 	"hash"
 	"io"
 
@@ -39,6 +40,8 @@ type crypter struct {
 	padding     padding
 	readMac     hash.Hash
 	writeMac    hash.Hash
+	readCount   uint64
+	writeCount  uint64
 	data        [codec.BufferSize]byte
 	sizeDeBytes [SizeSize]byte
 	sizeEnBytes [SizeSize]byte
@@ -67,6 +70,45 @@ func (c *crypter) hmac(mac hash.Hash, message []byte) ([]byte, error) {
 	return hmac[:MetaSize], nil
 }
 
+// sign calecute the HMAC of a data segment. The segment counter and
+// the size bytes will be written to the summer before the data, so
+// a segment that been replayed, dropped or reordered will not pass
+// the check
+func (c *crypter) sign(
+	mac hash.Hash,
+	counter *uint64,
+	size []byte,
+	message []byte,
+) ([]byte, error) {
+	counterBytes := [CounterSize]byte{}
+
+	binary.BigEndian.PutUint64(counterBytes[:], *counter)
+
+	*counter++
+
+	wLen, wErr := mac.Write(counterBytes[:])
+
+	if wErr != nil {
+		return nil, wErr
+	}
+
+	if wLen != CounterSize {
+		return nil, ErrFailedToWriteCounterToSumer
+	}
+
+	wLen, wErr = mac.Write(size)
+
+	if wErr != nil {
+		return nil, wErr
+	}
+
+	if wLen != len(size) {
+		return nil, ErrFailedToWriteHMACToSumer
+	}
+
+	return c.hmac(mac, message)
+}
+
 // hmacEqual check if two HMAC is equal
 func (c *crypter) hmacEqual(hmac1 []byte, hmac2 []byte) bool {
 	return hmac.Equal(hmac1, hmac2)
@@ -118,7 +160,8 @@ func (c *crypter) Stream(input []byte, w io.Writer) error {
 
 	// Meta,
 	// We use meta for Hash check
-	hmac, hmacErr := c.hmac(c.writeMac, input)
+	hmac, hmacErr := c.sign(
+		c.writeMac, &c.writeCount, c.sizeEnBytes[:], input)
 
 	if hmacErr != nil {
 		return hmacErr
@@ -245,7 +288,8 @@ func (c *crypter) Unstream(input io.Reader) (output []byte, err error) {
 	}
 
 	// Check data
-	dataHMAC, dataHMACErr := c.hmac(c.readMac, c.data[:size])
+	dataHMAC, dataHMACErr := c.sign(
+		c.readMac, &c.readCount, c.sizeDeBytes[:], c.data[:size])
 
 	if dataHMACErr != nil {
 		return nil, dataHMACErr
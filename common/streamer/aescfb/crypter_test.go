@@ -342,6 +342,95 @@ func TestCrypterStreamUnstream(t *testing.T) {
 	}
 }
 
+func TestCrypterReordered(t *testing.T) {
+	c := testMustGetCrypter([]byte("HMAC16BYTELONGKI"))
+	first := bytes.NewBuffer(make([]byte, 0, 4096))
+	second := bytes.NewBuffer(make([]byte, 0, 4096))
+
+	cErr := c.Stream([]byte("First segment"), first)
+
+	if cErr != nil {
+		t.Error("Can't wrap due to error:", cErr)
+
+		return
+	}
+
+	cErr = c.Stream([]byte("Second segment"), second)
+
+	if cErr != nil {
+		t.Error("Can't wrap due to error:", cErr)
+
+		return
+	}
+
+	_, parseErr := c.Unstream(second)
+
+	if parseErr != ErrInvalidDataReaded {
+		t.Errorf("Expecting error %s, got %s",
+			ErrInvalidDataReaded, parseErr)
+
+		return
+	}
+}
+
+type testHeadFilter map[string]bool
+
+func (f testHeadFilter) Accept(head []byte) bool {
+	if f[string(head)] {
+		return false
+	}
+
+	f[string(head)] = true
+
+	return true
+}
+
+func TestCrypterReplayedHead(t *testing.T) {
+	b, bErr := NewFiltered([]byte("0123456789ABCDEF"), testHeadFilter{})
+
+	if bErr != nil {
+		t.Error("Can't create AES-CFB driver for the test due to error:", bErr)
+
+		return
+	}
+
+	c, cErr := b.New()
+
+	if cErr != nil {
+		t.Error("Can't create AES-CFB chiper due to error:", cErr)
+
+		return
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, 4096))
+
+	headErr := c.Head(buf)
+
+	if headErr != nil {
+		t.Error("Can't save head due to error:", headErr)
+
+		return
+	}
+
+	head := buf.Bytes()
+
+	_, initErr := b.Init(bytes.NewBuffer(head))
+
+	if initErr != nil {
+		t.Error("Can't init AES-CFB chiper due to error:", initErr)
+
+		return
+	}
+
+	_, initErr = b.Init(bytes.NewBuffer(head))
+
+	if initErr != ErrReplayedHead {
+		t.Errorf("Expecting error %s, got %v", ErrReplayedHead, initErr)
+
+		return
+	}
+}
+
 func BenchmarkCrypterWrapParse(b *testing.B) {
 	c := testMustGetCrypter([]byte("HMAC16BYTELONGKI"))
 	buf := bytes.NewBuffer(make([]byte, 0, 4096))
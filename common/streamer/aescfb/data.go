@@ -35,6 +35,10 @@ const (
 
 	// SizeSize is the byte length of the data size (an uint16 data)
 	SizeSize = 2
+
+	// CounterSize is the byte length of the segment counter (an
+	// uint64 data)
+	CounterSize = 8
 )
 
 var (
@@ -118,4 +122,14 @@ var (
 	// ErrFailedHMACTooShort is throwed when HMAC bytes is too short
 	ErrFailedHMACTooShort = codec.Fail(
 		"HMAC is too short")
+
+	// ErrFailedToWriteCounterToSumer is throwed when segment counter
+	// is not fully written to summer
+	ErrFailedToWriteCounterToSumer = codec.Fail(
+		"Failed to write segment counter to summer")
+
+	// ErrReplayedHead is throwed when the readed head has been
+	// received before
+	ErrReplayedHead = codec.Fail(
+		"Replayed head")
 )
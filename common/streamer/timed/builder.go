@@ -0,0 +1,353 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package timed
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/nickrio/coward/common/codec"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Labels used to separate HMAC results which been calculated with the
+// same key
+var (
+	maskLabel = []byte{'M'}
+	tagLabel  = []byte{'T'}
+)
+
+// Builder builds the underlaying Streamer with the derived key
+type Builder func(key []byte) (codec.Streamer, error)
+
+// Streamer is a timed codec.Streamer
+type Streamer interface {
+	codec.Streamer
+
+	// Selected returns the index of the key which the remote is using.
+	// It's only known after a head has been received
+	Selected() (int, bool)
+}
+
+// builder builds timed streams.
+//
+// Every stream will begin with a head which contains a random salt and
+// the timestamp of the sender. The session key of underlaying Streamer
+// is derived from the pre-shared key, the salt and the timestamp with
+// HKDF, so both side will get the same key as long as their clock is
+// no further than the tolerance apart.
+//
+// Head format:
+//
+// +------+-----------+-----+
+// | SALT | TIMESTAMP | TAG |
+// +------+-----------+-----+
+// |  16  |     8     | 16  |
+// +------+-----------+-----+
+//
+// TIMESTAMP is masked, and TAG authenticates both SALT and TIMESTAMP.
+//
+// When multiple keys are given, received heads will be verified with
+// each of them, and the key which the head is tagged with will be
+// selected. Heads written before a key is selected are tagged with
+// the first key
+type builder struct {
+	keys      [][]byte
+	selected  int
+	lock      sync.Mutex
+	keySize   int
+	tolerance time.Duration
+	filter    codec.HeadFilter
+	build     Builder
+	overhead  int
+}
+
+// stream is the write side of timed stream
+type stream struct {
+	head   [HeadSize]byte
+	stream codec.Stream
+}
+
+// New creates a new timed Streamer which accepts any of given keys.
+// Heads received by the Streamer will be checked by the filter if
+// it's not nil
+func New(
+	keys [][]byte,
+	keySize int,
+	tolerance time.Duration,
+	filter codec.HeadFilter,
+	build Builder,
+) (Streamer, error) {
+	if len(keys) <= 0 {
+		return nil, ErrKeyTooShort
+	}
+
+	for _, key := range keys {
+		if len(key) <= 0 {
+			return nil, ErrKeyTooShort
+		}
+	}
+
+	// Build a Streamer to find out it's overhead size
+	testStreamer, testStreamerErr := build(make([]byte, keySize))
+
+	if testStreamerErr != nil {
+		return nil, testStreamerErr
+	}
+
+	return &builder{
+		keys:      keys,
+		selected:  -1,
+		lock:      sync.Mutex{},
+		keySize:   keySize,
+		tolerance: tolerance,
+		filter:    filter,
+		build:     build,
+		overhead:  testStreamer.OverheadSize(),
+	}, nil
+}
+
+// hmac calculates HMAC of given messages
+func hmacSum(key []byte, messages ...[]byte) []byte {
+	mac := hmac.New(sha256.New, key)
+
+	for _, message := range messages {
+		// hash.Hash never returns an error
+		mac.Write(message)
+	}
+
+	return mac.Sum(nil)
+}
+
+// mask masks or unmasks the timestamp
+func mask(key []byte, salt []byte, timestamp []byte) {
+	mask := hmacSum(key, maskLabel, salt)
+
+	for idx := range timestamp {
+		timestamp[idx] ^= mask[idx]
+	}
+}
+
+// tag calculates the authentication tag of the head
+func tag(key []byte, salt []byte, timestamp []byte) []byte {
+	return hmacSum(key, tagLabel, salt, timestamp)[:TagSize]
+}
+
+// key returns the key which will be used to write heads
+func (b *builder) key() []byte {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.selected < 0 {
+		return b.keys[0]
+	}
+
+	return b.keys[b.selected]
+}
+
+// selectKey finds out which key the head is tagged with, and unmasks
+// the timestamp with it
+func (b *builder) selectKey(head *[HeadSize]byte) (int, error) {
+	salt := head[:SaltSize]
+	timestamp := [TimeSize]byte{}
+
+	for idx, key := range b.keys {
+		copy(timestamp[:], head[SaltSize:SaltSize+TimeSize])
+
+		mask(key, salt, timestamp[:])
+
+		if !hmac.Equal(
+			tag(key, salt, timestamp[:]), head[SaltSize+TimeSize:]) {
+			continue
+		}
+
+		copy(head[SaltSize:SaltSize+TimeSize], timestamp[:])
+
+		return idx, nil
+	}
+
+	return -1, ErrInvalidHead
+}
+
+// streamer derives the session key and build the underlaying
+// Streamer with it
+func (b *builder) streamer(
+	key []byte, salt []byte, timestamp []byte) (codec.Streamer, error) {
+	sessionKey := make([]byte, b.keySize)
+
+	_, rErr := io.ReadFull(
+		hkdf.New(sha256.New, key, salt, timestamp), sessionKey)
+
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	return b.build(sessionKey)
+}
+
+// Selected returns the index of the key which the remote is using
+func (b *builder) Selected() (int, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.selected, b.selected >= 0
+}
+
+// OverheadSize returns the maximum size of overhead
+// if this driver
+func (b *builder) OverheadSize() int {
+	return b.overhead
+}
+
+// New creates a new stream
+func (b *builder) New() (codec.Stream, error) {
+	s := &stream{
+		head: [HeadSize]byte{},
+	}
+
+	salt := s.head[:SaltSize]
+	timestamp := s.head[SaltSize : SaltSize+TimeSize]
+
+	rLen, rErr := rand.Read(salt)
+
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	if rLen != SaltSize {
+		return nil, ErrFailedFullyWriteHead
+	}
+
+	binary.BigEndian.PutUint64(timestamp, uint64(time.Now().Unix()))
+
+	key := b.key()
+
+	streamer, streamerErr := b.streamer(key, salt, timestamp)
+
+	if streamerErr != nil {
+		return nil, streamerErr
+	}
+
+	copy(s.head[SaltSize+TimeSize:], tag(key, salt, timestamp))
+
+	mask(key, salt, timestamp)
+
+	stm, stmErr := streamer.New()
+
+	if stmErr != nil {
+		return nil, stmErr
+	}
+
+	s.stream = stm
+
+	return s, nil
+}
+
+// Init initials a stream from reader data
+func (b *builder) Init(reader io.Reader) (codec.Stream, error) {
+	head := [HeadSize]byte{}
+
+	rLen, rErr := io.ReadFull(reader, head[:])
+
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	if rLen != HeadSize {
+		return nil, ErrFailedFullyReadHead
+	}
+
+	selected, selectErr := b.selectKey(&head)
+
+	if selectErr != nil {
+		return nil, selectErr
+	}
+
+	salt := head[:SaltSize]
+	timestamp := head[SaltSize : SaltSize+TimeSize]
+
+	// Head is authenticated, so if the time is not right, it must be
+	// the clock
+	skew := time.Now().Sub(
+		time.Unix(int64(binary.BigEndian.Uint64(timestamp)), 0))
+
+	if skew > b.tolerance || skew < -b.tolerance {
+		return nil, ErrClockSkew
+	}
+
+	if b.filter != nil && !b.filter.Accept(salt) {
+		return nil, ErrReplayedHead
+	}
+
+	streamer, streamerErr := b.streamer(b.keys[selected], salt, timestamp)
+
+	if streamerErr != nil {
+		return nil, streamerErr
+	}
+
+	b.lock.Lock()
+	b.selected = selected
+	b.lock.Unlock()
+
+	return streamer.Init(reader)
+}
+
+// Head writes the head of timed stream and then the head of the
+// underlaying stream
+func (s *stream) Head(writer io.Writer) error {
+	wLen, wErr := writer.Write(s.head[:])
+
+	if wErr != nil {
+		return wErr
+	}
+
+	if wLen != HeadSize {
+		return ErrFailedFullyWriteHead
+	}
+
+	return s.stream.Head(writer)
+}
+
+// Stream wraps the data with underlaying stream
+func (s *stream) Stream(input []byte, w io.Writer) error {
+	return s.stream.Stream(input, w)
+}
+
+// Encode encodes the data with underlaying stream
+func (s *stream) Encode(input []byte, w io.Writer) error {
+	return s.stream.Encode(input, w)
+}
+
+// Decode decodes the data with underlaying stream
+func (s *stream) Decode(input []byte) ([]byte, error) {
+	return s.stream.Decode(input)
+}
+
+// Unstream parses the data with underlaying stream
+func (s *stream) Unstream(r io.Reader) ([]byte, error) {
+	return s.stream.Unstream(r)
+}
@@ -0,0 +1,239 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package timed
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nickrio/coward/common/codec"
+	"github.com/nickrio/coward/common/streamer/aead"
+	"github.com/nickrio/coward/common/streamer/aescfb"
+)
+
+type testHeadFilter map[string]bool
+
+func (f testHeadFilter) Accept(head []byte) bool {
+	if f[string(head)] {
+		return false
+	}
+
+	f[string(head)] = true
+
+	return true
+}
+
+func testMustGetStreamer(
+	key string, filter codec.HeadFilter) codec.Streamer {
+	s, sErr := New(
+		[][]byte{[]byte(key)}, 16, 10*time.Second, filter, aead.NewAESGCM)
+
+	if sErr != nil {
+		panic(sErr)
+	}
+
+	return s
+}
+
+func testMustWriteHead(s codec.Streamer) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, 4096))
+
+	stream, streamErr := s.New()
+
+	if streamErr != nil {
+		panic(streamErr)
+	}
+
+	headErr := stream.Head(buf)
+
+	if headErr != nil {
+		panic(headErr)
+	}
+
+	return buf.Bytes()
+}
+
+func TestBuilderReadWrite(t *testing.T) {
+	for _, build := range []Builder{aescfb.New, aead.NewAESGCM} {
+		buf := bytes.NewBuffer(make([]byte, 0, 4096))
+		cipher, cipherErr := New([][]byte{[]byte("1234567890123456")},
+			16, 10*time.Second, nil, build)
+		testData := [][]byte{
+			[]byte("Test data is here"),
+			[]byte("Test data is here again"),
+		}
+
+		if cipherErr != nil {
+			t.Error("Cipher creation failed:", cipherErr)
+
+			return
+		}
+
+		reader := codec.NewReader(cipher, buf)
+		writer := codec.NewWriter(cipher, buf)
+
+		for _, data := range testData {
+			_, wErr := writer.Write(data)
+
+			if wErr != nil {
+				t.Error("Writer failed:", wErr)
+
+				return
+			}
+		}
+
+		for _, data := range testData {
+			readBuf := make([]byte, len(data))
+
+			_, rErr := io.ReadFull(reader, readBuf)
+
+			if rErr != nil {
+				t.Error("Can't read due to error:", rErr)
+
+				return
+			}
+
+			if !bytes.Equal(data, readBuf) {
+				t.Errorf("Failed to read expected data, expecting %v, got %v",
+					data, readBuf)
+
+				return
+			}
+		}
+	}
+}
+
+func TestBuilderInvalidKey(t *testing.T) {
+	head := testMustWriteHead(testMustGetStreamer("1234567890123456", nil))
+
+	_, initErr := testMustGetStreamer("6543210987654321", nil).Init(
+		bytes.NewBuffer(head))
+
+	if initErr != ErrInvalidHead {
+		t.Errorf("Expecting error %s, got %v", ErrInvalidHead, initErr)
+
+		return
+	}
+}
+
+func TestBuilderClockSkew(t *testing.T) {
+	s := testMustGetStreamer("1234567890123456", nil)
+	key := []byte("1234567890123456")
+
+	for _, skew := range []time.Duration{-time.Minute, time.Minute} {
+		head := testMustWriteHead(s)
+		salt := head[:SaltSize]
+		timestamp := head[SaltSize : SaltSize+TimeSize]
+
+		// Rebuild the head with a skewed timestamp
+		binary.BigEndian.PutUint64(
+			timestamp, uint64(time.Now().Add(skew).Unix()))
+
+		copy(head[SaltSize+TimeSize:], tag(key, salt, timestamp))
+
+		mask(key, salt, timestamp)
+
+		_, initErr := s.Init(bytes.NewBuffer(head))
+
+		if initErr != ErrClockSkew {
+			t.Errorf("Expecting error %s, got %v", ErrClockSkew, initErr)
+
+			return
+		}
+	}
+}
+
+func TestBuilderSelectKey(t *testing.T) {
+	keys := [][]byte{
+		[]byte("1234567890123456"),
+		[]byte("6543210987654321"),
+	}
+	server, serverErr := New(keys, 16, 10*time.Second, nil, aead.NewAESGCM)
+
+	if serverErr != nil {
+		t.Error("Cipher creation failed:", serverErr)
+
+		return
+	}
+
+	_, selected := server.Selected()
+
+	if selected {
+		t.Error("Expecting no key to be selected before any head received")
+
+		return
+	}
+
+	head := testMustWriteHead(testMustGetStreamer(string(keys[1]), nil))
+
+	_, initErr := server.Init(bytes.NewBuffer(head))
+
+	if initErr != nil {
+		t.Error("Can't init stream due to error:", initErr)
+
+		return
+	}
+
+	keyIndex, selected := server.Selected()
+
+	if !selected || keyIndex != 1 {
+		t.Errorf("Expecting key %d to be selected, got %d", 1, keyIndex)
+
+		return
+	}
+
+	// The reply must be readable by the remote which only has the
+	// selected key
+	reply := testMustWriteHead(server)
+
+	_, initErr = testMustGetStreamer(string(keys[1]), nil).Init(
+		bytes.NewBuffer(reply))
+
+	if initErr != nil {
+		t.Error("Can't init reply stream due to error:", initErr)
+
+		return
+	}
+}
+
+func TestBuilderReplayedHead(t *testing.T) {
+	s := testMustGetStreamer("1234567890123456", testHeadFilter{})
+	head := testMustWriteHead(s)
+
+	_, initErr := s.Init(bytes.NewBuffer(head))
+
+	if initErr != nil {
+		t.Error("Can't init stream due to error:", initErr)
+
+		return
+	}
+
+	_, initErr = s.Init(bytes.NewBuffer(head))
+
+	if initErr != ErrReplayedHead {
+		t.Errorf("Expecting error %s, got %v", ErrReplayedHead, initErr)
+
+		return
+	}
+}
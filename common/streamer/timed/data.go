@@ -0,0 +1,68 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package timed
+
+import "github.com/nickrio/coward/common/codec"
+
+const (
+	// SaltSize is the byte length of the random salt
+	SaltSize = 16
+
+	// TimeSize is the byte length of the timestamp (an uint64 data)
+	TimeSize = 8
+
+	// TagSize is the byte length of the head authentication tag
+	TagSize = 16
+
+	// HeadSize is the byte length of head data
+	HeadSize = SaltSize + TimeSize + TagSize
+)
+
+var (
+	// ErrFailedFullyReadHead is throwed when Head is not fully
+	// readed
+	ErrFailedFullyReadHead = codec.Fail(
+		"Failed to fully read head")
+
+	// ErrFailedFullyWriteHead is throwed when Head is not fully
+	// written
+	ErrFailedFullyWriteHead = codec.Fail(
+		"Failed to fully write head")
+
+	// ErrInvalidHead is throwed when the head can't pass the
+	// authentication, usually caused by a wrong key
+	ErrInvalidHead = codec.Fail(
+		"Invalid head")
+
+	// ErrClockSkew is throwed when the head is authenticated, but the
+	// timestamp inside of it is too far away from local clock
+	ErrClockSkew = codec.Fail(
+		"Clock skew between local and remote is too large")
+
+	// ErrReplayedHead is throwed when the head has been received
+	// before
+	ErrReplayedHead = codec.Fail(
+		"Replayed head")
+
+	// ErrKeyTooShort is throwed when the key is empty
+	ErrKeyTooShort = codec.Fail(
+		"Key is too short")
+)
@@ -0,0 +1,63 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package x25519
+
+import "github.com/nickrio/coward/common/codec"
+
+const (
+	// PublicKeySize is the byte length of a X25519 public key
+	PublicKeySize = 32
+
+	// TimeSize is the byte length of the timestamp (an uint64 data)
+	TimeSize = 8
+
+	// TagSize is the byte length of the hello authentication tag
+	TagSize = 16
+
+	// HelloSize is the byte length of the hello message
+	HelloSize = PublicKeySize + TimeSize + TagSize
+)
+
+var (
+	// ErrFailedFullyWriteHello is throwed when Hello is not fully
+	// written
+	ErrFailedFullyWriteHello = codec.Fail(
+		"Failed to fully write hello")
+
+	// ErrFailedFullyReadHello is throwed when Hello is not fully
+	// readed
+	ErrFailedFullyReadHello = codec.Fail(
+		"Failed to fully read hello")
+
+	// ErrInvalidHello is throwed when the hello can't pass the
+	// authentication, usually caused by a wrong key
+	ErrInvalidHello = codec.Fail(
+		"Invalid hello")
+
+	// ErrInvalidPublicKey is throwed when the public key received
+	// from remote is not acceptable
+	ErrInvalidPublicKey = codec.Fail(
+		"Invalid public key")
+
+	// ErrKeyTooShort is throwed when the key is empty
+	ErrKeyTooShort = codec.Fail(
+		"Key is too short")
+)
@@ -0,0 +1,342 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package x25519
+
+import (
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/nickrio/coward/common/codec"
+	"github.com/nickrio/coward/common/streamer/timed"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Labels used to separate HMAC and HKDF results which been
+// calculated with the same key
+var (
+	maskLabel    = []byte{'M'}
+	tagLabel     = []byte{'T'}
+	trafficLabel = []byte("COWARD X25519 Traffic")
+)
+
+// Builder builds the Streamer with the derived traffic key
+type Builder func(key []byte) (codec.Streamer, error)
+
+// Exchanger exchanges ephemeral keys with the remote and returns
+// Streamers for both directions, and the index of the pre-shared key
+// which the remote is using
+type Exchanger interface {
+	Exchange(rw io.ReadWriter) (
+		reader codec.Streamer, writer codec.Streamer, key int, err error)
+}
+
+// exchanger implements Exchanger.
+//
+// Both ends will send a hello to each other, then read the hello
+// from the other end. The hello is authenticated with the pre-shared
+// key, and contains an ephemeral X25519 public key. Traffic keys
+// are derived from the X25519 shared secret, so recorded traffic
+// can't be decrypted even if the pre-shared key is leaked later.
+//
+// Hello format:
+//
+// +------------+-----------+-----+
+// | PUBLIC KEY | TIMESTAMP | TAG |
+// +------------+-----------+-----+
+// |     32     |     8     | 16  |
+// +------------+-----------+-----+
+//
+// TIMESTAMP is masked, and TAG authenticates both PUBLIC KEY and
+// TIMESTAMP.
+//
+// When multiple pre-shared keys are given, the local hello will be
+// sent only after the remote hello has been received, as we can't
+// know which key to use before that. So only one end of the connection
+// can have multiple keys
+type exchanger struct {
+	keys      [][]byte
+	keySize   int
+	tolerance time.Duration
+	filter    codec.HeadFilter
+	build     Builder
+}
+
+// New creates a new X25519 Exchanger which accepts any of given
+// pre-shared keys. Public keys received by the Exchanger will be
+// checked by the filter if it's not nil
+func New(
+	keys [][]byte,
+	keySize int,
+	tolerance time.Duration,
+	filter codec.HeadFilter,
+	build Builder,
+) Exchanger {
+	return &exchanger{
+		keys:      keys,
+		keySize:   keySize,
+		tolerance: tolerance,
+		filter:    filter,
+		build:     build,
+	}
+}
+
+// hmacSum calculates HMAC of given messages
+func hmacSum(key []byte, messages ...[]byte) []byte {
+	mac := hmac.New(sha256.New, key)
+
+	for _, message := range messages {
+		// hash.Hash never returns an error
+		mac.Write(message)
+	}
+
+	return mac.Sum(nil)
+}
+
+// mask masks or unmasks the timestamp
+func mask(key []byte, publicKey []byte, timestamp []byte) {
+	mask := hmacSum(key, maskLabel, publicKey)
+
+	for idx := range timestamp {
+		timestamp[idx] ^= mask[idx]
+	}
+}
+
+// tag calculates the authentication tag of the hello
+func tag(key []byte, publicKey []byte, timestamp []byte) []byte {
+	return hmacSum(key, tagLabel, publicKey, timestamp)[:TagSize]
+}
+
+// newHello builds the hello message
+func newHello(key []byte, publicKey []byte) [HelloSize]byte {
+	hello := [HelloSize]byte{}
+	timestamp := hello[PublicKeySize : PublicKeySize+TimeSize]
+
+	copy(hello[:PublicKeySize], publicKey)
+
+	binary.BigEndian.PutUint64(timestamp, uint64(time.Now().Unix()))
+
+	copy(hello[PublicKeySize+TimeSize:], tag(key, publicKey, timestamp))
+
+	mask(key, publicKey, timestamp)
+
+	return hello
+}
+
+// selectKey finds out which key the hello is tagged with, and unmasks
+// the timestamp with it
+func (e *exchanger) selectKey(hello *[HelloSize]byte) (int, error) {
+	publicKey := hello[:PublicKeySize]
+	timestamp := [TimeSize]byte{}
+
+	for idx, key := range e.keys {
+		copy(timestamp[:], hello[PublicKeySize:PublicKeySize+TimeSize])
+
+		mask(key, publicKey, timestamp[:])
+
+		if !hmac.Equal(tag(key, publicKey, timestamp[:]),
+			hello[PublicKeySize+TimeSize:]) {
+			continue
+		}
+
+		copy(hello[PublicKeySize:PublicKeySize+TimeSize], timestamp[:])
+
+		return idx, nil
+	}
+
+	return -1, ErrInvalidHello
+}
+
+// verify verifies the hello message received from remote and returns
+// the public key inside of it, and the index of the key which the
+// hello is tagged with
+func (e *exchanger) verify(
+	hello [HelloSize]byte) (*ecdh.PublicKey, int, error) {
+	selected, selectErr := e.selectKey(&hello)
+
+	if selectErr != nil {
+		return nil, -1, selectErr
+	}
+
+	publicKey := hello[:PublicKeySize]
+	timestamp := hello[PublicKeySize : PublicKeySize+TimeSize]
+
+	skew := time.Now().Sub(
+		time.Unix(int64(binary.BigEndian.Uint64(timestamp)), 0))
+
+	if skew > e.tolerance || skew < -e.tolerance {
+		return nil, -1, timed.ErrClockSkew
+	}
+
+	if e.filter != nil && !e.filter.Accept(publicKey) {
+		return nil, -1, timed.ErrReplayedHead
+	}
+
+	remoteKey, remoteKeyErr := ecdh.X25519().NewPublicKey(publicKey)
+
+	if remoteKeyErr != nil {
+		return nil, -1, ErrInvalidPublicKey
+	}
+
+	return remoteKey, selected, nil
+}
+
+// traffic derives the traffic key for data sent from sender to
+// receiver and builds a Streamer with it
+func (e *exchanger) traffic(
+	key []byte,
+	secret []byte,
+	sender []byte,
+	receiver []byte,
+) (codec.Streamer, error) {
+	info := make([]byte, 0, len(trafficLabel)+len(sender)+len(receiver))
+	info = append(info, trafficLabel...)
+	info = append(info, sender...)
+	info = append(info, receiver...)
+
+	trafficKey := make([]byte, e.keySize)
+
+	_, rErr := io.ReadFull(hkdf.New(sha256.New, secret, key, info),
+		trafficKey)
+
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	return e.build(trafficKey)
+}
+
+// sendHello sends the local hello to the remote
+func (e *exchanger) sendHello(
+	w io.Writer, key []byte, publicKey []byte) error {
+	localHello := newHello(key, publicKey)
+
+	wLen, wErr := w.Write(localHello[:])
+
+	if wErr != nil {
+		return wErr
+	}
+
+	if wLen != HelloSize {
+		return ErrFailedFullyWriteHello
+	}
+
+	return nil
+}
+
+// receiveHello receives and verifies the remote hello
+func (e *exchanger) receiveHello(
+	r io.Reader) (*ecdh.PublicKey, int, error) {
+	remoteHello := [HelloSize]byte{}
+
+	rLen, rErr := io.ReadFull(r, remoteHello[:])
+
+	if rErr != nil {
+		return nil, -1, rErr
+	}
+
+	if rLen != HelloSize {
+		return nil, -1, ErrFailedFullyReadHello
+	}
+
+	return e.verify(remoteHello)
+}
+
+// Exchange exchanges keys with the remote
+func (e *exchanger) Exchange(
+	rw io.ReadWriter) (codec.Streamer, codec.Streamer, int, error) {
+	var remotePublicKey *ecdh.PublicKey
+	var selected int
+	var exchangeErr error
+
+	if len(e.keys) <= 0 {
+		return nil, nil, -1, ErrKeyTooShort
+	}
+
+	for _, key := range e.keys {
+		if len(key) <= 0 {
+			return nil, nil, -1, ErrKeyTooShort
+		}
+	}
+
+	privateKey, privateKeyErr := ecdh.X25519().GenerateKey(rand.Reader)
+
+	if privateKeyErr != nil {
+		return nil, nil, -1, privateKeyErr
+	}
+
+	localPublicKey := privateKey.PublicKey().Bytes()
+
+	if len(e.keys) == 1 {
+		// Send our hello first, so both ends can read without waiting
+		// for each other
+		exchangeErr = e.sendHello(rw, e.keys[0], localPublicKey)
+
+		if exchangeErr != nil {
+			return nil, nil, -1, exchangeErr
+		}
+
+		remotePublicKey, selected, exchangeErr = e.receiveHello(rw)
+
+		if exchangeErr != nil {
+			return nil, nil, -1, exchangeErr
+		}
+	} else {
+		remotePublicKey, selected, exchangeErr = e.receiveHello(rw)
+
+		if exchangeErr != nil {
+			return nil, nil, -1, exchangeErr
+		}
+
+		exchangeErr = e.sendHello(rw, e.keys[selected], localPublicKey)
+
+		if exchangeErr != nil {
+			return nil, nil, -1, exchangeErr
+		}
+	}
+
+	secret, secretErr := privateKey.ECDH(remotePublicKey)
+
+	if secretErr != nil {
+		return nil, nil, -1, ErrInvalidPublicKey
+	}
+
+	reader, readerErr := e.traffic(e.keys[selected],
+		secret, remotePublicKey.Bytes(), localPublicKey)
+
+	if readerErr != nil {
+		return nil, nil, -1, readerErr
+	}
+
+	writer, writerErr := e.traffic(e.keys[selected],
+		secret, localPublicKey, remotePublicKey.Bytes())
+
+	if writerErr != nil {
+		return nil, nil, -1, writerErr
+	}
+
+	return reader, writer, selected, nil
+}
@@ -0,0 +1,291 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package x25519
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nickrio/coward/common/codec"
+	"github.com/nickrio/coward/common/streamer/aead"
+	"github.com/nickrio/coward/common/streamer/timed"
+)
+
+type testHeadFilter map[string]bool
+
+func (f testHeadFilter) Accept(head []byte) bool {
+	if f[string(head)] {
+		return false
+	}
+
+	f[string(head)] = true
+
+	return true
+}
+
+type testExchanged struct {
+	reader codec.Streamer
+	writer codec.Streamer
+	key    int
+	err    error
+}
+
+func testMustGetConns() (net.Conn, net.Conn) {
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+
+	if listenErr != nil {
+		panic(listenErr)
+	}
+
+	defer listener.Close()
+
+	client, dialErr := net.Dial("tcp", listener.Addr().String())
+
+	if dialErr != nil {
+		panic(dialErr)
+	}
+
+	server, acceptErr := listener.Accept()
+
+	if acceptErr != nil {
+		panic(acceptErr)
+	}
+
+	return client, server
+}
+
+func testExchange(
+	client Exchanger,
+	server Exchanger,
+) (testExchanged, testExchanged) {
+	clientConn, serverConn := testMustGetConns()
+
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	serverResult := make(chan testExchanged)
+
+	go func() {
+		r, w, k, err := server.Exchange(serverConn)
+
+		serverResult <- testExchanged{reader: r, writer: w, key: k, err: err}
+	}()
+
+	r, w, k, err := client.Exchange(clientConn)
+
+	return testExchanged{reader: r, writer: w, key: k, err: err},
+		<-serverResult
+}
+
+func TestExchangerReadWrite(t *testing.T) {
+	key := [][]byte{[]byte("1234567890123456")}
+	client, server := testExchange(
+		New(key, 32, 10*time.Second, nil, aead.NewChaCha20Poly1305),
+		New(key, 32, 10*time.Second, nil, aead.NewChaCha20Poly1305))
+
+	if client.err != nil || server.err != nil {
+		t.Error("Exchange failed:", client.err, server.err)
+
+		return
+	}
+
+	for _, pair := range [][2]codec.Streamer{
+		{client.writer, server.reader},
+		{server.writer, client.reader},
+	} {
+		buf := bytes.NewBuffer(make([]byte, 0, 4096))
+		writer := codec.NewWriter(pair[0], buf)
+		reader := codec.NewReader(pair[1], buf)
+		testData := []byte("Test data is here")
+
+		_, wErr := writer.Write(testData)
+
+		if wErr != nil {
+			t.Error("Writer failed:", wErr)
+
+			return
+		}
+
+		readBuf := make([]byte, len(testData))
+
+		_, rErr := io.ReadFull(reader, readBuf)
+
+		if rErr != nil {
+			t.Error("Can't read due to error:", rErr)
+
+			return
+		}
+
+		if !bytes.Equal(testData, readBuf) {
+			t.Errorf("Failed to read expected data, expecting %v, got %v",
+				testData, readBuf)
+
+			return
+		}
+	}
+}
+
+func TestExchangerDirectionalKeys(t *testing.T) {
+	key := [][]byte{[]byte("1234567890123456")}
+	client, server := testExchange(
+		New(key, 32, 10*time.Second, nil, aead.NewAESGCM),
+		New(key, 32, 10*time.Second, nil, aead.NewAESGCM))
+
+	if client.err != nil || server.err != nil {
+		t.Error("Exchange failed:", client.err, server.err)
+
+		return
+	}
+
+	// Data written by client must not be readable with the key of
+	// the same direction on the other end
+	buf := bytes.NewBuffer(make([]byte, 0, 4096))
+	writer := codec.NewWriter(client.writer, buf)
+	reader := codec.NewReader(server.writer, buf)
+
+	_, wErr := writer.Write([]byte("Test data is here"))
+
+	if wErr != nil {
+		t.Error("Writer failed:", wErr)
+
+		return
+	}
+
+	_, rErr := reader.Read(make([]byte, 4096))
+
+	if rErr == nil {
+		t.Error("Expecting an error, got nil")
+
+		return
+	}
+}
+
+func TestExchangerSelectKey(t *testing.T) {
+	keys := [][]byte{
+		[]byte("1234567890123456"),
+		[]byte("6543210987654321"),
+	}
+	client, server := testExchange(
+		New(keys[1:], 32, 10*time.Second, nil, aead.NewChaCha20Poly1305),
+		New(keys, 32, 10*time.Second, nil, aead.NewChaCha20Poly1305))
+
+	if client.err != nil || server.err != nil {
+		t.Error("Exchange failed:", client.err, server.err)
+
+		return
+	}
+
+	if server.key != 1 {
+		t.Errorf("Expecting key %d to be selected, got %d", 1, server.key)
+
+		return
+	}
+}
+
+func TestExchangerInvalidKey(t *testing.T) {
+	client, server := testExchange(
+		New([][]byte{[]byte("1234567890123456")}, 32, 10*time.Second,
+			nil, aead.NewAESGCM),
+		New([][]byte{[]byte("6543210987654321")}, 32, 10*time.Second,
+			nil, aead.NewAESGCM))
+
+	if client.err != ErrInvalidHello {
+		t.Errorf("Expecting error %s, got %v", ErrInvalidHello, client.err)
+
+		return
+	}
+
+	if server.err != ErrInvalidHello {
+		t.Errorf("Expecting error %s, got %v", ErrInvalidHello, server.err)
+
+		return
+	}
+}
+
+func testMustGetPublicKey() []byte {
+	privateKey, privateKeyErr := ecdh.X25519().GenerateKey(rand.Reader)
+
+	if privateKeyErr != nil {
+		panic(privateKeyErr)
+	}
+
+	return privateKey.PublicKey().Bytes()
+}
+
+func TestExchangerClockSkew(t *testing.T) {
+	e := New([][]byte{[]byte("1234567890123456")}, 32, 10*time.Second,
+		nil, aead.NewAESGCM).(*exchanger)
+	key := e.keys[0]
+	publicKey := testMustGetPublicKey()
+
+	for _, skew := range []time.Duration{-time.Minute, time.Minute} {
+		hello := [HelloSize]byte{}
+		timestamp := hello[PublicKeySize : PublicKeySize+TimeSize]
+
+		// Build the hello with a skewed timestamp
+		copy(hello[:PublicKeySize], publicKey)
+
+		binary.BigEndian.PutUint64(
+			timestamp, uint64(time.Now().Add(skew).Unix()))
+
+		copy(hello[PublicKeySize+TimeSize:], tag(key, publicKey, timestamp))
+
+		mask(key, publicKey, timestamp)
+
+		_, _, verifyErr := e.verify(hello)
+
+		if verifyErr != timed.ErrClockSkew {
+			t.Errorf("Expecting error %s, got %v",
+				timed.ErrClockSkew, verifyErr)
+
+			return
+		}
+	}
+}
+
+func TestExchangerReplayedHello(t *testing.T) {
+	e := New([][]byte{[]byte("1234567890123456")}, 32, 10*time.Second,
+		testHeadFilter{}, aead.NewAESGCM).(*exchanger)
+	hello := newHello(e.keys[0], testMustGetPublicKey())
+
+	_, _, verifyErr := e.verify(hello)
+
+	if verifyErr != nil {
+		t.Error("Can't verify hello due to error:", verifyErr)
+
+		return
+	}
+
+	_, _, verifyErr = e.verify(hello)
+
+	if verifyErr != timed.ErrReplayedHead {
+		t.Errorf("Expecting error %s, got %v",
+			timed.ErrReplayedHead, verifyErr)
+
+		return
+	}
+}
@@ -42,7 +42,9 @@ func main() {
 		Components: application.Components{
 			socks5.Role, proxy.Role, channel.Role,
 			wrapper.Plain, wrapper.AESCFB128, wrapper.AESCFB256,
-			wrapper.Chaotic,
+			wrapper.AESGCM128, wrapper.AESGCM256, wrapper.ChaCha20Poly1305,
+			wrapper.X25519AESGCM256, wrapper.X25519ChaCha20Poly1305,
+			wrapper.Chaotic, wrapper.Shaper, wrapper.Deflate,
 		},
 	})
 
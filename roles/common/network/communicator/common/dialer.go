@@ -22,112 +22,230 @@ package common
 
 import (
 	"net"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
 
-	"github.com/nickrio/coward/common/locked"
+	"github.com/nickrio/coward/roles/common/network"
+	"github.com/nickrio/coward/roles/common/network/dns"
 )
 
-// Dialer is a net.Dial wrapper which will cache target IP address
+const (
+	// lookupTimeout is how long the DNS queries sent for Dialers can
+	// wait for the replies
+	lookupTimeout = 5 * time.Second
+)
+
+// resolver resolves the host names for all Dialers
+var (
+	resolver     dns.Resolver
+	resolverOnce sync.Once
+)
+
+// Dialer is a net.Dial wrapper which will cache target IP addresses
 // The reason for Dialer to exist is for some network, sometimes it's
 // hard to always get in touch with some DNS servers to get a host name
 // resolved.
 // Consider the host IP is usually stay the same, we could keep connecting
-// it using the remote IP address resolved by pervious dialer.
-// + We also implemented a method to renew the resolved IP address when
-// needed: Try to renew the resolved IP every time when a dial has failed.
-// but do not erase the old IP if resolver has failed.
+// it using the remote IP addresses resolved by pervious dialer.
+// + All resolved addresses are kept until their DNS TTL expires, and
+// the old addresses will still be used if the resolver has failed.
+// Hosts are resolved by a dns.Resolver which is shared by all Dialers.
+// + Addresses are tried Happy-Eyeballs style: IPv6 and IPv4 addresses
+// in turn, a new attempt will be started when the previous one failed
+// or didn't complete in time. Addresses that keep failing will be
+// tried last.
+// When an Upstream is defined, all connection will be tunneled through
+// it, and the host name will be resolved by the upstream proxy
 type Dialer interface {
 	Dial(dialType string, dialTimeout time.Duration) (net.Conn, error)
 }
 
+// resolvedAddress is a resolved address of the host
+type resolvedAddress struct {
+	ip       net.IP
+	failures uint32
+}
+
 // dialer implements Dialer
 type dialer struct {
-	defaultHost    string
-	resolvedHostIP net.IP
-	port           uint16
-	tryRenew       locked.Boolean
-	lock           sync.RWMutex
+	defaultHost string
+	port        uint16
+	upstream    Upstream
+	resolver    dns.Resolver
+	addresses   []*resolvedAddress
+	lock        sync.Mutex
 }
 
-// NewDialer creates a new Dialer
-func NewDialer(defaultHost string, port uint16) Dialer {
+// NewDialer creates a new Dialer. upstream can be nil
+func NewDialer(defaultHost string, port uint16, upstream Upstream) Dialer {
 	return &dialer{
-		defaultHost:    defaultHost,
-		resolvedHostIP: nil,
-		port:           port,
-		tryRenew:       locked.NewBool(false),
-		lock:           sync.RWMutex{},
+		defaultHost: defaultHost,
+		port:        port,
+		upstream:    upstream,
+		resolver:    sharedResolver(),
+		addresses:   nil,
+		lock:        sync.Mutex{},
 	}
 }
 
-// updateIPResolved updates resolved IP address from net.Conn
-func (d *dialer) updateIPResolved(conn net.Conn) {
-	connIP, _, connSpErr := net.SplitHostPort(
-		conn.RemoteAddr().String())
+// sharedResolver returns the Resolver of all Dialers. It sends queries
+// to the system DNS servers so the TTLs can be known, and falls back
+// to the system resolver when the DNS servers are unavailable
+func sharedResolver() dns.Resolver {
+	resolverOnce.Do(func() {
+		var client dns.Client
+
+		servers := dns.SystemServers()
+
+		if len(servers) > 0 {
+			client, _ = dns.NewClient(servers, "udp", lookupTimeout)
+		}
+
+		resolver = dns.NewResolver(dns.ResolverConfig{
+			Client:      client,
+			Fallback:    true,
+			Prefer:      dns.PreferNone,
+			NegativeTTL: dns.DefaultNegativeTTL,
+			CacheSize:   dns.DefaultCacheSize,
+		})
+	})
+
+	return resolver
+}
 
-	if connSpErr != nil {
-		return
+// resolve returns the addresses of the host. The old addresses will be
+// kept if resolve has failed
+func (d *dialer) resolve() ([]*resolvedAddress, error) {
+	records, resolveErr := d.resolver.Resolve(d.defaultHost)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if resolveErr != nil || len(records) <= 0 {
+		if len(d.addresses) > 0 {
+			return d.addresses, nil
+		}
+
+		if resolveErr == nil {
+			resolveErr = dns.ErrNameNotFound
+		}
+
+		return nil, resolveErr
 	}
 
-	resolvedIP := net.ParseIP(connIP)
+	addresses := make([]*resolvedAddress, 0, len(records))
 
-	if resolvedIP == nil {
-		return
+	for _, record := range records {
+		address := &resolvedAddress{ip: record.IP, failures: 0}
+
+		// Keep the failure records of known addresses
+		for _, known := range d.addresses {
+			if !known.ip.Equal(record.IP) {
+				continue
+			}
+
+			address = known
+		}
+
+		addresses = append(addresses, address)
 	}
 
+	d.addresses = addresses
+
+	return d.addresses, nil
+}
+
+// order sorts addresses by how many times they failed, then puts the
+// IPv6 and IPv4 addresses in turn, begins with the family of the best
+// address
+func (d *dialer) order(addresses []*resolvedAddress) []*resolvedAddress {
 	d.lock.Lock()
-	defer d.lock.Unlock()
 
-	d.resolvedHostIP = resolvedIP
+	sorted := make([]*resolvedAddress, len(addresses))
+
+	copy(sorted, addresses)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].failures != sorted[j].failures {
+			return sorted[i].failures < sorted[j].failures
+		}
+
+		return sorted[i].ip.To4() == nil && sorted[j].ip.To4() != nil
+	})
+
+	d.lock.Unlock()
+
+	ips := make([]net.IP, len(sorted))
+
+	for idx, address := range sorted {
+		ips[idx] = address.ip
+	}
+
+	ordered := make([]*resolvedAddress, 0, len(sorted))
+
+	for _, ip := range network.InterleaveIPs(ips) {
+		for idx, address := range sorted {
+			if address == nil || !address.ip.Equal(ip) {
+				continue
+			}
+
+			ordered = append(ordered, address)
+			sorted[idx] = nil
+
+			break
+		}
+	}
+
+	return ordered
 }
 
-// getResolvedIP returns resolved IP from cache
-func (d *dialer) getResolvedIP() string {
-	d.lock.RLock()
-	defer d.lock.RUnlock()
+// report records the result of a connection attempt
+func (d *dialer) report(address *resolvedAddress, succeed bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if succeed {
+		address.failures = 0
+
+		return
+	}
 
-	return d.resolvedHostIP.String()
+	address.failures++
 }
 
 // Dial dial to the remote target
 func (d *dialer) Dial(
 	dialType string, dialTimeout time.Duration) (net.Conn, error) {
-	var targetAddr string
-	var updateResolvedIP bool
-
-	// We will set tryRenew to false no matter if we actually got a
-	// new IP address.
-	// This is because some time network itself can cause unstable
-	// connection which triggers tryRenew, but that not meaning the
-	// IP address of the target host has changed.
-	// If we clear the resolvedHostIP for every each failed connection
-	// that will be a waste of work.
-
-	resolvedHostIP := d.getResolvedIP()
-
-	if d.tryRenew.GetSet(false) || len(resolvedHostIP) <= 0 {
-		targetAddr = d.defaultHost
-		updateResolvedIP = true
-	} else {
-		targetAddr = resolvedHostIP
+	port := strconv.FormatUint(uint64(d.port), 10)
+
+	if d.upstream != nil {
+		return d.upstream.Dial(dialType,
+			net.JoinHostPort(d.defaultHost, port), dialTimeout)
 	}
 
-	hostAddr := net.JoinHostPort(
-		targetAddr, strconv.FormatUint(uint64(d.port), 10))
+	addresses, resolveErr := d.resolve()
 
-	conn, dialErr := net.DialTimeout(dialType, hostAddr, dialTimeout)
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
 
-	if dialErr != nil {
-		d.tryRenew.Set(true)
+	addresses = d.order(addresses)
+	targets := make([]string, len(addresses))
 
-		return nil, dialErr
+	for idx, address := range addresses {
+		targets[idx] = net.JoinHostPort(address.ip.String(), port)
 	}
 
-	if updateResolvedIP {
-		d.updateIPResolved(conn)
+	conn, dialErr := network.DialRace(dialType, targets,
+		network.DialAttemptDelay, dialTimeout, func(index int, err error) {
+			d.report(addresses[index], err == nil)
+		})
+
+	if dialErr != nil {
+		return nil, dialErr
 	}
 
-	return conn, dialErr
+	return conn, nil
 }
@@ -0,0 +1,169 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nickrio/coward/roles/common/network/dns"
+)
+
+func testDialerListen(t *testing.T) (net.Listener, uint16) {
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+
+	if listenErr != nil {
+		t.Fatal("Failed to listen due to error:", listenErr)
+	}
+
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+
+			if acceptErr != nil {
+				return
+			}
+
+			conn.Close()
+		}
+	}()
+
+	_, portStr, _ := net.SplitHostPort(listener.Addr().String())
+	port, _ := strconv.ParseUint(portStr, 10, 16)
+
+	return listener, uint16(port)
+}
+
+type testResolver func(name string) ([]dns.Record, error)
+
+func (r testResolver) Resolve(name string) ([]dns.Record, error) {
+	return r(name)
+}
+
+func (r testResolver) Prefer() dns.Preference {
+	return dns.PreferNone
+}
+
+func (r testResolver) Stats() dns.ResolverStats {
+	return dns.ResolverStats{}
+}
+
+func TestDialerFailover(t *testing.T) {
+	listener, port := testDialerListen(t)
+
+	defer listener.Close()
+
+	d := NewDialer("example.com", port, nil).(*dialer)
+
+	d.resolver = testResolver(func(host string) ([]dns.Record, error) {
+		// Nothing is listening on 127.0.0.2
+		return []dns.Record{
+			{IP: net.ParseIP("127.0.0.2"), TTL: time.Hour},
+			{IP: net.ParseIP("127.0.0.1"), TTL: time.Hour},
+		}, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		conn, dialErr := d.Dial("tcp", time.Second)
+
+		if dialErr != nil {
+			t.Error("Failed to dial due to error:", dialErr)
+
+			return
+		}
+
+		conn.Close()
+	}
+
+	ordered := d.order(d.addresses)
+
+	if !ordered[0].ip.Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("Expecting failing address will be tried last, got %s",
+			ordered[0].ip)
+
+		return
+	}
+}
+
+func TestDialerKeepAddressesWhenResolveFailed(t *testing.T) {
+	listener, port := testDialerListen(t)
+
+	defer listener.Close()
+
+	d := NewDialer("example.com", port, nil).(*dialer)
+
+	d.resolver = testResolver(func(host string) ([]dns.Record, error) {
+		return []dns.Record{
+			{IP: net.ParseIP("127.0.0.1"), TTL: time.Hour},
+		}, nil
+	})
+
+	conn, dialErr := d.Dial("tcp", time.Second)
+
+	if dialErr != nil {
+		t.Error("Failed to dial due to error:", dialErr)
+
+		return
+	}
+
+	conn.Close()
+
+	d.resolver = testResolver(func(host string) ([]dns.Record, error) {
+		return nil, errors.New("Resolve failed")
+	})
+
+	conn, dialErr = d.Dial("tcp", time.Second)
+
+	if dialErr != nil {
+		t.Error("Failed to dial due to error:", dialErr)
+
+		return
+	}
+
+	conn.Close()
+}
+
+func TestDialerOrder(t *testing.T) {
+	d := NewDialer("example.com", 80, nil).(*dialer)
+
+	ordered := d.order([]*resolvedAddress{
+		{ip: net.ParseIP("10.0.0.1"), failures: 0},
+		{ip: net.ParseIP("10.0.0.2"), failures: 0},
+		{ip: net.ParseIP("fd00::1"), failures: 2},
+		{ip: net.ParseIP("fd00::2"), failures: 0},
+	})
+
+	expected := []string{"fd00::2", "10.0.0.1", "fd00::1", "10.0.0.2"}
+
+	for idx, address := range ordered {
+		if address.ip.String() == expected[idx] {
+			continue
+		}
+
+		t.Errorf("Expecting address %d will be %s, got %s",
+			idx, expected[idx], address.ip)
+
+		return
+	}
+}
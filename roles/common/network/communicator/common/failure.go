@@ -0,0 +1,314 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nickrio/coward/common/locked"
+	"github.com/nickrio/coward/roles/common/network/conn"
+)
+
+// Failure errors
+var (
+	ErrUnauthenticatedConnFailed = errors.New(
+		"Connection failed before it was authenticated")
+
+	ErrFallbackDataIncomplete = errors.New(
+		"Not all data of the connection was recorded for the fallback")
+)
+
+// FailurePolicy decides what to do with a connection which failed to
+// be decoded before it was authenticated. Instead of replying or
+// closing right away, the server keeps reading and discarding data
+// from the connection for a random duration between MinWait and
+// MaxWait, then closes it silently.
+//
+// If Fallback address is set, the connection will be forwarded to it
+// instead, together with the data that has already been read, so the
+// remote will be talking to the fallback server from the beginning
+type FailurePolicy struct {
+	MinWait  time.Duration
+	MaxWait  time.Duration
+	Fallback string
+}
+
+// wait returns a random wait duration within the policy range
+func (f FailurePolicy) wait() time.Duration {
+	if f.MaxWait <= f.MinWait {
+		return f.MinWait
+	}
+
+	return f.MinWait + time.Duration(
+		rand.Int63n(int64(f.MaxWait-f.MinWait)))
+}
+
+// GuardedConn applies the FailurePolicy to a server side connection.
+// Conn is the decoding connection, and Raw is the Recorder which
+// underlays it. The connection is authenticated once data can be read
+// from Conn.
+//
+// If nothing can be decoded within the connect timeout after the first
+// read, the connection will be considered as failed as well, so probes
+// which are shorter than the head of the codec will get the same
+// treatment as the ones which failed to be decoded
+type GuardedConn struct {
+	net.Conn
+
+	raw            *Recorder
+	policy         FailurePolicy
+	connectTimeout time.Duration
+	idleTimeout    time.Duration
+	lock           sync.Mutex
+	headTimer      *time.Timer
+	authenticated  bool
+	expired        bool
+	handedOff      bool
+	closed         bool
+	failed         locked.Boolean
+}
+
+// NewGuardedConn creates a new GuardedConn
+func NewGuardedConn(
+	c net.Conn,
+	raw *Recorder,
+	policy FailurePolicy,
+	connectTimeout time.Duration,
+	idleTimeout time.Duration,
+) *GuardedConn {
+	return &GuardedConn{
+		Conn:           c,
+		raw:            raw,
+		policy:         policy,
+		connectTimeout: connectTimeout,
+		idleTimeout:    idleTimeout,
+		lock:           sync.Mutex{},
+		headTimer:      nil,
+		authenticated:  false,
+		expired:        false,
+		handedOff:      false,
+		closed:         false,
+		failed:         locked.NewBool(false),
+	}
+}
+
+// Identity returns the name of the key which the remote is using
+func (g *GuardedConn) Identity() (string, bool) {
+	identified, isIdentified := g.Conn.(IdentifiedConn)
+
+	if !isIdentified {
+		return "", false
+	}
+
+	return identified.Identity()
+}
+
+// expire interrupts the pending read if the connection still not been
+// authenticated when the head deadline is reached
+func (g *GuardedConn) expire() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.authenticated || g.closed {
+		return
+	}
+
+	g.expired = true
+
+	g.raw.Interrupt()
+}
+
+// Read reads data from the connection. If the data can't be decoded
+// before the connection was authenticated, the connection will be
+// handed over to a routine which deals with it according to the
+// FailurePolicy, and Read returns immediately
+func (g *GuardedConn) Read(b []byte) (int, error) {
+	g.lock.Lock()
+
+	if !g.authenticated && g.headTimer == nil && g.connectTimeout > 0 {
+		g.headTimer = time.AfterFunc(g.connectTimeout, g.expire)
+	}
+
+	g.lock.Unlock()
+
+	rLen, rErr := g.Conn.Read(b)
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.authenticated {
+		return rLen, rErr
+	}
+
+	if rErr == nil && !g.expired {
+		g.authenticated = true
+
+		if g.headTimer != nil {
+			g.headTimer.Stop()
+		}
+
+		g.raw.Stop()
+
+		return rLen, nil
+	}
+
+	if !g.expired {
+		if rErr == io.EOF {
+			return rLen, rErr
+		}
+
+		// Network errors is not caused by the data, nothing to hide
+		if _, isConnErr := rErr.(conn.ErrorConnError); isConnErr {
+			return rLen, rErr
+		}
+	}
+
+	if rErr == nil {
+		rErr = ErrUnauthenticatedConnFailed
+	}
+
+	if g.closed || g.handedOff {
+		return 0, rErr
+	}
+
+	g.failed.Set(true)
+
+	if g.headTimer != nil {
+		g.headTimer.Stop()
+	}
+
+	g.handedOff = true
+
+	go g.fail()
+
+	return 0, rErr
+}
+
+// fail deals with the failed connection, and closes the connection
+// once it's done
+func (g *GuardedConn) fail() {
+	defer g.raw.Close()
+
+	if g.policy.Fallback != "" && g.fallback() == nil {
+		return
+	}
+
+	g.discard()
+}
+
+// fallback forwards the connection to the fallback server, and
+// returns after the fallback server finished the conversation
+func (g *GuardedConn) fallback() error {
+	recorded, recordedAll := g.raw.Recorded()
+
+	g.raw.Stop()
+
+	if !recordedAll {
+		return ErrFallbackDataIncomplete
+	}
+
+	fallbackConn, dialErr := net.DialTimeout(
+		"tcp", g.policy.Fallback, g.connectTimeout)
+
+	if dialErr != nil {
+		return dialErr
+	}
+
+	defer fallbackConn.Close()
+
+	target := conn.NewTimed(fallbackConn)
+	remote := conn.NewTimed(g.raw.Conn)
+
+	target.SetTimeout(g.idleTimeout)
+	remote.SetTimeout(g.idleTimeout)
+
+	_, wErr := target.Write(recorded)
+
+	if wErr != nil {
+		return wErr
+	}
+
+	// The routine will exit when the remote stops sending, or when
+	// the connection is closed after the fallback server is done
+	go func() {
+		io.Copy(target, remote)
+
+		fallbackConn.Close()
+	}()
+
+	io.Copy(remote, target)
+
+	return nil
+}
+
+// discard reads and drops data from the raw connection until the
+// wait duration is reached or the connection is closed
+func (g *GuardedConn) discard() {
+	wait := g.policy.wait()
+
+	if wait <= 0 {
+		return
+	}
+
+	deadlineErr := g.raw.Conn.SetReadDeadline(time.Now().Add(wait))
+
+	if deadlineErr != nil {
+		return
+	}
+
+	io.Copy(ioutil.Discard, g.raw.Conn)
+}
+
+// Write writes data to the connection. Nothing will be written to a
+// connection which has failed before it was authenticated, so the
+// remote will not get any reply
+func (g *GuardedConn) Write(b []byte) (int, error) {
+	if g.failed.Get() {
+		return 0, ErrUnauthenticatedConnFailed
+	}
+
+	return g.Conn.Write(b)
+}
+
+// Close closes the connection. A connection which has been handed over
+// to the failure routine will be closed by that routine instead
+func (g *GuardedConn) Close() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.closed = true
+
+	if g.headTimer != nil {
+		g.headTimer.Stop()
+	}
+
+	if g.handedOff {
+		return nil
+	}
+
+	return g.Conn.Close()
+}
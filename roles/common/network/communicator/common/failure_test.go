@@ -0,0 +1,282 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+type testFailingConn struct {
+	net.Conn
+}
+
+func (t testFailingConn) Read(b []byte) (int, error) {
+	return 0, errors.New("Decode failed")
+}
+
+type testDecodingConn struct {
+	net.Conn
+}
+
+func (t testDecodingConn) Read(b []byte) (int, error) {
+	head := make([]byte, 4)
+
+	_, rErr := io.ReadFull(t.Conn, head)
+
+	if rErr != nil {
+		return 0, rErr
+	}
+
+	return 0, errors.New("Decode failed")
+}
+
+type testHeadConn struct {
+	net.Conn
+}
+
+func (t testHeadConn) Read(b []byte) (int, error) {
+	head := make([]byte, 40)
+
+	_, rErr := io.ReadFull(t.Conn, head)
+
+	if rErr != nil {
+		return 0, rErr
+	}
+
+	return copy(b, head), nil
+}
+
+func TestGuardedConnFailedBeforeAuthenticated(t *testing.T) {
+	raw, remote := net.Pipe()
+	written := make(chan int)
+
+	defer remote.Close()
+
+	policy := FailurePolicy{
+		MinWait: 100 * time.Millisecond,
+		MaxWait: 200 * time.Millisecond,
+	}
+	conn := NewGuardedConn(testFailingConn{Conn: raw}, NewRecorder(raw),
+		policy, time.Second, time.Second)
+
+	go func() {
+		total := 0
+
+		for i := 0; i < 3; i++ {
+			wLen, wErr := remote.Write([]byte("Probing data"))
+
+			if wErr != nil {
+				break
+			}
+
+			total += wLen
+		}
+
+		written <- total
+	}()
+
+	start := time.Now()
+
+	_, rErr := conn.Read(make([]byte, 16))
+
+	if rErr == nil {
+		t.Error("Expecting an error, got nil")
+
+		return
+	}
+
+	if time.Since(start) >= policy.MinWait {
+		t.Errorf("Expecting Read to return before the wait, it took %s",
+			time.Since(start))
+
+		return
+	}
+
+	if total := <-written; total != 3*len("Probing data") {
+		t.Errorf("Expecting all probing data to be discarded, got %d bytes",
+			total)
+
+		return
+	}
+
+	_, eofErr := remote.Read(make([]byte, 16))
+
+	if eofErr != io.EOF {
+		t.Errorf("Expecting the connection to be closed, got %v", eofErr)
+
+		return
+	}
+
+	if time.Since(start) < policy.MinWait {
+		t.Errorf("Expecting to wait at least %s, waited %s",
+			policy.MinWait, time.Since(start))
+
+		return
+	}
+
+	_, wErr := conn.Write([]byte("Reply"))
+
+	if wErr != ErrUnauthenticatedConnFailed {
+		t.Errorf("Expecting error %s, got %v",
+			ErrUnauthenticatedConnFailed, wErr)
+
+		return
+	}
+}
+
+func testFallbackServer(request []byte) (net.Listener, error) {
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+
+	if listenErr != nil {
+		return nil, listenErr
+	}
+
+	go func() {
+		fallbackConn, acceptErr := listener.Accept()
+
+		if acceptErr != nil {
+			return
+		}
+
+		defer fallbackConn.Close()
+
+		received := make([]byte, len(request))
+
+		_, rErr := io.ReadFull(fallbackConn, received)
+
+		if rErr != nil {
+			return
+		}
+
+		fallbackConn.Write(append([]byte("Fallback: "), received...))
+	}()
+
+	return listener, nil
+}
+
+func TestGuardedConnFallback(t *testing.T) {
+	listener, listenErr := testFallbackServer([]byte("GET / HTTP/1.0"))
+
+	if listenErr != nil {
+		t.Error("Can't listen due to error:", listenErr)
+
+		return
+	}
+
+	defer listener.Close()
+
+	raw, remote := net.Pipe()
+
+	defer remote.Close()
+
+	recorded := NewRecorder(raw)
+	conn := NewGuardedConn(testDecodingConn{Conn: recorded}, recorded,
+		FailurePolicy{
+			MinWait:  0,
+			MaxWait:  0,
+			Fallback: listener.Addr().String(),
+		}, time.Second, time.Second)
+
+	go func() {
+		remote.Write([]byte("GET / HTTP/1.0"))
+	}()
+
+	go func() {
+		conn.Read(make([]byte, 16))
+
+		// The connection has been handed over to the fallback, closing
+		// it must not interrupt the conversation
+		conn.Close()
+	}()
+
+	reply, rErr := ioutil.ReadAll(remote)
+
+	if rErr != nil {
+		t.Error("Can't read reply due to error:", rErr)
+
+		return
+	}
+
+	if !bytes.Equal(reply, []byte("Fallback: GET / HTTP/1.0")) {
+		t.Errorf("Expecting reply %q, got %q",
+			"Fallback: GET / HTTP/1.0", reply)
+
+		return
+	}
+}
+
+func TestGuardedConnShortProbe(t *testing.T) {
+	probe := []byte("GET / HTTP/1.0\r\n\r\n")
+
+	listener, listenErr := testFallbackServer(probe)
+
+	if listenErr != nil {
+		t.Error("Can't listen due to error:", listenErr)
+
+		return
+	}
+
+	defer listener.Close()
+
+	raw, remote := net.Pipe()
+
+	defer remote.Close()
+
+	recorded := NewRecorder(raw)
+	conn := NewGuardedConn(testHeadConn{Conn: recorded}, recorded,
+		FailurePolicy{
+			MinWait:  0,
+			MaxWait:  0,
+			Fallback: listener.Addr().String(),
+		}, 100*time.Millisecond, time.Second)
+
+	go func() {
+		remote.Write(probe)
+	}()
+
+	go func() {
+		conn.Read(make([]byte, 16))
+
+		conn.Close()
+	}()
+
+	reply, rErr := ioutil.ReadAll(remote)
+
+	if rErr != nil {
+		t.Error("Can't read reply due to error:", rErr)
+
+		return
+	}
+
+	expected := append([]byte("Fallback: "), probe...)
+
+	if !bytes.Equal(reply, expected) {
+		t.Errorf("Expecting reply %q, got %q", expected, reply)
+
+		return
+	}
+}
@@ -0,0 +1,81 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"net"
+
+	"github.com/nickrio/coward/roles/common/network/conn"
+)
+
+// NewPipeline creates a ConnWrapper which wraps the conn with all given
+// stages in order. The first stage will be the outermost one, which
+// means data written to the conn will go through the first stage
+// first and the last stage last.
+//
+// If any of the stage knows the identity of the remote, the wrapped
+// conn will be an IdentifiedConn
+func NewPipeline(stages ...ConnWrapper) ConnWrapper {
+	return func(raw net.Conn) (net.Conn, error) {
+		identified := []IdentifiedConn{}
+		wrapped := raw
+
+		for idx := len(stages) - 1; idx >= 0; idx-- {
+			stageConn, stageErr := stages[idx](wrapped)
+
+			if stageErr != nil {
+				return nil, stageErr
+			}
+
+			wrapped = stageConn
+
+			identifiedConn, isIdentified := stageConn.(IdentifiedConn)
+
+			if !isIdentified {
+				continue
+			}
+
+			identified = append(identified, identifiedConn)
+		}
+
+		if len(identified) <= 0 {
+			return wrapped, nil
+		}
+
+		if _, isIdentified := wrapped.(IdentifiedConn); isIdentified {
+			return wrapped, nil
+		}
+
+		return conn.NewIdentified(wrapped, func() (string, bool) {
+			for _, identifiedConn := range identified {
+				identity, known := identifiedConn.Identity()
+
+				if !known {
+					continue
+				}
+
+				return identity, true
+			}
+
+			return "", false
+		}), nil
+	}
+}
@@ -0,0 +1,133 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"net"
+	"testing"
+
+	"github.com/nickrio/coward/roles/common/network/conn"
+)
+
+type testStageConn struct {
+	net.Conn
+
+	order *[]string
+	name  string
+}
+
+func (t *testStageConn) Write(b []byte) (int, error) {
+	*t.order = append(*t.order, t.name)
+
+	return t.Conn.Write(b)
+}
+
+func testStage(order *[]string, name string) ConnWrapper {
+	return func(raw net.Conn) (net.Conn, error) {
+		return &testStageConn{Conn: raw, order: order, name: name}, nil
+	}
+}
+
+func TestPipelineOrder(t *testing.T) {
+	order := []string{}
+	client, server := net.Pipe()
+
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		server.Read(make([]byte, 16))
+	}()
+
+	wrapped, wrapErr := NewPipeline(
+		testStage(&order, "first"),
+		testStage(&order, "second"),
+		testStage(&order, "third"))(client)
+
+	if wrapErr != nil {
+		t.Error("Failed to wrap conn due to error:", wrapErr)
+
+		return
+	}
+
+	_, wErr := wrapped.Write([]byte("Hello"))
+
+	if wErr != nil {
+		t.Error("Failed to write due to error:", wErr)
+
+		return
+	}
+
+	expected := []string{"first", "second", "third"}
+
+	if len(order) != len(expected) {
+		t.Errorf("Expecting stages %v, got %v", expected, order)
+
+		return
+	}
+
+	for idx := range expected {
+		if order[idx] != expected[idx] {
+			t.Errorf("Expecting stages %v, got %v", expected, order)
+
+			return
+		}
+	}
+}
+
+func TestPipelineIdentity(t *testing.T) {
+	order := []string{}
+	client, server := net.Pipe()
+
+	defer client.Close()
+	defer server.Close()
+
+	wrapped, wrapErr := NewPipeline(
+		testStage(&order, "outer"),
+		func(raw net.Conn) (net.Conn, error) {
+			return conn.NewIdentified(raw, func() (string, bool) {
+				return "test", true
+			}), nil
+		},
+		testStage(&order, "inner"))(client)
+
+	if wrapErr != nil {
+		t.Error("Failed to wrap conn due to error:", wrapErr)
+
+		return
+	}
+
+	identified, isIdentified := wrapped.(IdentifiedConn)
+
+	if !isIdentified {
+		t.Error("Expecting the wrapped conn to be identified")
+
+		return
+	}
+
+	identity, known := identified.Identity()
+
+	if !known || identity != "test" {
+		t.Errorf("Expecting identity %s, got %s", "test", identity)
+
+		return
+	}
+}
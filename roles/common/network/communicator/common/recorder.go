@@ -0,0 +1,108 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/nickrio/coward/common/locked"
+)
+
+// Recorder errors
+var (
+	ErrRecorderInterrupted = errors.New(
+		"Reading from the recorded connection has been interrupted")
+)
+
+// maxRecordSize is the maximum amount of data that will be recorded
+// before a connection is authenticated
+const maxRecordSize = 16 * 1024
+
+// Recorder records data read from a connection so it can be replayed
+// to another connection later
+type Recorder struct {
+	net.Conn
+
+	record      []byte
+	recording   bool
+	overflow    bool
+	interrupted locked.Boolean
+}
+
+// NewRecorder creates a new Recorder
+func NewRecorder(c net.Conn) *Recorder {
+	return &Recorder{
+		Conn:        c,
+		record:      nil,
+		recording:   true,
+		overflow:    false,
+		interrupted: locked.NewBool(false),
+	}
+}
+
+// Read reads data from the connection, and records it when the
+// Recorder is still recording
+func (r *Recorder) Read(b []byte) (int, error) {
+	if r.interrupted.Get() {
+		return 0, ErrRecorderInterrupted
+	}
+
+	rLen, rErr := r.Conn.Read(b)
+
+	if !r.recording || rLen <= 0 {
+		return rLen, rErr
+	}
+
+	if len(r.record)+rLen > maxRecordSize {
+		r.Stop()
+
+		r.overflow = true
+
+		return rLen, rErr
+	}
+
+	r.record = append(r.record, b[:rLen]...)
+
+	return rLen, rErr
+}
+
+// Interrupt fails current and all following reads. It can be called
+// from another routine to unblock a reader which is waiting for data.
+// Data can still be read from the underlaying Conn directly
+func (r *Recorder) Interrupt() {
+	r.interrupted.Set(true)
+
+	r.Conn.SetReadDeadline(time.Now())
+}
+
+// Stop stops recording and releases recorded data
+func (r *Recorder) Stop() {
+	r.recording = false
+	r.record = nil
+}
+
+// Recorded returns recorded data, and whether or not it contains all
+// data that has been read from the connection
+func (r *Recorder) Recorded() ([]byte, bool) {
+	return r.record, r.recording && !r.overflow
+}
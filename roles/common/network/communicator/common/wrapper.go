@@ -20,10 +20,46 @@
 
 package common
 
-import "net"
+import (
+	"net"
+	"time"
+)
 
 // ConnWrapper is the Conn Wrapper function
 type ConnWrapper func(net.Conn) (net.Conn, error)
 
 // ConnDisrupter is the Conn Disrupt wrapper function
 type ConnDisrupter func(net.Conn) net.Conn
+
+// Key is a named key. A Key with zero Expire never expires
+type Key struct {
+	Name   string
+	Key    []byte
+	Expire time.Time
+}
+
+// Keys is a list of Key
+type Keys []Key
+
+// Active returns keys which are not expired at given time
+func (k Keys) Active(now time.Time) Keys {
+	active := make(Keys, 0, len(k))
+
+	for _, key := range k {
+		if !key.Expire.IsZero() && !now.Before(key.Expire) {
+			continue
+		}
+
+		active = append(active, key)
+	}
+
+	return active
+}
+
+// IdentifiedConn is a conn which knows the name of the key that the
+// remote is using
+type IdentifiedConn interface {
+	net.Conn
+
+	Identity() (string, bool)
+}
@@ -0,0 +1,86 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package wrapper
+
+import (
+	"github.com/nickrio/coward/common/streamer/aead"
+	"github.com/nickrio/coward/roles/common/network/communicator/common"
+)
+
+// AESGCM128 returns a AES-GCM-128 Data wrapper
+func AESGCM128() Wrapper {
+	return Wrapper{
+		Name:    "aes-gcm-128",
+		Wrapper: AESGCM128Wrapper,
+		Keyring: AESGCM128Keyring,
+	}
+}
+
+// AESGCM256 returns a AES-GCM-256 Data wrapper
+func AESGCM256() Wrapper {
+	return Wrapper{
+		Name:    "aes-gcm-256",
+		Wrapper: AESGCM256Wrapper,
+		Keyring: AESGCM256Keyring,
+	}
+}
+
+// ChaCha20Poly1305 returns a ChaCha20-Poly1305 Data wrapper
+func ChaCha20Poly1305() Wrapper {
+	return Wrapper{
+		Name:    "chacha20-poly1305",
+		Wrapper: ChaCha20Poly1305Wrapper,
+		Keyring: ChaCha20Poly1305Keyring,
+	}
+}
+
+// AESGCM128Wrapper returns an AES-GCM-128 conn wrapper
+func AESGCM128Wrapper(key []byte) common.ConnWrapper {
+	return AESGCM128Keyring(common.Keys{{Key: key}})
+}
+
+// AESGCM128Keyring returns an AES-GCM-128 conn wrapper which
+// accepts any of the given keys
+func AESGCM128Keyring(keys common.Keys) common.ConnWrapper {
+	return timedWrapper(keys, 16, aead.NewAESGCM)
+}
+
+// AESGCM256Wrapper returns an AES-GCM-256 conn wrapper
+func AESGCM256Wrapper(key []byte) common.ConnWrapper {
+	return AESGCM256Keyring(common.Keys{{Key: key}})
+}
+
+// AESGCM256Keyring returns an AES-GCM-256 conn wrapper which
+// accepts any of the given keys
+func AESGCM256Keyring(keys common.Keys) common.ConnWrapper {
+	return timedWrapper(keys, 32, aead.NewAESGCM)
+}
+
+// ChaCha20Poly1305Wrapper returns an ChaCha20-Poly1305 conn wrapper
+func ChaCha20Poly1305Wrapper(key []byte) common.ConnWrapper {
+	return ChaCha20Poly1305Keyring(common.Keys{{Key: key}})
+}
+
+// ChaCha20Poly1305Keyring returns an ChaCha20-Poly1305 conn wrapper
+// which accepts any of the given keys
+func ChaCha20Poly1305Keyring(keys common.Keys) common.ConnWrapper {
+	return timedWrapper(keys, 32, aead.NewChaCha20Poly1305)
+}
@@ -21,13 +21,8 @@
 package wrapper
 
 import (
-	"net"
-	"time"
-
 	"github.com/nickrio/coward/common/streamer/aescfb"
-	"github.com/nickrio/coward/roles/common/network"
 	"github.com/nickrio/coward/roles/common/network/communicator/common"
-	"github.com/nickrio/coward/roles/common/network/conn"
 )
 
 // AESCFB128 returns a AES-CFB-128-HMAC Data wrapper
@@ -35,6 +30,7 @@ func AESCFB128() Wrapper {
 	return Wrapper{
 		Name:    "aes-cfb-128-hmac",
 		Wrapper: AESCFB128Wrapper,
+		Keyring: AESCFB128Keyring,
 	}
 }
 
@@ -43,51 +39,28 @@ func AESCFB256() Wrapper {
 	return Wrapper{
 		Name:    "aes-cfb-256-hmac",
 		Wrapper: AESCFB256Wrapper,
+		Keyring: AESCFB256Keyring,
 	}
 }
 
 // AESCFB128Wrapper returns an AES-CFB-128 conn wrapper
 func AESCFB128Wrapper(key []byte) common.ConnWrapper {
-	timedKey := network.TimedKey(key)
-
-	return func(raw net.Conn) (net.Conn, error) {
-		current := time.Now()
-
-		sharedKey, keyErr := timedKey.Get(current, 16)
-
-		if keyErr != nil {
-			return nil, keyErr
-		}
-
-		cipher, cipherErr := aescfb.New(sharedKey)
-
-		if cipherErr != nil {
-			return nil, cipherErr
-		}
+	return AESCFB128Keyring(common.Keys{{Key: key}})
+}
 
-		return conn.NewEncoded(raw, cipher), nil
-	}
+// AESCFB128Keyring returns an AES-CFB-128 conn wrapper which
+// accepts any of the given keys
+func AESCFB128Keyring(keys common.Keys) common.ConnWrapper {
+	return timedWrapper(keys, 16, aescfb.New)
 }
 
 // AESCFB256Wrapper returns an AES-CFB-256 conn wrapper
 func AESCFB256Wrapper(key []byte) common.ConnWrapper {
-	timedKey := network.TimedKey(key)
-
-	return func(raw net.Conn) (net.Conn, error) {
-		current := time.Now()
-
-		sharedKey, keyErr := timedKey.Get(current, 32)
-
-		if keyErr != nil {
-			return nil, keyErr
-		}
-
-		cipher, cipherErr := aescfb.New(sharedKey)
-
-		if cipherErr != nil {
-			return nil, cipherErr
-		}
+	return AESCFB256Keyring(common.Keys{{Key: key}})
+}
 
-		return conn.NewEncoded(raw, cipher), nil
-	}
+// AESCFB256Keyring returns an AES-CFB-256 conn wrapper which
+// accepts any of the given keys
+func AESCFB256Keyring(keys common.Keys) common.ConnWrapper {
+	return timedWrapper(keys, 32, aescfb.New)
 }
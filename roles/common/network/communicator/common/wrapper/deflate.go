@@ -0,0 +1,83 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package wrapper
+
+import (
+	"compress/flate"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/nickrio/coward/roles/common/network/communicator/common"
+	"github.com/nickrio/coward/roles/common/network/conn"
+)
+
+// Deflate returns a deflate compression Pipeline Stage
+func Deflate() Stage {
+	return Stage{
+		Name:  "deflate",
+		Stage: DeflateStage,
+	}
+}
+
+// compressionRatio returns the percentage of compressed size
+// comparing to the original size
+func compressionRatio(original uint64, compressed uint64) float64 {
+	if original <= 0 {
+		return 0
+	}
+
+	return float64(compressed) / float64(original) * 100
+}
+
+// DeflateStage compresses data with deflate. The setting is the
+// compression level from -2 (Huffman only) to 9 (best compression),
+// or empty for the default level
+func DeflateStage(config StageConfig) (common.ConnWrapper, error) {
+	level := flate.DefaultCompression
+
+	if len(config.Setting) > 0 {
+		parsedLevel, parseErr := strconv.ParseInt(
+			string(config.Setting), 10, 64)
+
+		if parseErr != nil ||
+			parsedLevel < flate.HuffmanOnly ||
+			parsedLevel > flate.BestCompression {
+			return nil, fmt.Errorf("Invalid compression level \"%s\"",
+				config.Setting)
+		}
+
+		level = int(parsedLevel)
+	}
+
+	return func(raw net.Conn) (net.Conn, error) {
+		return conn.NewCompressed(raw, level,
+			func(status conn.CompressedStatus) {
+				config.Logger.Debugf("Sent %d bytes as %d bytes (%.1f%%), "+
+					"received %d bytes as %d bytes (%.1f%%)",
+					status.Written, status.WrittenCompressed,
+					compressionRatio(
+						status.Written, status.WrittenCompressed),
+					status.Read, status.ReadCompressed,
+					compressionRatio(status.Read, status.ReadCompressed))
+			})
+	}, nil
+}
@@ -0,0 +1,108 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package wrapper
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/nickrio/coward/common/logger"
+)
+
+func TestDeflateStage(t *testing.T) {
+	stages := Stages{Deflate()}
+	configs := []ConfigStage{{Name: "deflate", Setting: "9"}}
+
+	pipeline, pipelineErr := stages.Pipeline(
+		configs, nil, logger.NewDitch())
+
+	if pipelineErr != nil {
+		t.Error("Failed to build pipeline due to error:", pipelineErr)
+
+		return
+	}
+
+	left, right := net.Pipe()
+
+	defer left.Close()
+	defer right.Close()
+
+	client, clientErr := pipeline(left)
+
+	if clientErr != nil {
+		t.Error("Failed to wrap client due to error:", clientErr)
+
+		return
+	}
+
+	server, serverErr := pipeline(right)
+
+	if serverErr != nil {
+		t.Error("Failed to wrap server due to error:", serverErr)
+
+		return
+	}
+
+	data := bytes.Repeat([]byte("Test data is here"), 1000)
+
+	go client.Write(data)
+
+	readBuf := make([]byte, len(data))
+
+	_, rErr := io.ReadFull(server, readBuf)
+
+	if rErr != nil {
+		t.Error("Can't read due to error:", rErr)
+
+		return
+	}
+
+	if !bytes.Equal(data, readBuf) {
+		t.Error("Data is corrupted")
+
+		return
+	}
+
+	go server.Write([]byte("Reply"))
+
+	_, rErr = io.ReadFull(client, readBuf[:5])
+
+	if rErr != nil || string(readBuf[:5]) != "Reply" {
+		t.Errorf("Expecting reply %q, got %q (%v)",
+			"Reply", readBuf[:5], rErr)
+
+		return
+	}
+
+	for _, level := range []string{"10", "-3", "best"} {
+		_, pipelineErr = stages.Pipeline([]ConfigStage{
+			{Name: "deflate", Setting: level},
+		}, nil, logger.NewDitch())
+
+		if pipelineErr == nil {
+			t.Errorf("Expecting compression level %q to be invalid", level)
+
+			return
+		}
+	}
+}
@@ -21,12 +21,21 @@
 package wrapper
 
 import (
+	"fmt"
 	"net"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/nickrio/coward/roles/common/network/communicator/common"
 	"github.com/nickrio/coward/roles/common/network/conn"
 )
 
+const (
+	// shaperDefaultMax is the default max frame size of shaper
+	shaperDefaultMax = 1400
+)
+
 // Chaotic returns a data Disrupter wrapper
 func Chaotic() Disrupter {
 	return Disrupter{
@@ -35,9 +44,167 @@ func Chaotic() Disrupter {
 	}
 }
 
+// Shaper returns a traffic shaping Disrupter wrapper
+func Shaper() Disrupter {
+	return Disrupter{
+		Name:      "shaper",
+		Disrupter: ShaperWrapper,
+	}
+}
+
 // ChaoticWrapper will randomly spilt data for sending
-func ChaoticWrapper(setting []byte) common.ConnDisrupter {
+func ChaoticWrapper(
+	setting []byte, keys common.Keys) (common.ConnDisrupter, error) {
 	return func(raw net.Conn) net.Conn {
 		return conn.NewChaotic(raw)
+	}, nil
+}
+
+// parseShaperSize parses a frame size in "SIZE" or "SIZE:WEIGHT"
+// format
+func parseShaperSize(setting string) (conn.ShapedSize, error) {
+	size := conn.ShapedSize{
+		Size:   0,
+		Weight: 1,
+	}
+
+	parts := strings.SplitN(setting, ":", 2)
+
+	parsedSize, parseErr := strconv.ParseUint(parts[0], 10, 16)
+
+	if parseErr != nil || parsedSize <= conn.ShapedHeaderSize {
+		return size, fmt.Errorf("Invalid frame size \"%s\", must be "+
+			"a number between %d and %d", parts[0],
+			conn.ShapedHeaderSize+1, 0xffff)
+	}
+
+	size.Size = int(parsedSize)
+
+	if len(parts) < 2 {
+		return size, nil
+	}
+
+	parsedWeight, parseErr := strconv.ParseUint(parts[1], 10, 16)
+
+	if parseErr != nil || parsedWeight <= 0 {
+		return size, fmt.Errorf("Invalid weight \"%s\" of frame size %d",
+			parts[1], size.Size)
+	}
+
+	size.Weight = int(parsedWeight)
+
+	return size, nil
+}
+
+// parseShaperSetting parses the setting of shaper.
+//
+// Setting is a list of KEY=VALUE items separated by ";":
+//
+//	sizes:  Frame size distribution, a list of SIZE:WEIGHT separated
+//	        by ","
+//	min:    Min frame size
+//	max:    Max frame size
+//	jitter: Max random delay before each write
+//
+// For example: "sizes=64:3,576:2,1400:5;min=64;max=1400;jitter=20ms"
+func parseShaperSetting(setting []byte) (conn.ShapedConfig, error) {
+	config := conn.ShapedConfig{
+		Sizes:  nil,
+		Min:    conn.ShapedHeaderSize + 1,
+		Max:    shaperDefaultMax,
+		Jitter: 0,
+	}
+
+	for _, item := range strings.Split(string(setting), ";") {
+		item = strings.TrimSpace(item)
+
+		if len(item) <= 0 {
+			continue
+		}
+
+		keyValue := strings.SplitN(item, "=", 2)
+
+		if len(keyValue) != 2 {
+			return config, fmt.Errorf("Invalid shaper setting \"%s\", "+
+				"must be in KEY=VALUE format", item)
+		}
+
+		key := strings.TrimSpace(keyValue[0])
+		value := strings.TrimSpace(keyValue[1])
+
+		switch key {
+		case "sizes":
+			for _, sizeSetting := range strings.Split(value, ",") {
+				size, sizeErr := parseShaperSize(
+					strings.TrimSpace(sizeSetting))
+
+				if sizeErr != nil {
+					return config, sizeErr
+				}
+
+				config.Sizes = append(config.Sizes, size)
+			}
+
+		case "min", "max":
+			parsedSize, parseErr := parseShaperSize(value)
+
+			if parseErr != nil {
+				return config, parseErr
+			}
+
+			if key == "min" {
+				config.Min = parsedSize.Size
+			} else {
+				config.Max = parsedSize.Size
+			}
+
+		case "jitter":
+			jitter, parseErr := time.ParseDuration(value)
+
+			if parseErr != nil || jitter < 0 {
+				return config, fmt.Errorf("Invalid jitter \"%s\"", value)
+			}
+
+			config.Jitter = jitter
+
+		default:
+			return config, fmt.Errorf("Unknown shaper setting \"%s\"", key)
+		}
 	}
+
+	if config.Min > config.Max {
+		return config, fmt.Errorf("Min frame size %d must not be greater "+
+			"than the max frame size %d", config.Min, config.Max)
+	}
+
+	return config, nil
+}
+
+// ShaperWrapper pads or splits data into frames whose sizes matches
+// the distribution defined in the setting, so the size pattern of the
+// traffic will not be the one of the data being carried. Frame heads
+// are masked with the keys
+func ShaperWrapper(
+	setting []byte, keys common.Keys) (common.ConnDisrupter, error) {
+	config, configErr := parseShaperSetting(setting)
+
+	if configErr != nil {
+		return nil, configErr
+	}
+
+	if len(keys) <= 0 {
+		return nil, conn.ErrShapedNoKey
+	}
+
+	// Expired keys are still accepted here, the Data wrapper will
+	// reject them if it should
+	rawKeys := make([][]byte, len(keys))
+
+	for idx, key := range keys {
+		rawKeys[idx] = key.Key
+	}
+
+	return func(raw net.Conn) net.Conn {
+		return conn.NewShaped(raw, config, rawKeys)
+	}, nil
 }
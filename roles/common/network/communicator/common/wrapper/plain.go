@@ -24,6 +24,7 @@ import (
 	"net"
 
 	"github.com/nickrio/coward/roles/common/network/communicator/common"
+	"github.com/nickrio/coward/roles/common/network/conn"
 )
 
 // Plain returns a Clear-Text Data wrapper
@@ -31,6 +32,7 @@ func Plain() Wrapper {
 	return Wrapper{
 		Name:    "plain",
 		Wrapper: PlainWrapper,
+		Keyring: PlainKeyring,
 	}
 }
 
@@ -40,3 +42,18 @@ func PlainWrapper(key []byte) common.ConnWrapper {
 		return raw, nil
 	}
 }
+
+// PlainKeyring will send data as is. As there is no way to find out
+// which key the remote is using, all remotes will be identified as
+// the first key
+func PlainKeyring(keys common.Keys) common.ConnWrapper {
+	return func(raw net.Conn) (net.Conn, error) {
+		if len(keys) <= 0 {
+			return raw, nil
+		}
+
+		return conn.NewIdentified(raw, func() (string, bool) {
+			return keys[0].Name, true
+		}), nil
+	}
+}
@@ -0,0 +1,153 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package wrapper
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/nickrio/coward/common/logger"
+	"github.com/nickrio/coward/roles/common/network/communicator/common"
+)
+
+// StageConfig is the configuration which will be used to build a
+// Pipeline Stage
+type StageConfig struct {
+	Setting []byte
+	Keys    common.Keys
+	Logger  logger.Logger
+}
+
+// Stage is the Pipeline Stage wrapper
+type Stage struct {
+	Name  string
+	Stage func(config StageConfig) (common.ConnWrapper, error)
+}
+
+// Stages is a list of Stage
+type Stages []Stage
+
+// ConfigStage is the bare configuration of a Pipeline Stage
+type ConfigStage struct {
+	Name    string `json:"name" cfg:"n,-name:Name of the stage"`
+	Setting string `json:"setting" cfg:"s,-setting:Configuration string of the stage"`
+}
+
+// Stage returns a Pipeline Stage which encrypts data with the keys.
+// Setting of the stage will be ignored
+func (w Wrapper) Stage() Stage {
+	return Stage{
+		Name: w.Name,
+		Stage: func(config StageConfig) (common.ConnWrapper, error) {
+			return w.Keyring(config.Keys), nil
+		},
+	}
+}
+
+// Stage returns a Pipeline Stage which disrupts data according to the
+// setting. Keys can be used by the Disrupter to hide it's own data
+func (d Disrupter) Stage() Stage {
+	return Stage{
+		Name: d.Name,
+		Stage: func(config StageConfig) (common.ConnWrapper, error) {
+			disrupter, disrupterErr := d.Disrupter(
+				config.Setting, config.Keys)
+
+			if disrupterErr != nil {
+				return nil, disrupterErr
+			}
+
+			return func(raw net.Conn) (net.Conn, error) {
+				return disrupter(raw), nil
+			}, nil
+		},
+	}
+}
+
+// Names returns names of all stages
+func (s Stages) Names() []string {
+	names := make([]string, len(s))
+
+	for idx, stage := range s {
+		names[idx] = stage.Name
+	}
+
+	return names
+}
+
+// Get returns the stage of given name
+func (s Stages) Get(name string) (Stage, error) {
+	for _, stage := range s {
+		if stage.Name != name {
+			continue
+		}
+
+		return stage, nil
+	}
+
+	return Stage{}, fmt.Errorf("Pipeline Stage \"%s\" is undefined", name)
+}
+
+// Verify checks whether or not all configured stages are defined
+func (s Stages) Verify(configs []ConfigStage) error {
+	for _, config := range configs {
+		_, stageErr := s.Get(config.Name)
+
+		if stageErr != nil {
+			return stageErr
+		}
+	}
+
+	return nil
+}
+
+// Pipeline builds a conn wrapper which wraps the conn with configured
+// stages in order
+func (s Stages) Pipeline(
+	configs []ConfigStage,
+	keys common.Keys,
+	log logger.Logger,
+) (common.ConnWrapper, error) {
+	wrappers := make([]common.ConnWrapper, len(configs))
+
+	for idx, config := range configs {
+		stage, stageErr := s.Get(config.Name)
+
+		if stageErr != nil {
+			return nil, stageErr
+		}
+
+		wrapper, wrapperErr := stage.Stage(StageConfig{
+			Setting: []byte(config.Setting),
+			Keys:    keys,
+			Logger:  log.Context(config.Name),
+		})
+
+		if wrapperErr != nil {
+			return nil, fmt.Errorf("Can't build Pipeline Stage \"%s\": %s",
+				config.Name, wrapperErr)
+		}
+
+		wrappers[idx] = wrapper
+	}
+
+	return common.NewPipeline(wrappers...), nil
+}
@@ -0,0 +1,86 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package wrapper
+
+import (
+	"net"
+	"time"
+
+	"github.com/nickrio/coward/common/streamer/timed"
+	"github.com/nickrio/coward/roles/common/network"
+	"github.com/nickrio/coward/roles/common/network/communicator/common"
+	"github.com/nickrio/coward/roles/common/network/conn"
+)
+
+const (
+	// timedHeadExpireDuration is how long a received head will be
+	// remembered. Heads older than the time tolerance will be rejected
+	// anyway, so no need to remember them for longer
+	timedHeadExpireDuration = 2 * network.KeyTimeTolerance
+)
+
+// rawKeys returns keys which are not expired, and the raw key data
+// of them
+func rawKeys(keys common.Keys) (common.Keys, [][]byte) {
+	active := keys.Active(time.Now())
+	raw := make([][]byte, len(active))
+
+	for idx, key := range active {
+		raw[idx] = key.Key
+	}
+
+	return active, raw
+}
+
+// timedWrapper returns a conn wrapper which encrypts data with the
+// session key derived from one of given keys and the time of the
+// sender. Session heads received by conns of the same wrapper will be
+// remembered, so replayed sessions will be rejected. Expired keys
+// will not be accepted by new conns
+func timedWrapper(
+	keys common.Keys,
+	keySize int,
+	builder timed.Builder,
+) common.ConnWrapper {
+	heads := network.NewHeads(timedHeadExpireDuration)
+
+	return func(raw net.Conn) (net.Conn, error) {
+		activeKeys, activeRawKeys := rawKeys(keys)
+
+		cipher, cipherErr := timed.New(
+			activeRawKeys, keySize, network.KeyTimeTolerance, heads, builder)
+
+		if cipherErr != nil {
+			return nil, cipherErr
+		}
+
+		return conn.NewIdentified(
+			conn.NewEncoded(raw, cipher), func() (string, bool) {
+				selected, isSelected := cipher.Selected()
+
+				if !isSelected {
+					return "", false
+				}
+
+				return activeKeys[selected].Name, true
+			}), nil
+	}
+}
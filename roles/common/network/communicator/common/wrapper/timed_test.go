@@ -0,0 +1,115 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package wrapper
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nickrio/coward/roles/common/network/communicator/common"
+)
+
+func TestTimedKeyringExpire(t *testing.T) {
+	now := time.Now()
+	keyring := AESGCM256Keyring(common.Keys{
+		{Name: "default", Key: []byte("0123456789abcdef0")},
+		{
+			Name:   "previous-1",
+			Key:    []byte("0123456789abcdef1"),
+			Expire: now.Add(-time.Hour),
+		},
+		{
+			Name:   "previous-2",
+			Key:    []byte("0123456789abcdef2"),
+			Expire: now.Add(time.Hour),
+		},
+	})
+
+	tests := []struct {
+		Key      string
+		Identity string
+		Accepted bool
+	}{
+		{"0123456789abcdef0", "default", true},
+		{"0123456789abcdef1", "", false},
+		{"0123456789abcdef2", "previous-2", true},
+		{"0123456789abcdef3", "", false},
+	}
+
+	for _, test := range tests {
+		left, right := net.Pipe()
+
+		client, clientErr := AESGCM256Wrapper([]byte(test.Key))(left)
+
+		if clientErr != nil {
+			t.Error("Failed to wrap client due to error:", clientErr)
+
+			return
+		}
+
+		server, serverErr := keyring(right)
+
+		if serverErr != nil {
+			t.Error("Failed to wrap server due to error:", serverErr)
+
+			return
+		}
+
+		go client.Write([]byte("Hello"))
+
+		right.SetReadDeadline(time.Now().Add(time.Second))
+
+		readBuf := make([]byte, 5)
+
+		_, rErr := io.ReadFull(server, readBuf)
+
+		left.Close()
+		right.Close()
+
+		if !test.Accepted {
+			if rErr == nil {
+				t.Errorf("Expecting key %s to be rejected", test.Key)
+
+				return
+			}
+
+			continue
+		}
+
+		if rErr != nil || string(readBuf) != "Hello" {
+			t.Errorf("Expecting key %s to be accepted, got error %v",
+				test.Key, rErr)
+
+			return
+		}
+
+		identity, identified := server.(common.IdentifiedConn).Identity()
+
+		if !identified || identity != test.Identity {
+			t.Errorf("Expecting key %s to be identified as %s, got %s",
+				test.Key, test.Identity, identity)
+
+			return
+		}
+	}
+}
@@ -22,14 +22,19 @@ package wrapper
 
 import "github.com/nickrio/coward/roles/common/network/communicator/common"
 
-// Disrupter is the Disrupter wrapper
+// Disrupter is the Disrupter wrapper. Disrupter builds a conn
+// disrupter with the setting and the keys, and returns an error when
+// the setting is invalid
 type Disrupter struct {
 	Name      string
-	Disrupter func([]byte) common.ConnDisrupter
+	Disrupter func([]byte, common.Keys) (common.ConnDisrupter, error)
 }
 
-// Wrapper is the Data Wrapper wrapper
+// Wrapper is the Data Wrapper wrapper. Wrapper builds a conn wrapper
+// with a single key, and Keyring builds a conn wrapper which accepts
+// any of the given keys
 type Wrapper struct {
 	Name    string
 	Wrapper func([]byte) common.ConnWrapper
+	Keyring func(common.Keys) common.ConnWrapper
 }
@@ -0,0 +1,108 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package wrapper
+
+import (
+	"io"
+	"net"
+
+	"github.com/nickrio/coward/common/codec"
+	"github.com/nickrio/coward/common/streamer/aead"
+	"github.com/nickrio/coward/common/streamer/x25519"
+	"github.com/nickrio/coward/roles/common/network"
+	"github.com/nickrio/coward/roles/common/network/communicator/common"
+	"github.com/nickrio/coward/roles/common/network/conn"
+)
+
+// X25519AESGCM256 returns a X25519 key exchanged AES-GCM-256 Data
+// wrapper
+func X25519AESGCM256() Wrapper {
+	return Wrapper{
+		Name:    "x25519-aes-gcm-256",
+		Wrapper: X25519AESGCM256Wrapper,
+		Keyring: X25519AESGCM256Keyring,
+	}
+}
+
+// X25519ChaCha20Poly1305 returns a X25519 key exchanged
+// ChaCha20-Poly1305 Data wrapper
+func X25519ChaCha20Poly1305() Wrapper {
+	return Wrapper{
+		Name:    "x25519-chacha20-poly1305",
+		Wrapper: X25519ChaCha20Poly1305Wrapper,
+		Keyring: X25519ChaCha20Poly1305Keyring,
+	}
+}
+
+// X25519AESGCM256Wrapper returns a X25519 key exchanged AES-GCM-256
+// conn wrapper
+func X25519AESGCM256Wrapper(key []byte) common.ConnWrapper {
+	return X25519AESGCM256Keyring(common.Keys{{Key: key}})
+}
+
+// X25519AESGCM256Keyring returns a X25519 key exchanged AES-GCM-256
+// conn wrapper which accepts any of the given keys
+func X25519AESGCM256Keyring(keys common.Keys) common.ConnWrapper {
+	return x25519Wrapper(keys, 32, aead.NewAESGCM)
+}
+
+// X25519ChaCha20Poly1305Wrapper returns a X25519 key exchanged
+// ChaCha20-Poly1305 conn wrapper
+func X25519ChaCha20Poly1305Wrapper(key []byte) common.ConnWrapper {
+	return X25519ChaCha20Poly1305Keyring(common.Keys{{Key: key}})
+}
+
+// X25519ChaCha20Poly1305Keyring returns a X25519 key exchanged
+// ChaCha20-Poly1305
+// conn wrapper which accepts any of the given keys
+func X25519ChaCha20Poly1305Keyring(keys common.Keys) common.ConnWrapper {
+	return x25519Wrapper(keys, 32, aead.NewChaCha20Poly1305)
+}
+
+// x25519Wrapper returns a conn wrapper which exchanges ephemeral
+// X25519 keys authenticated by one of the given keys, and encrypts
+// data with the exchanged traffic keys. Hellos received by conns of the
+// same wrapper will be remembered, so replayed hellos will be rejected.
+// Expired keys will not be accepted by new conns
+func x25519Wrapper(
+	keys common.Keys,
+	keySize int,
+	builder x25519.Builder,
+) common.ConnWrapper {
+	hellos := network.NewHeads(timedHeadExpireDuration)
+
+	return func(raw net.Conn) (net.Conn, error) {
+		activeKeys, activeRawKeys := rawKeys(keys)
+		exchanger := x25519.New(activeRawKeys, keySize,
+			network.KeyTimeTolerance, hellos, builder)
+
+		return conn.NewExchanged(raw, func(rw io.ReadWriter) (
+			codec.Streamer, codec.Streamer, string, error) {
+			reader, writer, selected, exchangeErr := exchanger.Exchange(rw)
+
+			if exchangeErr != nil {
+				return nil, nil, "", exchangeErr
+			}
+
+			return reader, writer, activeKeys[selected].Name, nil
+		}), nil
+	}
+}
@@ -0,0 +1,186 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package datagram
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nickrio/coward/roles/common/network/transporter"
+)
+
+const (
+	// announceInterval is how long the client will wait for the
+	// first packet from the server before announcing it again
+	announceInterval = 300 * time.Millisecond
+
+	// maxAnnounces is how many announces at most will be sent
+	maxAnnounces = 10
+)
+
+// client is the client side of a datagram session
+type client struct {
+	conn         net.Conn
+	codec        *codec
+	readBuf      [MaxPacketSize]byte
+	writeBuf     [MaxPacketSize]byte
+	writeLock    sync.Mutex
+	received     chan struct{}
+	receivedOnce sync.Once
+	closed       chan struct{}
+	closeOnce    sync.Once
+}
+
+// NewDialer creates a Transporter DatagramDialer which dials to
+// given port of the host
+func NewDialer(
+	host string,
+	connectTimeout time.Duration,
+) transporter.DatagramDialer {
+	return func(port uint16) (net.Conn, error) {
+		return net.DialTimeout("udp", net.JoinHostPort(
+			host, strconv.FormatUint(uint64(port), 10)), connectTimeout)
+	}
+}
+
+// NewClient creates the client side of a datagram session on top of
+// a connected UDP connection. An empty packet will be sent right away
+// so the server can learn where to send packets to, and it will be
+// sent again from time to time until the first packet from the server
+// is received, in case it has been lost
+func NewClient(conn net.Conn, session Session) (io.ReadWriteCloser, error) {
+	cdc, cdcErr := newCodec(session, true)
+
+	if cdcErr != nil {
+		return nil, cdcErr
+	}
+
+	c := &client{
+		conn:         conn,
+		codec:        cdc,
+		readBuf:      [MaxPacketSize]byte{},
+		writeBuf:     [MaxPacketSize]byte{},
+		writeLock:    sync.Mutex{},
+		received:     make(chan struct{}),
+		receivedOnce: sync.Once{},
+		closed:       make(chan struct{}),
+		closeOnce:    sync.Once{},
+	}
+
+	_, wErr := c.Write(nil)
+
+	if wErr != nil {
+		return nil, wErr
+	}
+
+	go c.announce()
+
+	return c, nil
+}
+
+// announce resends the empty packet until a packet is received from
+// the server, or the client is closed
+func (c *client) announce() {
+	ticker := time.NewTicker(announceInterval)
+
+	defer ticker.Stop()
+
+	for announced := 1; announced < maxAnnounces; announced++ {
+		select {
+		case <-ticker.C:
+			_, wErr := c.Write(nil)
+
+			if wErr != nil {
+				return
+			}
+
+		case <-c.received:
+			return
+
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// Read reads data of the next valid packet. Packets which failed to
+// be authenticated will be dropped silently
+func (c *client) Read(b []byte) (int, error) {
+	for {
+		rLen, rErr := c.conn.Read(c.readBuf[:])
+
+		if rErr != nil {
+			return 0, rErr
+		}
+
+		data, openErr := c.codec.open(c.readBuf[:rLen])
+
+		if openErr != nil {
+			continue
+		}
+
+		c.receivedOnce.Do(func() {
+			close(c.received)
+		})
+
+		if len(data) <= 0 {
+			continue
+		}
+
+		if len(data) > len(b) {
+			return 0, ErrPacketBufferTooSmall
+		}
+
+		return copy(b, data), nil
+	}
+}
+
+// Write sends b as one packet
+func (c *client) Write(b []byte) (int, error) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	packet, sealErr := c.codec.seal(c.writeBuf[:], b)
+
+	if sealErr != nil {
+		return 0, sealErr
+	}
+
+	_, wErr := c.conn.Write(packet)
+
+	if wErr != nil {
+		return 0, wErr
+	}
+
+	return len(b), nil
+}
+
+// Close closes the underlying UDP connection
+func (c *client) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+
+	return c.conn.Close()
+}
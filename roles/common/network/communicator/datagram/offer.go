@@ -0,0 +1,107 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package datagram
+
+import (
+	"errors"
+
+	"github.com/nickrio/coward/common/types"
+)
+
+// Offer errors
+var (
+	ErrOfferDataTooShort = errors.New(
+		"Offer data is too short")
+)
+
+const (
+	// OfferSize is the size of an encoded Offer
+	OfferSize = 2 + SessionSize
+)
+
+// Request is the data of a RelayUDP request which asks the server to
+// relay packets through a datagram session
+var Request = []byte{1}
+
+// Offer is the reply of a datagram session request. It tells the
+// client which port of the server the packets should be sent to and
+// the Session which will be used to seal them
+//
+// Offer format:
+//
+// +------+------------+-----+
+// | PORT | SESSION ID | KEY |
+// +------+------------+-----+
+// |  2   |     8      | 32  |
+// +------+------------+-----+
+type Offer struct {
+	Port    uint16
+	Session Session
+}
+
+// DecodeOffer decodes an Offer from bytes
+func DecodeOffer(b []byte) (Offer, error) {
+	port := types.EncodableUint16(0)
+
+	if len(b) < OfferSize {
+		return Offer{}, ErrOfferDataTooShort
+	}
+
+	decodeErr := port.DecodeBytes(b[:2])
+
+	if decodeErr != nil {
+		return Offer{}, decodeErr
+	}
+
+	session, sessionErr := DecodeSession(b[2:])
+
+	if sessionErr != nil {
+		return Offer{}, sessionErr
+	}
+
+	return Offer{
+		Port:    uint16(port),
+		Session: session,
+	}, nil
+}
+
+// Encode writes Offer into b
+func (o Offer) Encode(b []byte) (int, error) {
+	if len(b) < OfferSize {
+		return 0, ErrOfferDataTooShort
+	}
+
+	port := types.EncodableUint16(o.Port)
+
+	encodeErr := port.EncodeBytes(b[:2])
+
+	if encodeErr != nil {
+		return 0, encodeErr
+	}
+
+	sLen, sErr := o.Session.Encode(b[2:])
+
+	if sErr != nil {
+		return 0, sErr
+	}
+
+	return 2 + sLen, nil
+}
@@ -0,0 +1,402 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package datagram
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// Server errors
+var (
+	ErrServerNotListening = errors.New(
+		"Datagram server is not listening")
+
+	ErrServerAlreadyListening = errors.New(
+		"Datagram server is already listening")
+
+	ErrSessionAlreadyOpened = errors.New(
+		"Datagram session is already opened")
+)
+
+const (
+	// sessionQueueSize is how many received packets can be waiting
+	// to be read for each session before new ones got dropped
+	sessionQueueSize = 64
+)
+
+// Server receives datagram packets and dispatch them to their
+// sessions.
+//
+// As the session ID is masked, the session of a packet is looked up
+// by the address which the packet came from first. Only packets from
+// an unknown address will be matched against all sessions
+type Server struct {
+	iface    net.IP
+	port     uint16
+	conn     *net.UDPConn
+	sessions map[[SessionIDSize]byte]*serverSession
+	remotes  map[string]*serverSession
+	lock     sync.RWMutex
+	wait     sync.WaitGroup
+}
+
+// serverSession is the server side of a datagram session
+type serverSession struct {
+	server    *Server
+	codec     *codec
+	remote    *net.UDPAddr
+	remoteMtx sync.Mutex
+	packets   chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+	writeBuf  [MaxPacketSize]byte
+	writeLock sync.Mutex
+}
+
+// NewServer creates a new datagram Server
+func NewServer(iface net.IP, port uint16) *Server {
+	return &Server{
+		iface:    iface,
+		port:     port,
+		conn:     nil,
+		sessions: map[[SessionIDSize]byte]*serverSession{},
+		remotes:  map[string]*serverSession{},
+		lock:     sync.RWMutex{},
+		wait:     sync.WaitGroup{},
+	}
+}
+
+// Listen starts listening and receiving packets
+func (s *Server) Listen() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.conn != nil {
+		return ErrServerAlreadyListening
+	}
+
+	conn, listenErr := net.ListenUDP("udp", &net.UDPAddr{
+		IP:   s.iface,
+		Port: int(s.port),
+	})
+
+	if listenErr != nil {
+		return listenErr
+	}
+
+	s.conn = conn
+
+	s.wait.Add(1)
+
+	go func() {
+		defer s.wait.Done()
+
+		s.receive(conn)
+	}()
+
+	return nil
+}
+
+// Addr returns the address which the server is listening on
+func (s *Server) Addr() net.Addr {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.conn == nil {
+		return nil
+	}
+
+	return s.conn.LocalAddr()
+}
+
+// Port returns the port which the server is listening on
+func (s *Server) Port() uint16 {
+	addr, isUDPAddr := s.Addr().(*net.UDPAddr)
+
+	if !isUDPAddr {
+		return 0
+	}
+
+	return uint16(addr.Port)
+}
+
+// Close stops the server and closes all opened sessions
+func (s *Server) Close() error {
+	s.lock.Lock()
+
+	if s.conn == nil {
+		s.lock.Unlock()
+
+		return ErrServerNotListening
+	}
+
+	closeErr := s.conn.Close()
+
+	s.conn = nil
+
+	sessions := make([]*serverSession, 0, len(s.sessions))
+
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+
+	s.lock.Unlock()
+
+	for _, session := range sessions {
+		session.Close()
+	}
+
+	s.wait.Wait()
+
+	return closeErr
+}
+
+// Open opens a new datagram session on the server
+func (s *Server) Open(session Session) (io.ReadWriteCloser, error) {
+	cdc, cdcErr := newCodec(session, false)
+
+	if cdcErr != nil {
+		return nil, cdcErr
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.conn == nil {
+		return nil, ErrServerNotListening
+	}
+
+	_, found := s.sessions[session.ID]
+
+	if found {
+		return nil, ErrSessionAlreadyOpened
+	}
+
+	ss := &serverSession{
+		server:    s,
+		codec:     cdc,
+		remote:    nil,
+		remoteMtx: sync.Mutex{},
+		packets:   make(chan []byte, sessionQueueSize),
+		closed:    make(chan struct{}),
+		closeOnce: sync.Once{},
+		writeBuf:  [MaxPacketSize]byte{},
+		writeLock: sync.Mutex{},
+	}
+
+	s.sessions[session.ID] = ss
+
+	return ss, nil
+}
+
+// receive reads packets from conn and dispatch them until conn
+// is closed
+func (s *Server) receive(conn *net.UDPConn) {
+	buf := [MaxPacketSize]byte{}
+
+	for {
+		rLen, rAddr, rErr := conn.ReadFromUDP(buf[:])
+
+		if rErr != nil {
+			netErr, isNetErr := rErr.(net.Error)
+
+			if isNetErr && netErr.Temporary() {
+				continue
+			}
+
+			return
+		}
+
+		if rLen < Overhead {
+			continue
+		}
+
+		s.lock.RLock()
+		session := s.find(buf[:rLen], rAddr)
+		s.lock.RUnlock()
+
+		if session == nil {
+			continue
+		}
+
+		session.deliver(buf[:rLen], rAddr)
+	}
+}
+
+// find finds the session which the packet is sent to. It must be
+// called with the lock held
+func (s *Server) find(packet []byte, addr *net.UDPAddr) *serverSession {
+	session, found := s.remotes[addr.String()]
+
+	if found && session.codec.match(packet) {
+		return session
+	}
+
+	for _, session := range s.sessions {
+		if !session.codec.match(packet) {
+			continue
+		}
+
+		return session
+	}
+
+	return nil
+}
+
+// bind makes addr the address of the session
+func (s *Server) bind(
+	session *serverSession, previous *net.UDPAddr, addr *net.UDPAddr) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, found := s.sessions[session.codec.id]; !found {
+		return
+	}
+
+	if previous != nil && s.remotes[previous.String()] == session {
+		delete(s.remotes, previous.String())
+	}
+
+	s.remotes[addr.String()] = session
+}
+
+// remove removes a session and it's addresses from the server
+func (s *Server) remove(session *serverSession) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.sessions, session.codec.id)
+
+	for addr, bound := range s.remotes {
+		if bound != session {
+			continue
+		}
+
+		delete(s.remotes, addr)
+	}
+}
+
+// send sends a packet to addr
+func (s *Server) send(packet []byte, addr *net.UDPAddr) error {
+	s.lock.RLock()
+	conn := s.conn
+	s.lock.RUnlock()
+
+	if conn == nil {
+		return ErrServerNotListening
+	}
+
+	_, wErr := conn.WriteToUDP(packet, addr)
+
+	return wErr
+}
+
+// deliver authenticates the packet and queues it's data for reading.
+// The sender of the latest valid packet will receive packets sent
+// by the session
+func (s *serverSession) deliver(packet []byte, addr *net.UDPAddr) {
+	data, openErr := s.codec.open(packet)
+
+	if openErr != nil {
+		return
+	}
+
+	s.remoteMtx.Lock()
+	previous := s.remote
+	s.remote = addr
+	s.remoteMtx.Unlock()
+
+	if previous == nil || previous.String() != addr.String() {
+		s.server.bind(s, previous, addr)
+	}
+
+	// Empty packets are only used to tell us the client address
+	if len(data) <= 0 {
+		return
+	}
+
+	queued := make([]byte, len(data))
+
+	copy(queued, data)
+
+	select {
+	case s.packets <- queued:
+	default:
+	}
+}
+
+// Read reads data of the next received packet
+func (s *serverSession) Read(b []byte) (int, error) {
+	select {
+	case data := <-s.packets:
+		if len(data) > len(b) {
+			return 0, ErrPacketBufferTooSmall
+		}
+
+		return copy(b, data), nil
+
+	case <-s.closed:
+		return 0, io.EOF
+	}
+}
+
+// Write sends b as one packet to the client. b will be dropped
+// when the client address is still unknown
+func (s *serverSession) Write(b []byte) (int, error) {
+	s.remoteMtx.Lock()
+	remote := s.remote
+	s.remoteMtx.Unlock()
+
+	if remote == nil {
+		return len(b), nil
+	}
+
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	packet, sealErr := s.codec.seal(s.writeBuf[:], b)
+
+	if sealErr != nil {
+		return 0, sealErr
+	}
+
+	sendErr := s.server.send(packet, remote)
+
+	if sendErr != nil {
+		return 0, sendErr
+	}
+
+	return len(b), nil
+}
+
+// Close closes the session
+func (s *serverSession) Close() error {
+	s.closeOnce.Do(func() {
+		s.server.remove(s)
+
+		close(s.closed)
+	})
+
+	return nil
+}
@@ -0,0 +1,369 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package datagram
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Session errors
+var (
+	ErrSessionDataTooShort = errors.New(
+		"Session data is too short")
+
+	ErrPacketTooShort = errors.New(
+		"Datagram packet is too short")
+
+	ErrPacketSessionMismatch = errors.New(
+		"Datagram packet belongs to another session")
+
+	ErrPacketReplayed = errors.New(
+		"Datagram packet has been received before")
+
+	ErrPacketBufferTooSmall = errors.New(
+		"Buffer is too small for the datagram packet")
+)
+
+const (
+	// SessionIDSize is the size of a session ID
+	SessionIDSize = 8
+
+	// KeySize is the size of a session key
+	KeySize = 32
+
+	// SessionSize is the size of an encoded Session
+	SessionSize = SessionIDSize + KeySize
+
+	// nonceSize is the size of the GCM nonce
+	nonceSize = 12
+
+	// tagSize is the size of the GCM authentication tag
+	tagSize = 16
+
+	// headerSize is the size of the masked packet header
+	headerSize = SessionIDSize + nonceSize
+
+	// sampleSize is the size of the sealed data sample which the
+	// header mask is generated from
+	sampleSize = aes.BlockSize
+
+	// Overhead is how many bytes a packet is larger than the data
+	// it carries
+	Overhead = headerSize + tagSize
+
+	// MaxPacketSize is the max size of a datagram packet
+	MaxPacketSize = 65507
+
+	// replayWindowSize is how many recent packets will be remembered
+	// for replay detection
+	replayWindowSize = 64
+)
+
+// headerMaskLabel is the HKDF info of the header mask key
+var headerMaskLabel = []byte("datagram header mask")
+
+// Packet directions, put into the nonce so a packet can't be reflected
+// back to it's sender
+const (
+	clientToServer byte = 0
+	serverToClient byte = 1
+)
+
+// Session is the ID and the key of a datagram session. It's generated
+// by the server and sent to the client through an authenticated
+// Transporter connection
+type Session struct {
+	ID  [SessionIDSize]byte
+	Key [KeySize]byte
+}
+
+// NewSession generates a new random Session
+func NewSession() (Session, error) {
+	s := Session{}
+
+	_, rErr := io.ReadFull(rand.Reader, s.ID[:])
+
+	if rErr != nil {
+		return Session{}, rErr
+	}
+
+	_, rErr = io.ReadFull(rand.Reader, s.Key[:])
+
+	if rErr != nil {
+		return Session{}, rErr
+	}
+
+	return s, nil
+}
+
+// DecodeSession decodes a Session from bytes
+func DecodeSession(b []byte) (Session, error) {
+	s := Session{}
+
+	if len(b) < SessionSize {
+		return Session{}, ErrSessionDataTooShort
+	}
+
+	copy(s.ID[:], b[:SessionIDSize])
+	copy(s.Key[:], b[SessionIDSize:SessionSize])
+
+	return s, nil
+}
+
+// Encode writes Session into b
+func (s Session) Encode(b []byte) (int, error) {
+	if len(b) < SessionSize {
+		return 0, ErrSessionDataTooShort
+	}
+
+	copy(b[:SessionIDSize], s.ID[:])
+	copy(b[SessionIDSize:SessionSize], s.Key[:])
+
+	return SessionSize, nil
+}
+
+// replayWindow remembers counters of recently received packets
+type replayWindow struct {
+	highest uint64
+	bitmap  uint64
+}
+
+// accept returns true when the counter has never been seen before
+// and marks it as seen
+func (w *replayWindow) accept(counter uint64) bool {
+	// Counter 0 is never used
+	if counter == 0 {
+		return false
+	}
+
+	if counter > w.highest {
+		shift := counter - w.highest
+
+		if shift >= replayWindowSize {
+			w.bitmap = 0
+		} else {
+			w.bitmap <<= shift
+		}
+
+		w.bitmap |= 1
+		w.highest = counter
+
+		return true
+	}
+
+	distance := w.highest - counter
+
+	if distance >= replayWindowSize {
+		return false
+	}
+
+	if w.bitmap&(1<<distance) != 0 {
+		return false
+	}
+
+	w.bitmap |= 1 << distance
+
+	return true
+}
+
+// codec seals and opens packets of a session.
+//
+// Packet format:
+//
+// +------------+-------+----------+--------------------+
+// | SESSION ID | NONCE |   DATA   | AUTHENTICATION TAG |
+// +------------+-------+----------+--------------------+
+// |     8      |  12   | Variable |         16         |
+// +------------+-------+----------+--------------------+
+//
+// The NONCE is the packet direction followed by a per-direction
+// counter. SESSION ID is authenticated as additional data.
+//
+// SESSION ID and NONCE are masked with AES-CTR, using a key derived
+// from the session key and the first 16 bytes of the sealed data as
+// IV, so they look different on every packet
+type codec struct {
+	id          [SessionIDSize]byte
+	aead        cipher.AEAD
+	mask        cipher.Block
+	sending     byte
+	receiving   byte
+	sendCounter uint64
+	window      replayWindow
+}
+
+// newCodec creates a new codec for a session
+func newCodec(session Session, client bool) (*codec, error) {
+	block, blockErr := aes.NewCipher(session.Key[:])
+
+	if blockErr != nil {
+		return nil, blockErr
+	}
+
+	aead, aeadErr := cipher.NewGCM(block)
+
+	if aeadErr != nil {
+		return nil, aeadErr
+	}
+
+	maskKey := [KeySize]byte{}
+
+	_, rErr := io.ReadFull(hkdf.New(
+		sha256.New, session.Key[:], nil, headerMaskLabel), maskKey[:])
+
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	mask, maskErr := aes.NewCipher(maskKey[:])
+
+	if maskErr != nil {
+		return nil, maskErr
+	}
+
+	c := &codec{
+		id:          session.ID,
+		aead:        aead,
+		mask:        mask,
+		sending:     serverToClient,
+		receiving:   clientToServer,
+		sendCounter: 0,
+		window:      replayWindow{},
+	}
+
+	if client {
+		c.sending, c.receiving = c.receiving, c.sending
+	}
+
+	return c, nil
+}
+
+// maskHeader masks or unmasks the header with the mask generated from
+// the sealed data of the packet
+func (c *codec) maskHeader(header []byte, packet []byte) {
+	cipher.NewCTR(c.mask, packet[headerSize:headerSize+sampleSize]).
+		XORKeyStream(header, header)
+}
+
+// header returns the unmasked header of the packet, and whether or
+// not the packet is sent to current codec
+func (c *codec) header(packet []byte) ([headerSize]byte, bool) {
+	header := [headerSize]byte{}
+
+	if len(packet) < Overhead {
+		return header, false
+	}
+
+	copy(header[:], packet[:headerSize])
+
+	c.maskHeader(header[:], packet)
+
+	if string(header[:SessionIDSize]) != string(c.id[:]) {
+		return header, false
+	}
+
+	nonce := header[SessionIDSize:]
+
+	if nonce[0] != c.receiving || nonce[1] != 0 ||
+		nonce[2] != 0 || nonce[3] != 0 {
+		return header, false
+	}
+
+	return header, true
+}
+
+// match returns whether or not the packet is sent to current codec.
+// The packet will not be authenticated
+func (c *codec) match(packet []byte) bool {
+	_, matched := c.header(packet)
+
+	return matched
+}
+
+// seal builds a packet which carries data into buf. It must not be
+// called concurrently
+func (c *codec) seal(buf []byte, data []byte) ([]byte, error) {
+	packetLen := len(data) + Overhead
+
+	if packetLen > len(buf) || packetLen > MaxPacketSize {
+		return nil, ErrPacketBufferTooSmall
+	}
+
+	copy(buf[:SessionIDSize], c.id[:])
+
+	nonce := buf[SessionIDSize:headerSize]
+
+	nonce[0] = c.sending
+	nonce[1] = 0
+	nonce[2] = 0
+	nonce[3] = 0
+
+	c.sendCounter++
+
+	binary.BigEndian.PutUint64(nonce[4:], c.sendCounter)
+
+	sealed := c.aead.Seal(buf[:headerSize], nonce, data,
+		buf[:SessionIDSize])
+
+	c.maskHeader(sealed[:headerSize], sealed)
+
+	return sealed, nil
+}
+
+// open authenticates and decrypts a packet in place, then returns
+// the data it carries. It must not be called concurrently
+func (c *codec) open(packet []byte) ([]byte, error) {
+	if len(packet) < Overhead {
+		return nil, ErrPacketTooShort
+	}
+
+	header, matched := c.header(packet)
+
+	if !matched {
+		return nil, ErrPacketSessionMismatch
+	}
+
+	nonce := header[SessionIDSize:]
+	counter := binary.BigEndian.Uint64(nonce[4:])
+
+	sealed := packet[headerSize:]
+
+	data, openErr := c.aead.Open(sealed[:0], nonce, sealed,
+		header[:SessionIDSize])
+
+	if openErr != nil {
+		return nil, openErr
+	}
+
+	if !c.window.accept(counter) {
+		return nil, ErrPacketReplayed
+	}
+
+	return data, nil
+}
@@ -0,0 +1,578 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package datagram
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testMustNewSession() Session {
+	session, sessionErr := NewSession()
+
+	if sessionErr != nil {
+		panic(sessionErr)
+	}
+
+	return session
+}
+
+func testMustNewCodec(session Session, client bool) *codec {
+	cdc, cdcErr := newCodec(session, client)
+
+	if cdcErr != nil {
+		panic(cdcErr)
+	}
+
+	return cdc
+}
+
+func TestCodecSealOpen(t *testing.T) {
+	session := testMustNewSession()
+	client := testMustNewCodec(session, true)
+	server := testMustNewCodec(session, false)
+	buf := [MaxPacketSize]byte{}
+
+	packet, sealErr := client.seal(buf[:], []byte("Hello World"))
+
+	if sealErr != nil {
+		t.Error("Failed to seal due to error:", sealErr)
+
+		return
+	}
+
+	if len(packet) != len("Hello World")+Overhead {
+		t.Errorf("Expecting packet length will be %d, got %d",
+			len("Hello World")+Overhead, len(packet))
+
+		return
+	}
+
+	received := make([]byte, len(packet))
+
+	copy(received, packet)
+
+	data, openErr := server.open(received)
+
+	if openErr != nil {
+		t.Error("Failed to open due to error:", openErr)
+
+		return
+	}
+
+	if !bytes.Equal(data, []byte("Hello World")) {
+		t.Errorf("Expecting data will be %v, got %v",
+			[]byte("Hello World"), data)
+
+		return
+	}
+
+	// Replayed
+	copy(received, packet)
+
+	_, openErr = server.open(received)
+
+	if openErr != ErrPacketReplayed {
+		t.Errorf("Expecting error %s, got %v", ErrPacketReplayed, openErr)
+
+		return
+	}
+
+	// Reflected back to the sender
+	packet, _ = server.seal(buf[:], []byte("Hello World"))
+
+	_, openErr = server.open(packet)
+
+	if openErr == nil {
+		t.Error("Expecting reflected packet will be rejected")
+
+		return
+	}
+
+	// Tampered
+	packet, _ = client.seal(buf[:], []byte("Hello World"))
+
+	packet[len(packet)-1] ^= 1
+
+	_, openErr = server.open(packet)
+
+	if openErr == nil {
+		t.Error("Expecting tampered packet will be rejected")
+
+		return
+	}
+}
+
+func TestCodecHeaderMasked(t *testing.T) {
+	session := testMustNewSession()
+	client := testMustNewCodec(session, true)
+	buf := [MaxPacketSize]byte{}
+
+	first, _ := client.seal(buf[:], []byte("Hello World"))
+	firstHeader := make([]byte, headerSize)
+
+	copy(firstHeader, first[:headerSize])
+
+	second, _ := client.seal(buf[:], []byte("Hello World"))
+
+	if bytes.Equal(firstHeader, second[:headerSize]) {
+		t.Error("Expecting headers of two packets will be different")
+
+		return
+	}
+
+	if bytes.Contains(firstHeader, session.ID[:]) ||
+		bytes.Contains(second[:headerSize], session.ID[:]) {
+		t.Error("Expecting session ID will not be sent in the clear")
+
+		return
+	}
+
+	if testMustNewCodec(testMustNewSession(), false).match(second) {
+		t.Error("Expecting packet will not match another session")
+
+		return
+	}
+
+	if !testMustNewCodec(session, false).match(second) {
+		t.Error("Expecting packet will match it's session")
+
+		return
+	}
+}
+
+func TestReplayWindow(t *testing.T) {
+	w := replayWindow{}
+
+	for _, test := range []struct {
+		counter  uint64
+		accepted bool
+	}{
+		{0, false},
+		{1, true},
+		{3, true},
+		{2, true},
+		{3, false},
+		{100, true},
+		{36, false},
+		{37, true},
+		{37, false},
+		{99, true},
+		{1000, true},
+		{100, false},
+	} {
+		if w.accept(test.counter) != test.accepted {
+			t.Errorf("Expecting counter %d accepted will be %v",
+				test.counter, test.accepted)
+
+			return
+		}
+	}
+}
+
+func TestServerClient(t *testing.T) {
+	server := NewServer(net.ParseIP("127.0.0.1"), 0)
+
+	listenErr := server.Listen()
+
+	if listenErr != nil {
+		t.Error("Failed to listen due to error:", listenErr)
+
+		return
+	}
+
+	defer server.Close()
+
+	session := testMustNewSession()
+
+	serverSession, openErr := server.Open(session)
+
+	if openErr != nil {
+		t.Error("Failed to open session due to error:", openErr)
+
+		return
+	}
+
+	defer serverSession.Close()
+
+	conn, dialErr := NewDialer("127.0.0.1", time.Second)(server.Port())
+
+	if dialErr != nil {
+		t.Error("Failed to dial due to error:", dialErr)
+
+		return
+	}
+
+	client, clientErr := NewClient(conn, session)
+
+	if clientErr != nil {
+		t.Error("Failed to create client due to error:", clientErr)
+
+		return
+	}
+
+	defer client.Close()
+
+	// Packet which belongs to no session will be ignored
+	conn.Write(make([]byte, Overhead+10))
+
+	_, wErr := client.Write([]byte("Hello Server"))
+
+	if wErr != nil {
+		t.Error("Failed to write due to error:", wErr)
+
+		return
+	}
+
+	buf := [MaxPacketSize]byte{}
+
+	rLen, rErr := serverSession.Read(buf[:])
+
+	if rErr != nil {
+		t.Error("Failed to read due to error:", rErr)
+
+		return
+	}
+
+	if string(buf[:rLen]) != "Hello Server" {
+		t.Errorf("Expecting server will read %s, got %s",
+			"Hello Server", buf[:rLen])
+
+		return
+	}
+
+	_, wErr = serverSession.Write([]byte("Hello Client"))
+
+	if wErr != nil {
+		t.Error("Failed to write due to error:", wErr)
+
+		return
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	rLen, rErr = client.Read(buf[:])
+
+	if rErr != nil {
+		t.Error("Failed to read due to error:", rErr)
+
+		return
+	}
+
+	if string(buf[:rLen]) != "Hello Client" {
+		t.Errorf("Expecting client will read %s, got %s",
+			"Hello Client", buf[:rLen])
+
+		return
+	}
+}
+
+func TestServerSessions(t *testing.T) {
+	server := NewServer(net.ParseIP("127.0.0.1"), 0)
+
+	listenErr := server.Listen()
+
+	if listenErr != nil {
+		t.Error("Failed to listen due to error:", listenErr)
+
+		return
+	}
+
+	defer server.Close()
+
+	sessions := []Session{testMustNewSession(), testMustNewSession()}
+	serverSessions := make([]io.ReadWriteCloser, len(sessions))
+
+	for idx := range sessions {
+		serverSession, openErr := server.Open(sessions[idx])
+
+		if openErr != nil {
+			t.Error("Failed to open session due to error:", openErr)
+
+			return
+		}
+
+		defer serverSession.Close()
+
+		serverSessions[idx] = serverSession
+	}
+
+	clients := make([]io.ReadWriteCloser, len(sessions))
+
+	// Clients are dialed from different addresses, the first packets
+	// of them will be found by matching against all sessions
+	for idx := range sessions {
+		conn, dialErr := NewDialer("127.0.0.1", time.Second)(server.Port())
+
+		if dialErr != nil {
+			t.Error("Failed to dial due to error:", dialErr)
+
+			return
+		}
+
+		client, clientErr := NewClient(conn, sessions[idx])
+
+		if clientErr != nil {
+			t.Error("Failed to create client due to error:", clientErr)
+
+			return
+		}
+
+		defer client.Close()
+
+		clients[idx] = client
+	}
+
+	buf := [MaxPacketSize]byte{}
+
+	for round := 0; round < 2; round++ {
+		for idx := len(sessions) - 1; idx >= 0; idx-- {
+			_, wErr := clients[idx].Write([]byte{byte(round), byte(idx)})
+
+			if wErr != nil {
+				t.Error("Failed to write due to error:", wErr)
+
+				return
+			}
+
+			rLen, rErr := serverSessions[idx].Read(buf[:])
+
+			if rErr != nil {
+				t.Error("Failed to read due to error:", rErr)
+
+				return
+			}
+
+			if !bytes.Equal(buf[:rLen], []byte{byte(round), byte(idx)}) {
+				t.Errorf("Expecting session %d will read %v, got %v",
+					idx, []byte{byte(round), byte(idx)}, buf[:rLen])
+
+				return
+			}
+		}
+	}
+}
+
+// testLossyRelay relays packets between a client and the server, the
+// first packet from the client will be dropped
+type testLossyRelay struct {
+	conn     *net.UDPConn
+	upstream *net.UDPConn
+	client   *net.UDPAddr
+	lock     sync.Mutex
+	relayed  int
+}
+
+func testMustNewLossyRelay(port uint16) *testLossyRelay {
+	conn, listenErr := net.ListenUDP("udp", &net.UDPAddr{
+		IP:   net.ParseIP("127.0.0.1"),
+		Port: 0,
+	})
+
+	if listenErr != nil {
+		panic(listenErr)
+	}
+
+	upstream, dialErr := net.DialUDP("udp", nil, &net.UDPAddr{
+		IP:   net.ParseIP("127.0.0.1"),
+		Port: int(port),
+	})
+
+	if dialErr != nil {
+		panic(dialErr)
+	}
+
+	r := &testLossyRelay{
+		conn:     conn,
+		upstream: upstream,
+		client:   nil,
+		lock:     sync.Mutex{},
+		relayed:  0,
+	}
+
+	go r.up()
+	go r.down()
+
+	return r
+}
+
+func (r *testLossyRelay) up() {
+	buf := [MaxPacketSize]byte{}
+
+	for {
+		rLen, rAddr, rErr := r.conn.ReadFromUDP(buf[:])
+
+		if rErr != nil {
+			return
+		}
+
+		r.lock.Lock()
+		r.client = rAddr
+		r.relayed++
+		relayed := r.relayed
+		r.lock.Unlock()
+
+		if relayed <= 1 {
+			continue
+		}
+
+		r.upstream.Write(buf[:rLen])
+	}
+}
+
+func (r *testLossyRelay) down() {
+	buf := [MaxPacketSize]byte{}
+
+	for {
+		rLen, rErr := r.upstream.Read(buf[:])
+
+		if rErr != nil {
+			return
+		}
+
+		r.lock.Lock()
+		client := r.client
+		r.lock.Unlock()
+
+		r.conn.WriteToUDP(buf[:rLen], client)
+	}
+}
+
+func (r *testLossyRelay) Relayed() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.relayed
+}
+
+func (r *testLossyRelay) Port() uint16 {
+	return uint16(r.conn.LocalAddr().(*net.UDPAddr).Port)
+}
+
+func (r *testLossyRelay) Close() {
+	r.conn.Close()
+	r.upstream.Close()
+}
+
+func TestClientAnnounceLost(t *testing.T) {
+	server := NewServer(net.ParseIP("127.0.0.1"), 0)
+
+	listenErr := server.Listen()
+
+	if listenErr != nil {
+		t.Error("Failed to listen due to error:", listenErr)
+
+		return
+	}
+
+	defer server.Close()
+
+	session := testMustNewSession()
+
+	serverSession, openErr := server.Open(session)
+
+	if openErr != nil {
+		t.Error("Failed to open session due to error:", openErr)
+
+		return
+	}
+
+	defer serverSession.Close()
+
+	relay := testMustNewLossyRelay(server.Port())
+
+	defer relay.Close()
+
+	conn, dialErr := NewDialer("127.0.0.1", time.Second)(relay.Port())
+
+	if dialErr != nil {
+		t.Error("Failed to dial due to error:", dialErr)
+
+		return
+	}
+
+	client, clientErr := NewClient(conn, session)
+
+	if clientErr != nil {
+		t.Error("Failed to create client due to error:", clientErr)
+
+		return
+	}
+
+	defer client.Close()
+
+	// The server only writes, it can reach the client once one of
+	// the announces resent has been relayed
+	written := make(chan struct{})
+
+	defer close(written)
+
+	go func() {
+		ticker := time.NewTicker(50 * time.Millisecond)
+
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				serverSession.Write([]byte("Hello Client"))
+
+			case <-written:
+				return
+			}
+		}
+	}()
+
+	buf := [MaxPacketSize]byte{}
+
+	conn.SetReadDeadline(time.Now().Add(3 * announceInterval))
+
+	rLen, rErr := client.Read(buf[:])
+
+	if rErr != nil {
+		t.Error("Failed to read due to error:", rErr)
+
+		return
+	}
+
+	if string(buf[:rLen]) != "Hello Client" {
+		t.Errorf("Expecting client will read %s, got %s",
+			"Hello Client", buf[:rLen])
+
+		return
+	}
+
+	relayed := relay.Relayed()
+
+	time.Sleep(2 * announceInterval)
+
+	if relay.Relayed() != relayed {
+		t.Errorf("Expecting no more announce will be sent after the "+
+			"first packet has been received, got %d more",
+			relay.Relayed()-relayed)
+
+		return
+	}
+}
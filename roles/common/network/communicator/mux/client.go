@@ -0,0 +1,307 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package mux
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nickrio/coward/common/locked"
+	"github.com/nickrio/coward/roles/common/network/communicator/common"
+	"github.com/nickrio/coward/roles/common/network/conn"
+	"github.com/nickrio/coward/roles/common/network/transporter"
+)
+
+// Client errors
+var (
+	ErrClientNoConnection = errors.New(
+		"Client no connection")
+
+	ErrClientFailedToConnection = errors.New(
+		"Client couldn't dial to the remote host")
+)
+
+// clientConfig is configuration data used by client
+type clientConfig struct {
+	dialer         common.Dialer
+	connectTimeout time.Duration
+	idleTimeout    time.Duration
+	wrapper        common.ConnWrapper
+	sessions       []*session
+	dialing        []chan struct{}
+	sessionsLock   sync.Mutex
+}
+
+// client implements Transporter Client. Every client is a stream
+// which shares the session with other clients
+type client struct {
+	net.Conn
+
+	config    *clientConfig
+	name      string
+	used      locked.Boolean
+	connected bool
+}
+
+// NewClientBuilder builds a new Client builder. Clients built by the
+// builder will be multiplexed over no more than given number of
+// sessions
+func NewClientBuilder(
+	host string,
+	port uint16,
+	connectTimeout time.Duration,
+	idleTimeout time.Duration,
+	wrapper common.ConnWrapper,
+	sessions uint16,
+	upstream common.Upstream,
+) func() transporter.ClientConn {
+	config := &clientConfig{
+		dialer:         common.NewDialer(host, port, upstream),
+		connectTimeout: connectTimeout,
+		idleTimeout:    idleTimeout,
+		wrapper:        wrapper,
+		sessions:       make([]*session, sessions),
+		dialing:        make([]chan struct{}, sessions),
+		sessionsLock:   sync.Mutex{},
+	}
+
+	return func() transporter.ClientConn {
+		return &client{
+			Conn:      nil,
+			config:    config,
+			name:      "",
+			used:      locked.NewBool(false),
+			connected: false,
+		}
+	}
+}
+
+// pick selects the session which carries least streams, and finds a
+// free slot which is not being dialed. If all free slots are being
+// dialed, a channel which will be closed after one of the dial is
+// finished will be returned. Must be called with sessionsLock held
+func (c *clientConfig) pick() (*session, int, chan struct{}) {
+	var selected *session
+	var dialing chan struct{}
+
+	free := -1
+
+	for idx, sess := range c.sessions {
+		if sess != nil && !sess.isClosed() {
+			if selected == nil ||
+				sess.streamCount() < selected.streamCount() {
+				selected = sess
+			}
+
+			continue
+		}
+
+		if c.dialing[idx] != nil {
+			dialing = c.dialing[idx]
+
+			continue
+		}
+
+		if free < 0 {
+			free = idx
+		}
+	}
+
+	return selected, free, dialing
+}
+
+// dial establishes a new session
+func (c *clientConfig) dial() (*session, error) {
+	newConn, dialErr := c.dialer.Dial("tcp", c.connectTimeout)
+
+	if dialErr != nil {
+		return nil, ErrClientFailedToConnection
+	}
+
+	wrappedConn, wrappedErr := c.wrapper(
+		conn.WrapClientConn(newConn, conn.ClientConfig{
+			Timeout: c.idleTimeout,
+			OnClose: func() {},
+		}))
+
+	if wrappedErr != nil {
+		newConn.Close()
+
+		return nil, wrappedErr
+	}
+
+	return newSession(wrappedConn, nil), nil
+}
+
+// session returns a session to open stream on. A new session will be
+// established if there is a free slot, otherwise, the session which
+// carries least streams will be selected. The selected session will
+// also be used when the new session can't be established
+func (c *clientConfig) session() (*session, error) {
+	for {
+		c.sessionsLock.Lock()
+
+		selected, free, dialing := c.pick()
+
+		if free >= 0 {
+			c.dialing[free] = make(chan struct{})
+		}
+
+		c.sessionsLock.Unlock()
+
+		if free < 0 {
+			if selected != nil {
+				return selected, nil
+			}
+
+			if dialing == nil {
+				return nil, ErrClientFailedToConnection
+			}
+
+			// Wait for other dials, there will be a session or a free
+			// slot after that
+			<-dialing
+
+			continue
+		}
+
+		// Dial without holding the lock, so the streams of established
+		// sessions will not be blocked by it
+		sess, dialErr := c.dial()
+
+		c.sessionsLock.Lock()
+
+		close(c.dialing[free])
+
+		c.dialing[free] = nil
+
+		if dialErr == nil {
+			c.sessions[free] = sess
+		}
+
+		c.sessionsLock.Unlock()
+
+		if dialErr == nil {
+			return sess, nil
+		}
+
+		if selected != nil && !selected.isClosed() {
+			return selected, nil
+		}
+
+		return nil, dialErr
+	}
+}
+
+// Dial opens a new stream to the Transporter server
+func (c *client) Dial() error {
+	if c.Conn != nil {
+		// Close the previous stream blindly, same as the TCP
+		// communicator
+		c.Close()
+
+		c.Conn = nil
+	}
+
+	sess, sessErr := c.config.session()
+
+	if sessErr != nil {
+		return sessErr
+	}
+
+	st, openErr := sess.open()
+
+	if openErr != nil {
+		return openErr
+	}
+
+	c.Conn = conn.WrapClientConn(st, conn.ClientConfig{
+		Timeout: c.config.idleTimeout,
+		OnClose: func() {},
+	})
+	c.name = st.LocalAddr().String() + "#" +
+		strconv.FormatUint(uint64(st.id), 10)
+	c.connected = true
+
+	return nil
+}
+
+// Rewind resets the status of current Transporter without
+// closing it
+func (c *client) Rewind() {
+	c.used.Set(false)
+}
+
+// Connected returns whether or not current client is connected
+// with a Transporter server
+func (c *client) Connected() bool {
+	return c.connected
+}
+
+// Name returns ID of current client
+func (c *client) Name() string {
+	return c.name
+}
+
+// Read reads data from source, and close connection when any
+// error happened
+func (c *client) Read(b []byte) (int, error) {
+	if !c.used.Get() {
+		// Set one time timeout
+		c.SetDeadline(time.Now().Add(c.config.connectTimeout))
+
+		c.used.Set(true)
+	}
+
+	rLen, rErr := c.Conn.Read(b)
+
+	if rErr != nil {
+		c.Close()
+	}
+
+	return rLen, rErr
+}
+
+// Write writes data to the source, and close connection when
+// any error happened
+func (c *client) Write(b []byte) (int, error) {
+	wLen, wErr := c.Conn.Write(b)
+
+	if wErr != nil {
+		c.Close()
+	}
+
+	return wLen, wErr
+}
+
+// Close closes current stream
+func (c *client) Close() error {
+	if c.Conn == nil {
+		return ErrClientNoConnection
+	}
+
+	c.connected = false
+
+	return c.Conn.Close()
+}
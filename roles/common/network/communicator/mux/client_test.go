@@ -0,0 +1,102 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package mux
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testClientDialer struct {
+	fail bool
+}
+
+func (d *testClientDialer) Dial(
+	dialType string, dialTimeout time.Duration) (net.Conn, error) {
+	if d.fail {
+		return nil, errors.New("Dial failed")
+	}
+
+	left, right := net.Pipe()
+
+	newSession(right, make(chan *stream, acceptBacklog))
+
+	return left, nil
+}
+
+func testClientConfig(dialer *testClientDialer, sessions int) *clientConfig {
+	return &clientConfig{
+		dialer:         dialer,
+		connectTimeout: time.Second,
+		idleTimeout:    time.Second,
+		wrapper:        func(c net.Conn) (net.Conn, error) { return c, nil },
+		sessions:       make([]*session, sessions),
+		dialing:        make([]chan struct{}, sessions),
+		sessionsLock:   sync.Mutex{},
+	}
+}
+
+func TestClientSessionFallback(t *testing.T) {
+	dialer := &testClientDialer{}
+	config := testClientConfig(dialer, 2)
+
+	first, sessErr := config.session()
+
+	if sessErr != nil {
+		t.Error("Can't get session due to error:", sessErr)
+
+		return
+	}
+
+	defer first.close()
+
+	// The second slot is free, but it can't be dialed. The established
+	// session must be used
+	dialer.fail = true
+
+	second, sessErr := config.session()
+
+	if sessErr != nil {
+		t.Error("Can't get session due to error:", sessErr)
+
+		return
+	}
+
+	if second != first {
+		t.Error("Expecting the established session to be selected")
+
+		return
+	}
+
+	first.close()
+
+	_, sessErr = config.session()
+
+	if sessErr != ErrClientFailedToConnection {
+		t.Errorf("Expecting error %s, got %v",
+			ErrClientFailedToConnection, sessErr)
+
+		return
+	}
+}
@@ -0,0 +1,92 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package mux
+
+import "errors"
+
+// frameType is the type of a multiplexing frame
+type frameType byte
+
+// Frame types
+const (
+	frameOpen   frameType = 1
+	frameData   frameType = 2
+	frameWindow frameType = 3
+	frameClose  frameType = 4
+)
+
+const (
+	// headerSize is the size of frame header
+	headerSize = 7
+
+	// maxPayloadSize is the max size of the payload of a frame
+	maxPayloadSize = 16 * 1024
+
+	// windowUpdateSize is the size of frameWindow payload
+	windowUpdateSize = 4
+
+	// streamWindow is how many data can be sent to a stream before
+	// the stream reads them
+	streamWindow = 256 * 1024
+
+	// acceptBacklog is how many opened streams can wait to be
+	// accepted. Streams opened after the backlog is full will be
+	// refused
+	acceptBacklog = 256
+)
+
+// Multiplexing errors
+var (
+	ErrSessionClosed = errors.New(
+		"Multiplexing session is closed")
+
+	ErrStreamClosed = errors.New(
+		"Multiplexing stream is closed")
+
+	ErrInvalidFrame = errors.New(
+		"Invalid multiplexing frame")
+
+	ErrWindowExceeded = errors.New(
+		"Multiplexing stream receive window exceeded")
+
+	ErrServerClosed = errors.New(
+		"Multiplexing server is closed")
+
+	ErrTimeout = timeoutError{}
+)
+
+// timeoutError is returned when a stream operation is timed out
+type timeoutError struct{}
+
+// Error returns the error message
+func (t timeoutError) Error() string {
+	return "Multiplexing stream operation timed out"
+}
+
+// Timeout returns true as it's a timeout error
+func (t timeoutError) Timeout() bool {
+	return true
+}
+
+// Temporary returns true as it's a timeout error
+func (t timeoutError) Temporary() bool {
+	return true
+}
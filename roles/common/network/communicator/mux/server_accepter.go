@@ -0,0 +1,135 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package mux
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/nickrio/coward/roles/common/network"
+	"github.com/nickrio/coward/roles/common/network/communicator/common"
+	"github.com/nickrio/coward/roles/common/network/conn"
+	"github.com/nickrio/coward/roles/common/network/transporter"
+)
+
+// serverAccepter will accept incoming sessions, and return streams
+// of them as Transporter ServerClientConns
+type serverAccepter struct {
+	Config      *serverConfig
+	ListenConn  net.Listener
+	Connections network.Connections
+	Streams     chan *stream
+	AcceptErr   chan error
+	Closed      chan struct{}
+}
+
+// Name returns the name of current Accepter
+func (s *serverAccepter) Name() string {
+	return s.ListenConn.Addr().String()
+}
+
+// serve accepts incoming connections and start sessions on them
+func (s *serverAccepter) serve() {
+	for {
+		aConn, aErr := s.ListenConn.Accept()
+
+		if aErr != nil {
+			select {
+			case s.AcceptErr <- aErr:
+				continue
+
+			case <-s.Closed:
+				return
+			}
+		}
+
+		recorded := common.NewRecorder(aConn)
+
+		wrapped, wrapErr := s.Config.Wrapper(
+			conn.WrapClientConn(recorded, conn.ClientConfig{
+				Timeout: s.Config.IdleTimeout,
+				OnClose: func() {},
+			}))
+
+		if wrapErr != nil {
+			aConn.Close()
+
+			continue
+		}
+
+		clientAddr := wrapped.RemoteAddr().String()
+		sessionConn := &serverSessionConn{
+			Conn: common.NewGuardedConn(wrapped, recorded, s.Config.Failure,
+				s.Config.ConnectTimeout, s.Config.IdleTimeout),
+			OnClose: func() {
+				s.Connections.Del(clientAddr)
+			},
+		}
+
+		s.Connections.Put(clientAddr, sessionConn)
+
+		newSession(sessionConn, s.Streams)
+	}
+}
+
+// Accept accepts incoming streams
+func (s *serverAccepter) Accept() (transporter.ServerClientConn, error) {
+	select {
+	case st := <-s.Streams:
+		name := st.RemoteAddr().String() + "#" +
+			strconv.FormatUint(uint64(st.id), 10)
+
+		return &serverConn{
+			Conn: conn.WrapClientConn(st, conn.ClientConfig{
+				Timeout: s.Config.IdleTimeout,
+				OnClose: func() {},
+			}),
+			RemoteAddress: name,
+			Session:       st.session,
+		}, nil
+
+	case aErr := <-s.AcceptErr:
+		return nil, aErr
+
+	case <-s.Closed:
+		return nil, ErrServerClosed
+	}
+}
+
+// Close closes current accepter. This will shutdown the listening
+// connection and all sessions associated with it
+func (s *serverAccepter) Close() error {
+	cErr := s.ListenConn.Close()
+
+	if cErr != nil {
+		return cErr
+	}
+
+	close(s.Closed)
+
+	// Must call close in a routine or we will doomed to
+	// dead lock
+	s.Connections.Iterate(func(name string, conn net.Conn) {
+		go conn.Close()
+	})
+
+	return nil
+}
@@ -0,0 +1,78 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package mux
+
+import (
+	"net"
+
+	"github.com/nickrio/coward/roles/common/network/communicator/common"
+)
+
+// serverSessionConn is the conn which carries a session
+type serverSessionConn struct {
+	net.Conn
+
+	OnClose func()
+}
+
+// serverConn is a stream which will be served as a client
+type serverConn struct {
+	net.Conn
+
+	RemoteAddress string
+	Session       *session
+}
+
+// Close closes the session conn
+func (s *serverSessionConn) Close() error {
+	cErr := s.Conn.Close()
+
+	s.OnClose()
+
+	return cErr
+}
+
+// Identity returns the name of the key which the client is using
+func (s *serverSessionConn) Identity() (string, bool) {
+	identified, isIdentified := s.Conn.(common.IdentifiedConn)
+
+	if !isIdentified {
+		return "", false
+	}
+
+	return identified.Identity()
+}
+
+// Name returns the name or ID of current connection
+func (s *serverConn) Name() string {
+	return s.RemoteAddress
+}
+
+// Identity returns the name of the key which the client is using
+func (s *serverConn) Identity() (string, bool) {
+	identified, isIdentified := s.Session.conn.(common.IdentifiedConn)
+
+	if !isIdentified {
+		return "", false
+	}
+
+	return identified.Identity()
+}
@@ -0,0 +1,116 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package mux
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nickrio/coward/roles/common/network/communicator/common"
+)
+
+type testFailingConn struct {
+	net.Conn
+}
+
+func (t testFailingConn) Read(b []byte) (int, error) {
+	return 0, errors.New("Decode failed")
+}
+
+func TestServerFallback(t *testing.T) {
+	fallback, listenErr := net.Listen("tcp", "127.0.0.1:0")
+
+	if listenErr != nil {
+		t.Error("Can't listen due to error:", listenErr)
+
+		return
+	}
+
+	defer fallback.Close()
+
+	go func() {
+		fallbackConn, acceptErr := fallback.Accept()
+
+		if acceptErr != nil {
+			return
+		}
+
+		defer fallbackConn.Close()
+
+		request := make([]byte, len("GET / HTTP/1.0"))
+
+		_, rErr := io.ReadFull(fallbackConn, request)
+
+		if rErr != nil {
+			return
+		}
+
+		fallbackConn.Write(append([]byte("Fallback: "), request...))
+	}()
+
+	accepter, listenErr := NewServer(net.ParseIP("127.0.0.1"), 0,
+		time.Second, time.Second,
+		func(c net.Conn) (net.Conn, error) {
+			return testFailingConn{Conn: c}, nil
+		}, common.FailurePolicy{
+			Fallback: fallback.Addr().String(),
+		}).Listen()
+
+	if listenErr != nil {
+		t.Error("Can't listen due to error:", listenErr)
+
+		return
+	}
+
+	defer accepter.Close()
+
+	probe, dialErr := net.Dial("tcp", accepter.Name())
+
+	if dialErr != nil {
+		t.Error("Can't dial due to error:", dialErr)
+
+		return
+	}
+
+	defer probe.Close()
+
+	probe.Write([]byte("GET / HTTP/1.0"))
+
+	reply, rErr := ioutil.ReadAll(probe)
+
+	if rErr != nil {
+		t.Error("Can't read reply due to error:", rErr)
+
+		return
+	}
+
+	if !bytes.Equal(reply, []byte("Fallback: GET / HTTP/1.0")) {
+		t.Errorf("Expecting reply %q, got %q",
+			"Fallback: GET / HTTP/1.0", reply)
+
+		return
+	}
+}
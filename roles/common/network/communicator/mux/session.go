@@ -0,0 +1,281 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package mux
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+)
+
+// session carries multiple streams over one conn.
+//
+// Frame format:
+//
+// +------+-----------+--------+---------+
+// | TYPE | STREAM ID | LENGTH | PAYLOAD |
+// +------+-----------+--------+---------+
+// |  1   |     4     |   2    | LENGTH  |
+// +------+-----------+--------+---------+
+//
+// Streams are only opened by the client side. Data frames sent to a
+// stream must not exceed the receive window of it, which will be
+// extended by window frames after the data is read
+type session struct {
+	conn      net.Conn
+	accept    chan *stream
+	writeLock sync.Mutex
+	writeBuf  [headerSize + maxPayloadSize]byte
+	lock      sync.Mutex
+	streams   map[uint32]*stream
+	nextID    uint32
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// newSession creates a new session and start to read frames from the
+// conn. Streams opened by the remote will be sent to the accept
+// channel, or if it's nil, the session will refuse to accept streams
+func newSession(conn net.Conn, accept chan *stream) *session {
+	s := &session{
+		conn:      conn,
+		accept:    accept,
+		writeLock: sync.Mutex{},
+		writeBuf:  [headerSize + maxPayloadSize]byte{},
+		lock:      sync.Mutex{},
+		streams:   make(map[uint32]*stream, 16),
+		nextID:    0,
+		closed:    make(chan struct{}),
+		closeOnce: sync.Once{},
+	}
+
+	go s.serve()
+
+	return s
+}
+
+// serve reads and handles frames until the session is closed
+func (s *session) serve() {
+	header := [headerSize]byte{}
+	payload := [maxPayloadSize]byte{}
+
+	for {
+		_, rErr := io.ReadFull(s.conn, header[:])
+
+		if rErr != nil {
+			s.close()
+
+			return
+		}
+
+		length := int(binary.BigEndian.Uint16(header[5:7]))
+
+		if length > maxPayloadSize {
+			s.close()
+
+			return
+		}
+
+		_, rErr = io.ReadFull(s.conn, payload[:length])
+
+		if rErr != nil {
+			s.close()
+
+			return
+		}
+
+		handleErr := s.handle(frameType(header[0]),
+			binary.BigEndian.Uint32(header[1:5]), payload[:length])
+
+		if handleErr != nil {
+			s.close()
+
+			return
+		}
+	}
+}
+
+// handle handles a received frame
+func (s *session) handle(ft frameType, id uint32, payload []byte) error {
+	if ft == frameOpen {
+		return s.opened(id)
+	}
+
+	s.lock.Lock()
+	st, found := s.streams[id]
+	s.lock.Unlock()
+
+	// The stream may already been closed by us, ignore frames that
+	// still on their way
+	if !found {
+		return nil
+	}
+
+	switch ft {
+	case frameData:
+		return st.push(payload)
+
+	case frameWindow:
+		if len(payload) != windowUpdateSize {
+			return ErrInvalidFrame
+		}
+
+		st.extend(int(binary.BigEndian.Uint32(payload)))
+
+		return nil
+
+	case frameClose:
+		st.remoteClose()
+
+		return nil
+	}
+
+	return ErrInvalidFrame
+}
+
+// opened accepts a stream which opened by the remote
+func (s *session) opened(id uint32) error {
+	if s.accept == nil {
+		return ErrInvalidFrame
+	}
+
+	s.lock.Lock()
+
+	_, found := s.streams[id]
+
+	if found {
+		s.lock.Unlock()
+
+		return ErrInvalidFrame
+	}
+
+	st := newStream(id, s)
+
+	s.streams[id] = st
+
+	s.lock.Unlock()
+
+	// Never wait for the accepter here, otherwise frames of all other
+	// streams will be blocked with it
+	select {
+	case s.accept <- st:
+		return nil
+
+	default:
+		st.Close()
+
+		return nil
+	}
+}
+
+// open opens a new stream
+func (s *session) open() (*stream, error) {
+	s.lock.Lock()
+
+	if s.isClosed() {
+		s.lock.Unlock()
+
+		return nil, ErrSessionClosed
+	}
+
+	s.nextID++
+
+	st := newStream(s.nextID, s)
+
+	s.streams[st.id] = st
+
+	s.lock.Unlock()
+
+	wErr := s.write(frameOpen, st.id, nil)
+
+	if wErr != nil {
+		s.remove(st.id)
+
+		return nil, wErr
+	}
+
+	return st, nil
+}
+
+// remove removes a stream from the session
+func (s *session) remove(id uint32) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.streams, id)
+}
+
+// streamCount returns how many streams are currently opened
+func (s *session) streamCount() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return len(s.streams)
+}
+
+// write writes a frame
+func (s *session) write(ft frameType, id uint32, payload []byte) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	if s.isClosed() {
+		return ErrSessionClosed
+	}
+
+	s.writeBuf[0] = byte(ft)
+
+	binary.BigEndian.PutUint32(s.writeBuf[1:5], id)
+	binary.BigEndian.PutUint16(s.writeBuf[5:7], uint16(len(payload)))
+
+	copy(s.writeBuf[headerSize:], payload)
+
+	_, wErr := s.conn.Write(s.writeBuf[:headerSize+len(payload)])
+
+	if wErr != nil {
+		s.close()
+
+		return wErr
+	}
+
+	return nil
+}
+
+// isClosed returns whether or not the session is closed
+func (s *session) isClosed() bool {
+	select {
+	case <-s.closed:
+		return true
+
+	default:
+		return false
+	}
+}
+
+// close closes the session and the underlaying conn. All streams of
+// the session will be failed
+func (s *session) close() {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+
+		s.conn.Close()
+	})
+}
@@ -0,0 +1,225 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package mux
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testSessions() (*session, chan *stream) {
+	left, right := net.Pipe()
+	accept := make(chan *stream, acceptBacklog)
+
+	newSession(right, accept)
+
+	return newSession(left, nil), accept
+}
+
+func TestSessionStreams(t *testing.T) {
+	client, accept := testSessions()
+	defer client.close()
+
+	go func() {
+		for st := range accept {
+			go func(st *stream) {
+				defer st.Close()
+
+				io.Copy(st, st)
+			}(st)
+		}
+	}()
+
+	// Larger than the stream window, so the flow control will be
+	// triggered
+	testData := bytes.Repeat([]byte("Test data is here"), 64*1024)
+	wait := sync.WaitGroup{}
+
+	for idx := 0; idx < 8; idx++ {
+		st, openErr := client.open()
+
+		if openErr != nil {
+			t.Error("Can't open stream due to error:", openErr)
+
+			return
+		}
+
+		wait.Add(1)
+
+		go func(st *stream) {
+			defer wait.Done()
+
+			st.Write(testData)
+		}(st)
+
+		wait.Add(1)
+
+		go func(st *stream) {
+			defer wait.Done()
+			defer st.Close()
+
+			readBuf := make([]byte, len(testData))
+
+			_, rErr := io.ReadFull(st, readBuf)
+
+			if rErr != nil {
+				t.Error("Can't read due to error:", rErr)
+
+				return
+			}
+
+			if !bytes.Equal(testData, readBuf) {
+				t.Error("Failed to read expected data")
+
+				return
+			}
+		}(st)
+	}
+
+	wait.Wait()
+}
+
+func TestStreamClose(t *testing.T) {
+	client, accept := testSessions()
+	defer client.close()
+
+	st, openErr := client.open()
+
+	if openErr != nil {
+		t.Error("Can't open stream due to error:", openErr)
+
+		return
+	}
+
+	remote := <-accept
+
+	st.Write([]byte("Hello"))
+	st.Close()
+
+	readBuf := make([]byte, 5)
+
+	_, rErr := io.ReadFull(remote, readBuf)
+
+	if rErr != nil {
+		t.Error("Can't read due to error:", rErr)
+
+		return
+	}
+
+	_, rErr = remote.Read(readBuf)
+
+	if rErr != io.EOF {
+		t.Errorf("Expecting error %s, got %v", io.EOF, rErr)
+
+		return
+	}
+
+	if client.streamCount() != 0 {
+		t.Errorf("Expecting no stream left, got %d", client.streamCount())
+
+		return
+	}
+}
+
+func TestStreamReadTimeout(t *testing.T) {
+	client, accept := testSessions()
+	defer client.close()
+
+	st, openErr := client.open()
+
+	if openErr != nil {
+		t.Error("Can't open stream due to error:", openErr)
+
+		return
+	}
+
+	<-accept
+
+	st.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+
+	_, rErr := st.Read(make([]byte, 1))
+
+	if rErr != ErrTimeout {
+		t.Errorf("Expecting error %s, got %v", ErrTimeout, rErr)
+
+		return
+	}
+}
+
+func TestSessionAcceptBacklog(t *testing.T) {
+	left, right := net.Pipe()
+	accept := make(chan *stream, 1)
+
+	newSession(right, accept)
+
+	client := newSession(left, nil)
+	defer client.close()
+
+	queued, openErr := client.open()
+
+	if openErr != nil {
+		t.Error("Can't open stream due to error:", openErr)
+
+		return
+	}
+
+	refused, openErr := client.open()
+
+	if openErr != nil {
+		t.Error("Can't open stream due to error:", openErr)
+
+		return
+	}
+
+	// Backlog is full, the second stream must be refused without
+	// blocking the first one
+	_, rErr := refused.Read(make([]byte, 1))
+
+	if rErr != io.EOF {
+		t.Errorf("Expecting error %s, got %v", io.EOF, rErr)
+
+		return
+	}
+
+	remote := <-accept
+
+	queued.Write([]byte("Hello"))
+
+	readBuf := make([]byte, 5)
+
+	_, rErr = io.ReadFull(remote, readBuf)
+
+	if rErr != nil {
+		t.Error("Can't read due to error:", rErr)
+
+		return
+	}
+
+	if string(readBuf) != "Hello" {
+		t.Errorf("Expecting %q, got %q", "Hello", readBuf)
+
+		return
+	}
+}
@@ -0,0 +1,338 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package mux
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// stream is a multiplexed conn inside a session
+type stream struct {
+	id            uint32
+	session       *session
+	lock          sync.Mutex
+	buffer        []byte
+	consumed      int
+	credit        int
+	readDeadline  time.Time
+	writeDeadline time.Time
+	readNotify    chan struct{}
+	writeNotify   chan struct{}
+	remoteClosed  bool
+	localClosed   bool
+}
+
+// newStream creates a new stream
+func newStream(id uint32, s *session) *stream {
+	return &stream{
+		id:            id,
+		session:       s,
+		lock:          sync.Mutex{},
+		buffer:        nil,
+		consumed:      0,
+		credit:        streamWindow,
+		readDeadline:  time.Time{},
+		writeDeadline: time.Time{},
+		readNotify:    make(chan struct{}, 1),
+		writeNotify:   make(chan struct{}, 1),
+		remoteClosed:  false,
+		localClosed:   false,
+	}
+}
+
+// notify wakes up the operation which is waiting on the channel
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// wait waits until the channel is notified, the deadline is reached
+// or the session is closed
+func (s *stream) wait(ch chan struct{}, deadline time.Time) error {
+	var timeout <-chan time.Time
+
+	if !deadline.IsZero() {
+		remain := deadline.Sub(time.Now())
+
+		if remain <= 0 {
+			return ErrTimeout
+		}
+
+		timer := time.NewTimer(remain)
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	select {
+	case <-ch:
+		return nil
+
+	case <-timeout:
+		return ErrTimeout
+
+	case <-s.session.closed:
+		return nil
+	}
+}
+
+// push appends received data to the read buffer
+func (s *stream) push(data []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.localClosed {
+		return nil
+	}
+
+	if len(s.buffer)+len(data) > streamWindow {
+		return ErrWindowExceeded
+	}
+
+	s.buffer = append(s.buffer, data...)
+
+	notify(s.readNotify)
+
+	return nil
+}
+
+// extend extends the send window
+func (s *stream) extend(size int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.credit += size
+
+	notify(s.writeNotify)
+}
+
+// remoteClose marks the stream as closed by the remote
+func (s *stream) remoteClose() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.remoteClosed = true
+
+	notify(s.readNotify)
+	notify(s.writeNotify)
+}
+
+// Read reads data from the stream
+func (s *stream) Read(b []byte) (int, error) {
+	for {
+		s.lock.Lock()
+
+		if len(s.buffer) > 0 {
+			rLen := copy(b, s.buffer)
+
+			s.buffer = s.buffer[rLen:]
+
+			if len(s.buffer) <= 0 {
+				s.buffer = nil
+			}
+
+			s.consumed += rLen
+
+			consumed := s.consumed
+
+			// Tell the remote it can send more data after we have
+			// consumed a half of the window
+			if consumed >= streamWindow/2 {
+				s.consumed = 0
+			}
+
+			s.lock.Unlock()
+
+			if consumed >= streamWindow/2 {
+				update := [windowUpdateSize]byte{}
+
+				binary.BigEndian.PutUint32(update[:], uint32(consumed))
+
+				s.session.write(frameWindow, s.id, update[:])
+			}
+
+			return rLen, nil
+		}
+
+		if s.remoteClosed {
+			s.lock.Unlock()
+
+			return 0, io.EOF
+		}
+
+		if s.localClosed {
+			s.lock.Unlock()
+
+			return 0, ErrStreamClosed
+		}
+
+		if s.session.isClosed() {
+			s.lock.Unlock()
+
+			return 0, ErrSessionClosed
+		}
+
+		deadline := s.readDeadline
+
+		s.lock.Unlock()
+
+		waitErr := s.wait(s.readNotify, deadline)
+
+		if waitErr != nil {
+			return 0, waitErr
+		}
+	}
+}
+
+// Write writes data to the stream
+func (s *stream) Write(b []byte) (int, error) {
+	totalWLen := 0
+
+	for totalWLen < len(b) {
+		s.lock.Lock()
+
+		if s.localClosed || s.remoteClosed {
+			s.lock.Unlock()
+
+			return totalWLen, ErrStreamClosed
+		}
+
+		if s.session.isClosed() {
+			s.lock.Unlock()
+
+			return totalWLen, ErrSessionClosed
+		}
+
+		if s.credit <= 0 {
+			deadline := s.writeDeadline
+
+			s.lock.Unlock()
+
+			waitErr := s.wait(s.writeNotify, deadline)
+
+			if waitErr != nil {
+				return totalWLen, waitErr
+			}
+
+			continue
+		}
+
+		wLen := len(b) - totalWLen
+
+		if wLen > s.credit {
+			wLen = s.credit
+		}
+
+		if wLen > maxPayloadSize {
+			wLen = maxPayloadSize
+		}
+
+		s.credit -= wLen
+
+		s.lock.Unlock()
+
+		wErr := s.session.write(
+			frameData, s.id, b[totalWLen:totalWLen+wLen])
+
+		if wErr != nil {
+			return totalWLen, wErr
+		}
+
+		totalWLen += wLen
+	}
+
+	return totalWLen, nil
+}
+
+// Close closes the stream
+func (s *stream) Close() error {
+	s.lock.Lock()
+
+	if s.localClosed {
+		s.lock.Unlock()
+
+		return ErrStreamClosed
+	}
+
+	s.localClosed = true
+	s.buffer = nil
+
+	notify(s.readNotify)
+	notify(s.writeNotify)
+
+	s.lock.Unlock()
+
+	s.session.remove(s.id)
+
+	if s.session.isClosed() {
+		return nil
+	}
+
+	return s.session.write(frameClose, s.id, nil)
+}
+
+// LocalAddr returns the local address of the session
+func (s *stream) LocalAddr() net.Addr {
+	return s.session.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote address of the session
+func (s *stream) RemoteAddr() net.Addr {
+	return s.session.conn.RemoteAddr()
+}
+
+// SetDeadline sets both read and write deadline
+func (s *stream) SetDeadline(t time.Time) error {
+	s.SetReadDeadline(t)
+	s.SetWriteDeadline(t)
+
+	return nil
+}
+
+// SetReadDeadline sets the read deadline
+func (s *stream) SetReadDeadline(t time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.readDeadline = t
+
+	notify(s.readNotify)
+
+	return nil
+}
+
+// SetWriteDeadline sets the write deadline
+func (s *stream) SetWriteDeadline(t time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.writeDeadline = t
+
+	notify(s.writeNotify)
+
+	return nil
+}
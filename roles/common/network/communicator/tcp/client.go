@@ -45,7 +45,6 @@ type clientConfig struct {
 	connectTimeout time.Duration
 	idleTimeout    time.Duration
 	wrapper        common.ConnWrapper
-	disrupter      common.ConnDisrupter
 }
 
 // client implements Transporter Client
@@ -57,21 +56,21 @@ type client struct {
 	connected bool
 }
 
-// NewClientBuilder builds a new Client builder
+// NewClientBuilder builds a new Client builder. Connections will be
+// tunneled through the upstream proxy when it is not nil
 func NewClientBuilder(
 	host string,
 	port uint16,
 	connectTimeout time.Duration,
 	idleTimeout time.Duration,
 	wrapper common.ConnWrapper,
-	disrupter common.ConnDisrupter,
+	upstream common.Upstream,
 ) func() transporter.ClientConn {
 	config := &clientConfig{
-		dialer:         common.NewDialer(host, port),
+		dialer:         common.NewDialer(host, port, upstream),
 		connectTimeout: connectTimeout,
 		idleTimeout:    idleTimeout,
 		wrapper:        wrapper,
-		disrupter:      disrupter,
 	}
 
 	return func() transporter.ClientConn {
@@ -106,12 +105,11 @@ func (c *client) Dial() error {
 	wrappedConn, wrappedErr := wrapConn(
 		newConn,
 		c.config.wrapper,
-		c.config.disrupter,
 		c.config.idleTimeout,
 	)
 
 	if wrappedErr != nil {
-		wrappedConn.Close()
+		newConn.Close()
 
 		return wrappedErr
 	}
@@ -24,6 +24,7 @@ import (
 	"net"
 
 	"github.com/nickrio/coward/roles/common/network"
+	"github.com/nickrio/coward/roles/common/network/communicator/common"
 	"github.com/nickrio/coward/roles/common/network/transporter"
 )
 
@@ -49,10 +50,11 @@ func (s *serverAccepter) Accept() (transporter.ServerClientConn, error) {
 		return nil, aErr
 	}
 
+	recorded := common.NewRecorder(aConn)
+
 	wrapped, wrapErr := wrapConn(
-		aConn,
+		recorded,
 		s.Config.Wrapper,
-		s.Config.Disrupter,
 		s.Config.IdleTimeout,
 	)
 
@@ -64,7 +66,8 @@ func (s *serverAccepter) Accept() (transporter.ServerClientConn, error) {
 
 	clientAddr := wrapped.RemoteAddr().String()
 	clientWrapped := &serverConn{
-		Conn:          wrapped,
+		Conn: common.NewGuardedConn(wrapped, recorded, s.Config.Failure,
+			s.Config.ConnectTimeout, s.Config.IdleTimeout),
 		RemoteAddress: clientAddr,
 		OnClose: func(name string) {
 			s.Connections.Del(name)
@@ -20,7 +20,11 @@
 
 package tcp
 
-import "net"
+import (
+	"net"
+
+	"github.com/nickrio/coward/roles/common/network/communicator/common"
+)
 
 // serverConn is wrapped conn for current TCP communicator
 type serverConn struct {
@@ -35,6 +39,17 @@ func (s *serverConn) Name() string {
 	return s.RemoteAddress
 }
 
+// Identity returns the name of the key which the client is using
+func (s *serverConn) Identity() (string, bool) {
+	identified, isIdentified := s.Conn.(common.IdentifiedConn)
+
+	if !isIdentified {
+		return "", false
+	}
+
+	return identified.Identity()
+}
+
 // Close shuts down current connection
 func (s *serverConn) Close() error {
 	cErr := s.Conn.Close()
@@ -37,7 +37,7 @@ type serverConfig struct {
 	ConnectTimeout time.Duration
 	IdleTimeout    time.Duration
 	Wrapper        common.ConnWrapper
-	Disrupter      common.ConnDisrupter
+	Failure        common.FailurePolicy
 }
 
 // server is a Transporter server
@@ -52,7 +52,7 @@ func NewServer(
 	connectTimeout time.Duration,
 	idleTimeout time.Duration,
 	wrapper common.ConnWrapper,
-	disrupter common.ConnDisrupter,
+	failure common.FailurePolicy,
 ) transporter.ServerConnListener {
 	config := &serverConfig{
 		ListenAddr:     listenAddr,
@@ -60,7 +60,7 @@ func NewServer(
 		ConnectTimeout: connectTimeout,
 		IdleTimeout:    idleTimeout,
 		Wrapper:        wrapper,
-		Disrupter:      disrupter,
+		Failure:        failure,
 	}
 
 	return &server{
@@ -31,11 +31,10 @@ import (
 func wrapConn(
 	newConn net.Conn,
 	wrapper common.ConnWrapper,
-	disrupter common.ConnDisrupter,
 	timeout time.Duration,
 ) (net.Conn, error) {
-	return wrapper(disrupter(conn.WrapClientConn(newConn, conn.ClientConfig{
+	return wrapper(conn.WrapClientConn(newConn, conn.ClientConfig{
 		Timeout: timeout,
 		OnClose: func() {},
-	})))
+	}))
 }
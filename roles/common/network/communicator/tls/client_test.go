@@ -0,0 +1,236 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nickrio/coward/roles/common/network/communicator/common"
+	"github.com/nickrio/coward/roles/common/network/transporter"
+)
+
+func testMustGenerateCertificate() Certificate {
+	key, keyErr := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if keyErr != nil {
+		panic(keyErr)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:     []string{"localhost"},
+	}
+
+	der, derErr := x509.CreateCertificate(
+		rand.Reader, template, template, &key.PublicKey, key)
+
+	if derErr != nil {
+		panic(derErr)
+	}
+
+	keyDER, keyDERErr := x509.MarshalECPrivateKey(key)
+
+	if keyDERErr != nil {
+		panic(keyDERErr)
+	}
+
+	cert, certErr := ParseCertificate(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+
+	if certErr != nil {
+		panic(certErr)
+	}
+
+	return cert
+}
+
+func testWrapper(c net.Conn) (net.Conn, error) {
+	return c, nil
+}
+
+func testMustListen(cert Certificate) transporter.ServerConnAccepter {
+	accepter, listenErr := NewServer(net.ParseIP("127.0.0.1"), 0,
+		time.Second, 10*time.Second, testWrapper, cert,
+		common.FailurePolicy{}).Listen()
+
+	if listenErr != nil {
+		panic(listenErr)
+	}
+
+	go func() {
+		for {
+			conn, acceptErr := accepter.Accept()
+
+			if acceptErr != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return accepter
+}
+
+func testMustGetPort(accepter transporter.ServerConnAccepter) uint16 {
+	_, port, splitErr := net.SplitHostPort(accepter.Name())
+
+	if splitErr != nil {
+		panic(splitErr)
+	}
+
+	portNum, portErr := strconv.ParseUint(port, 10, 16)
+
+	if portErr != nil {
+		panic(portErr)
+	}
+
+	return uint16(portNum)
+}
+
+func TestFingerprint(t *testing.T) {
+	cert := testMustGenerateCertificate()
+	fingerprint := cert.Fingerprint()
+
+	if len(fingerprint) != 32*3-1 {
+		t.Errorf("Invalid fingerprint format: %s", fingerprint)
+
+		return
+	}
+
+	parsed, parseErr := ParseFingerprint(fingerprint)
+
+	if parseErr != nil {
+		t.Error("Can't parse fingerprint due to error:", parseErr)
+
+		return
+	}
+
+	if Fingerprint(cert.certificate.Certificate[0]) != fingerprint ||
+		len(parsed) != 32 {
+		t.Errorf("Fingerprint mismatched")
+
+		return
+	}
+
+	_, parseErr = ParseFingerprint("AB:CD")
+
+	if parseErr != ErrInvalidFingerprint {
+		t.Errorf("Expecting error %s, got %v",
+			ErrInvalidFingerprint, parseErr)
+
+		return
+	}
+}
+
+func TestClientPinned(t *testing.T) {
+	cert := testMustGenerateCertificate()
+	accepter := testMustListen(cert)
+
+	defer accepter.Close()
+
+	fingerprint, _ := ParseFingerprint(cert.Fingerprint())
+	client := NewClientBuilder("127.0.0.1", testMustGetPort(accepter),
+		time.Second, 10*time.Second, testWrapper, ClientConfig{
+			ServerName:   "example.com",
+			Fingerprints: [][]byte{fingerprint},
+		}, nil)()
+
+	dialErr := client.Dial()
+
+	if dialErr != nil {
+		t.Error("Can't dial due to error:", dialErr)
+
+		return
+	}
+
+	defer client.Close()
+
+	_, wErr := client.Write([]byte("Hello TLS"))
+
+	if wErr != nil {
+		t.Error("Can't write due to error:", wErr)
+
+		return
+	}
+
+	reply := make([]byte, len("Hello TLS"))
+
+	_, rErr := io.ReadFull(client, reply)
+
+	if rErr != nil {
+		t.Error("Can't read due to error:", rErr)
+
+		return
+	}
+
+	if string(reply) != "Hello TLS" {
+		t.Errorf("Expecting reply %q, got %q", "Hello TLS", reply)
+
+		return
+	}
+}
+
+func TestClientNotPinned(t *testing.T) {
+	accepter := testMustListen(testMustGenerateCertificate())
+
+	defer accepter.Close()
+
+	fingerprint, _ := ParseFingerprint(
+		testMustGenerateCertificate().Fingerprint())
+
+	for _, config := range []ClientConfig{
+		{Fingerprints: [][]byte{fingerprint}},
+		{Fingerprints: nil},
+	} {
+		client := NewClientBuilder("127.0.0.1", testMustGetPort(accepter),
+			time.Second, 10*time.Second, testWrapper, config, nil)()
+
+		dialErr := client.Dial()
+
+		if dialErr != ErrClientFailedToHandshake {
+			t.Errorf("Expecting error %s, got %v",
+				ErrClientFailedToHandshake, dialErr)
+
+			return
+		}
+	}
+}
@@ -0,0 +1,193 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package tls
+
+import (
+	"bytes"
+	"crypto/sha256"
+	ctls "crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// Certificate errors
+var (
+	ErrInvalidFingerprint = errors.New(
+		"Invalid certificate fingerprint")
+
+	ErrCertificateNotPinned = errors.New(
+		"Server certificate does not match any pinned fingerprint")
+
+	ErrNoCertificate = errors.New(
+		"Server presented no certificate")
+)
+
+// Certificate is a loaded TLS certificate and its private key
+type Certificate struct {
+	certificate ctls.Certificate
+}
+
+// LoadCertificate loads a certificate and its private key from PEM
+// encoded files
+func LoadCertificate(certFile string, keyFile string) (Certificate, error) {
+	cert, certErr := ctls.LoadX509KeyPair(certFile, keyFile)
+
+	if certErr != nil {
+		return Certificate{}, certErr
+	}
+
+	return Certificate{certificate: cert}, nil
+}
+
+// ParseCertificate parses a certificate and its private key from PEM
+// encoded data
+func ParseCertificate(certPEM []byte, keyPEM []byte) (Certificate, error) {
+	cert, certErr := ctls.X509KeyPair(certPEM, keyPEM)
+
+	if certErr != nil {
+		return Certificate{}, certErr
+	}
+
+	return Certificate{certificate: cert}, nil
+}
+
+// Server starts a TLS session on an accepted connection. The
+// handshake will be performed during the first read, and must be
+// completed within the given timeout. A failed handshake will be
+// reported as a connection error
+func (c Certificate) Server(conn net.Conn, timeout time.Duration) net.Conn {
+	return &serverSession{
+		Conn: ctls.Server(conn, &ctls.Config{
+			Certificates: []ctls.Certificate{c.certificate},
+		}),
+		timeout:    timeout,
+		handshaked: false,
+	}
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the certificate
+func (c Certificate) Fingerprint() string {
+	if len(c.certificate.Certificate) <= 0 {
+		return ""
+	}
+
+	return Fingerprint(c.certificate.Certificate[0])
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a DER encoded
+// certificate, in the "AB:CD:..." format
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	hexed := strings.ToUpper(hex.EncodeToString(sum[:]))
+	result := make([]string, 0, len(sum))
+
+	for i := 0; i < len(hexed); i += 2 {
+		result = append(result, hexed[i:i+2])
+	}
+
+	return strings.Join(result, ":")
+}
+
+// ParseFingerprint parses a SHA-256 fingerprint. Both "AB:CD:..." and
+// "abcd..." formats are accepted
+func ParseFingerprint(fingerprint string) ([]byte, error) {
+	result, decodeErr := hex.DecodeString(
+		strings.Replace(fingerprint, ":", "", -1))
+
+	if decodeErr != nil || len(result) != sha256.Size {
+		return nil, ErrInvalidFingerprint
+	}
+
+	return result, nil
+}
+
+// ClientConfig is the TLS configuration of a client
+type ClientConfig struct {
+	// ServerName will be sent as SNI. If it's empty, the remote host
+	// name will be used unless it's an IP address
+	ServerName string
+
+	// Fingerprints are SHA-256 fingerprints of the trusted server
+	// certificates. When defined, the server certificate will only be
+	// checked against them, so a self-signed certificate can be used.
+	// Otherwise, the certificate will be verified by system CAs
+	Fingerprints [][]byte
+}
+
+// build builds the crypto/tls configuration
+func (c ClientConfig) build(host string) *ctls.Config {
+	serverName := c.ServerName
+
+	if serverName == "" && net.ParseIP(host) == nil {
+		serverName = host
+	}
+
+	if len(c.Fingerprints) <= 0 {
+		return &ctls.Config{
+			ServerName: serverName,
+		}
+	}
+
+	return &ctls.Config{
+		ServerName:            serverName,
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: c.verify,
+	}
+}
+
+// Client starts a TLS session on a dialed connection, and returns
+// after the handshake is completed
+func (c ClientConfig) Client(
+	conn net.Conn, host string, timeout time.Duration) (net.Conn, error) {
+	tlsConn := ctls.Client(conn, c.build(host))
+
+	handshakeErr := handshake(tlsConn, timeout)
+
+	if handshakeErr != nil {
+		return nil, handshakeErr
+	}
+
+	return tlsConn, nil
+}
+
+// verify checks whether or not the server certificate is pinned
+func (c ClientConfig) verify(
+	rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) <= 0 {
+		return ErrNoCertificate
+	}
+
+	sum := sha256.Sum256(rawCerts[0])
+
+	for _, fingerprint := range c.Fingerprints {
+		if !bytes.Equal(fingerprint, sum[:]) {
+			continue
+		}
+
+		return nil
+	}
+
+	return ErrCertificateNotPinned
+}
@@ -0,0 +1,205 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package tls
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nickrio/coward/roles/common/network/communicator/common"
+	"github.com/nickrio/coward/roles/common/network/transporter"
+)
+
+// testFailingConn fails after reading through the TLS connection, so
+// the handshake will be carried out first
+type testFailingConn struct {
+	net.Conn
+}
+
+func (t testFailingConn) Read(b []byte) (int, error) {
+	_, rErr := t.Conn.Read(b)
+
+	if rErr != nil {
+		return 0, rErr
+	}
+
+	return 0, errors.New("Decode failed")
+}
+
+func testMustListenFallback(
+	cert Certificate, fallback string) transporter.ServerConnAccepter {
+	accepter, listenErr := NewServer(net.ParseIP("127.0.0.1"), 0,
+		time.Second, 10*time.Second, func(c net.Conn) (net.Conn, error) {
+			return testFailingConn{Conn: c}, nil
+		}, cert, common.FailurePolicy{
+			Fallback: fallback,
+		}).Listen()
+
+	if listenErr != nil {
+		panic(listenErr)
+	}
+
+	go func() {
+		for {
+			conn, acceptErr := accepter.Accept()
+
+			if acceptErr != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				conn.Read(make([]byte, 16))
+			}()
+		}
+	}()
+
+	return accepter
+}
+
+func TestServerFallback(t *testing.T) {
+	fallback, listenErr := net.Listen("tcp", "127.0.0.1:0")
+
+	if listenErr != nil {
+		t.Error("Can't listen due to error:", listenErr)
+
+		return
+	}
+
+	defer fallback.Close()
+
+	go func() {
+		fallbackConn, acceptErr := fallback.Accept()
+
+		if acceptErr != nil {
+			return
+		}
+
+		defer fallbackConn.Close()
+
+		request := make([]byte, len("GET / HTTP/1.0"))
+
+		_, rErr := io.ReadFull(fallbackConn, request)
+
+		if rErr != nil {
+			return
+		}
+
+		fallbackConn.Write(append([]byte("Fallback: "), request...))
+	}()
+
+	cert := testMustGenerateCertificate()
+	accepter := testMustListenFallback(cert, fallback.Addr().String())
+
+	defer accepter.Close()
+
+	raw, dialErr := net.Dial("tcp", accepter.Name())
+
+	if dialErr != nil {
+		t.Error("Can't dial due to error:", dialErr)
+
+		return
+	}
+
+	defer raw.Close()
+
+	fingerprint, _ := ParseFingerprint(cert.Fingerprint())
+
+	client, handshakeErr := ClientConfig{
+		Fingerprints: [][]byte{fingerprint},
+	}.Client(raw, "127.0.0.1", time.Second)
+
+	if handshakeErr != nil {
+		t.Error("Can't handshake due to error:", handshakeErr)
+
+		return
+	}
+
+	client.Write([]byte("GET / HTTP/1.0"))
+
+	reply, rErr := ioutil.ReadAll(client)
+
+	if rErr != nil {
+		t.Error("Can't read reply due to error:", rErr)
+
+		return
+	}
+
+	if !bytes.Equal(reply, []byte("Fallback: GET / HTTP/1.0")) {
+		t.Errorf("Expecting reply %q, got %q",
+			"Fallback: GET / HTTP/1.0", reply)
+
+		return
+	}
+}
+
+func TestServerHandshakeFailure(t *testing.T) {
+	fallback, listenErr := net.ListenTCP("tcp", &net.TCPAddr{
+		IP: net.ParseIP("127.0.0.1"),
+	})
+
+	if listenErr != nil {
+		t.Error("Can't listen due to error:", listenErr)
+
+		return
+	}
+
+	defer fallback.Close()
+
+	accepter := testMustListenFallback(
+		testMustGenerateCertificate(), fallback.Addr().String())
+
+	defer accepter.Close()
+
+	raw, dialErr := net.Dial("tcp", accepter.Name())
+
+	if dialErr != nil {
+		t.Error("Can't dial due to error:", dialErr)
+
+		return
+	}
+
+	defer raw.Close()
+
+	raw.Write([]byte("GET / HTTP/1.0"))
+
+	// A failed TLS handshake is answered by the TLS layer, so the
+	// connection will just be closed instead of been forwarded
+	ioutil.ReadAll(raw)
+
+	fallback.SetDeadline(time.Now().Add(100 * time.Millisecond))
+
+	forwarded, acceptErr := fallback.Accept()
+
+	if acceptErr == nil {
+		forwarded.Close()
+
+		t.Error("Expecting the connection not to be forwarded")
+
+		return
+	}
+}
@@ -0,0 +1,98 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package tls
+
+import (
+	ctls "crypto/tls"
+	"net"
+	"time"
+
+	"github.com/nickrio/coward/roles/common/network/communicator/common"
+	"github.com/nickrio/coward/roles/common/network/conn"
+)
+
+func wrapConn(
+	newConn net.Conn,
+	wrapper common.ConnWrapper,
+	timeout time.Duration,
+) (net.Conn, error) {
+	return wrapper(conn.WrapClientConn(newConn, conn.ClientConfig{
+		Timeout: timeout,
+		OnClose: func() {},
+	}))
+}
+
+// handshake performs TLS handshake within given timeout
+func handshake(tlsConn *ctls.Conn, timeout time.Duration) error {
+	deadlineErr := tlsConn.SetDeadline(time.Now().Add(timeout))
+
+	if deadlineErr != nil {
+		return deadlineErr
+	}
+
+	handshakeErr := tlsConn.Handshake()
+
+	if handshakeErr != nil {
+		return handshakeErr
+	}
+
+	return tlsConn.SetDeadline(time.Time{})
+}
+
+// handshakeError is a failed TLS handshake. It's answered by the TLS
+// layer itself rather than caused by the transported data, so it will
+// be treated as a connection error
+type handshakeError struct {
+	err error
+}
+
+// Error returns the error message
+func (h handshakeError) Error() string {
+	return "TLS handshake failed: " + h.err.Error()
+}
+
+// IsErrorConnError implements conn.ErrorConnError
+func (h handshakeError) IsErrorConnError() bool {
+	return true
+}
+
+// serverSession is the TLS session of an accepted connection
+type serverSession struct {
+	*ctls.Conn
+
+	timeout    time.Duration
+	handshaked bool
+}
+
+// Read performs the TLS handshake before reading the first data
+func (s *serverSession) Read(b []byte) (int, error) {
+	if !s.handshaked {
+		handshakeErr := handshake(s.Conn, s.timeout)
+
+		if handshakeErr != nil {
+			return 0, handshakeError{err: handshakeErr}
+		}
+
+		s.handshaked = true
+	}
+
+	return s.Conn.Read(b)
+}
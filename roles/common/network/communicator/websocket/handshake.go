@@ -0,0 +1,208 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/nickrio/coward/roles/common/network/communicator/common"
+)
+
+// Handshake errors
+var (
+	ErrHandshakeRejected = errors.New(
+		"WebSocket upgrade has been rejected by the server")
+
+	ErrHandshakeInvalidAccept = errors.New(
+		"Invalid WebSocket accept key")
+
+	ErrHandshakeInvalidRequest = errors.New(
+		"Invalid WebSocket upgrade request")
+
+	ErrHandshakeNotCompleted = errors.New(
+		"WebSocket handshake is not completed")
+)
+
+// acceptGUID is the GUID defined by RFC 6455 for accept key
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// acceptKey generates the accept key of the handshake key
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerContains checks whether or not the comma separated header
+// contains the token
+func headerContains(header http.Header, name string, token string) bool {
+	for _, value := range strings.Split(header.Get(name), ",") {
+		if !strings.EqualFold(strings.TrimSpace(value), token) {
+			continue
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// clientHandshake upgrades a dialed connection to WebSocket
+func clientHandshake(c net.Conn, host string, path string) (*socket, error) {
+	keyBytes := [16]byte{}
+
+	_, rErr := rand.Read(keyBytes[:])
+
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	key := base64.StdEncoding.EncodeToString(keyBytes[:])
+
+	_, wErr := c.Write([]byte("GET " + path + " HTTP/1.1\r\n" +
+		"Host: " + host + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"))
+
+	if wErr != nil {
+		return nil, wErr
+	}
+
+	reader := bufio.NewReader(c)
+
+	resp, respErr := http.ReadResponse(reader, nil)
+
+	if respErr != nil {
+		return nil, respErr
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		!headerContains(resp.Header, "Upgrade", "websocket") {
+		return nil, ErrHandshakeRejected
+	}
+
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, ErrHandshakeInvalidAccept
+	}
+
+	return newSocket(c, reader, true), nil
+}
+
+// serverHandshake accepts WebSocket upgrade request on given path.
+// Requests which is not an upgrade request of the path will be
+// responded as not found unless silent is true, in which case the
+// caller will be responsible for answering it
+func serverHandshake(
+	c net.Conn, path string, silent bool) (*socket, error) {
+	reader := bufio.NewReader(c)
+
+	req, reqErr := http.ReadRequest(reader)
+
+	if reqErr != nil {
+		return nil, reqErr
+	}
+
+	req.Body.Close()
+
+	key := req.Header.Get("Sec-WebSocket-Key")
+
+	if req.Method != http.MethodGet || req.URL.Path != path || key == "" ||
+		req.Header.Get("Sec-WebSocket-Version") != "13" ||
+		!headerContains(req.Header, "Upgrade", "websocket") ||
+		!headerContains(req.Header, "Connection", "Upgrade") {
+		if silent {
+			return nil, ErrHandshakeInvalidRequest
+		}
+
+		c.Write([]byte("HTTP/1.1 404 Not Found\r\n" +
+			"Content-Length: 0\r\n" +
+			"Connection: close\r\n\r\n"))
+
+		return nil, ErrHandshakeInvalidRequest
+	}
+
+	_, wErr := c.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"))
+
+	if wErr != nil {
+		return nil, wErr
+	}
+
+	return newSocket(c, reader, false), nil
+}
+
+// upgrading is an accepted connection which will be upgraded to
+// WebSocket during the first read, so a slow client will not block
+// the accepter.
+//
+// Invalid requests are left to the fallback server when there is one,
+// and the recorder stops once the connection is upgraded, so failures
+// after the upgrade will only be discarded
+type upgrading struct {
+	net.Conn
+
+	path       string
+	recorder   *common.Recorder
+	fallback   bool
+	upgraded   *socket
+	upgradeErr error
+}
+
+// Read upgrades the connection if it's not been upgraded, then reads
+// data from it
+func (u *upgrading) Read(b []byte) (int, error) {
+	if u.upgraded == nil && u.upgradeErr == nil {
+		u.upgraded, u.upgradeErr = serverHandshake(
+			u.Conn, u.path, u.fallback)
+
+		if u.upgradeErr == nil {
+			u.recorder.Stop()
+		}
+	}
+
+	if u.upgradeErr != nil {
+		return 0, u.upgradeErr
+	}
+
+	return u.upgraded.Read(b)
+}
+
+// Write writes data to the upgraded connection
+func (u *upgrading) Write(b []byte) (int, error) {
+	if u.upgraded == nil {
+		return 0, ErrHandshakeNotCompleted
+	}
+
+	return u.upgraded.Write(b)
+}
@@ -0,0 +1,223 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package websocket
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nickrio/coward/roles/common/network/communicator/common"
+	"github.com/nickrio/coward/roles/common/network/transporter"
+)
+
+// testFailingConn fails only after it received some data, so the
+// upgrade will be completed before the failure
+type testFailingConn struct {
+	net.Conn
+}
+
+func (t testFailingConn) Read(b []byte) (int, error) {
+	_, rErr := t.Conn.Read(b)
+
+	if rErr != nil {
+		return 0, rErr
+	}
+
+	return 0, errors.New("Decode failed")
+}
+
+func testMustListenFallback(
+	failure common.FailurePolicy) transporter.ServerConnAccepter {
+	accepter, listenErr := NewServer(net.ParseIP("127.0.0.1"), 0,
+		time.Second, 10*time.Second, func(c net.Conn) (net.Conn, error) {
+			return testFailingConn{Conn: c}, nil
+		}, "/ws", nil, failure).Listen()
+
+	if listenErr != nil {
+		panic(listenErr)
+	}
+
+	go func() {
+		for {
+			conn, acceptErr := accepter.Accept()
+
+			if acceptErr != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				conn.Read(make([]byte, 16))
+			}()
+		}
+	}()
+
+	return accepter
+}
+
+func testMustListenFallbackServer() *net.TCPListener {
+	listener, listenErr := net.ListenTCP("tcp", &net.TCPAddr{
+		IP: net.ParseIP("127.0.0.1"),
+	})
+
+	if listenErr != nil {
+		panic(listenErr)
+	}
+
+	return listener
+}
+
+func TestServerFallback(t *testing.T) {
+	fallback := testMustListenFallbackServer()
+
+	defer fallback.Close()
+
+	go func() {
+		fallbackConn, acceptErr := fallback.Accept()
+
+		if acceptErr != nil {
+			return
+		}
+
+		defer fallbackConn.Close()
+
+		req, reqErr := http.ReadRequest(bufio.NewReader(fallbackConn))
+
+		if reqErr != nil {
+			return
+		}
+
+		fallbackConn.Write([]byte("HTTP/1.1 200 OK\r\n" +
+			"Content-Length: " + strconv.Itoa(len(req.URL.Path)) + "\r\n" +
+			"Connection: close\r\n\r\n" + req.URL.Path))
+	}()
+
+	accepter := testMustListenFallback(common.FailurePolicy{
+		Fallback: fallback.Addr().String(),
+	})
+
+	defer accepter.Close()
+
+	raw, dialErr := net.Dial("tcp", accepter.Name())
+
+	if dialErr != nil {
+		t.Error("Can't dial due to error:", dialErr)
+
+		return
+	}
+
+	defer raw.Close()
+
+	raw.Write([]byte("GET /index.html HTTP/1.1\r\n" +
+		"Host: example.com\r\n\r\n"))
+
+	resp, respErr := http.ReadResponse(bufio.NewReader(raw), nil)
+
+	if respErr != nil {
+		t.Error("Can't read response due to error:", respErr)
+
+		return
+	}
+
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK || string(body) != "/index.html" {
+		t.Errorf("Expecting the fallback response, got %d %q",
+			resp.StatusCode, body)
+
+		return
+	}
+}
+
+func TestServerFailedAfterUpgrade(t *testing.T) {
+	fallback := testMustListenFallbackServer()
+
+	defer fallback.Close()
+
+	policy := common.FailurePolicy{
+		MinWait:  100 * time.Millisecond,
+		MaxWait:  200 * time.Millisecond,
+		Fallback: fallback.Addr().String(),
+	}
+	accepter := testMustListenFallback(policy)
+
+	defer accepter.Close()
+
+	raw, dialErr := net.Dial("tcp", accepter.Name())
+
+	if dialErr != nil {
+		t.Error("Can't dial due to error:", dialErr)
+
+		return
+	}
+
+	defer raw.Close()
+
+	client, handshakeErr := clientHandshake(raw, "example.com", "/ws")
+
+	if handshakeErr != nil {
+		t.Error("Can't handshake due to error:", handshakeErr)
+
+		return
+	}
+
+	start := time.Now()
+
+	client.Write([]byte("Invalid data"))
+
+	// The upgrade has been answered, so the connection can't be
+	// forwarded anymore. It will be discarded instead
+	_, rErr := ioutil.ReadAll(client)
+
+	if rErr != nil {
+		t.Error("Can't read due to error:", rErr)
+
+		return
+	}
+
+	if time.Since(start) < policy.MinWait {
+		t.Errorf("Expecting to wait at least %s, waited %s",
+			policy.MinWait, time.Since(start))
+
+		return
+	}
+
+	fallback.SetDeadline(time.Now().Add(100 * time.Millisecond))
+
+	forwarded, acceptErr := fallback.Accept()
+
+	if acceptErr == nil {
+		forwarded.Close()
+
+		t.Error("Expecting the connection not to be forwarded")
+
+		return
+	}
+}
@@ -0,0 +1,325 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// WebSocket errors
+var (
+	ErrInvalidFrame = errors.New(
+		"Invalid WebSocket frame")
+
+	ErrUnsupportedFrame = errors.New(
+		"Unsupported WebSocket frame")
+)
+
+// opcode is the type of a WebSocket frame
+type opcode byte
+
+// Opcodes
+const (
+	opContinuation opcode = 0x0
+	opText         opcode = 0x1
+	opBinary       opcode = 0x2
+	opClose        opcode = 0x8
+	opPing         opcode = 0x9
+	opPong         opcode = 0xa
+)
+
+// Frame settings
+const (
+	frameFin            = 0x80
+	frameMasked         = 0x80
+	frameMaxHeaderSize  = 14
+	frameMaxControlSize = 125
+)
+
+// socket carries data inside WebSocket binary messages.
+//
+// Frame format:
+//
+// +-----+-----+--------+------+----------+----------+---------+
+// | FIN | RSV | OPCODE | MASK | LENGTH   | MASK KEY | PAYLOAD |
+// +-----+-----+--------+------+----------+----------+---------+
+// | 1b  | 3b  |   4b   |  1b  | 7b - 71b | 0 or 4B  |   ...   |
+// +-----+-----+--------+------+----------+----------+---------+
+//
+// Every Write will be sent as one binary frame. Frames written by the
+// client are masked as required by RFC 6455
+type socket struct {
+	net.Conn
+
+	reader      *bufio.Reader
+	mask        bool
+	remain      uint64
+	readMask    [4]byte
+	readMasked  bool
+	readMaskIdx int
+	control     [frameMaxControlSize]byte
+	writeBuf    []byte
+	writeLock   sync.Mutex
+}
+
+// newSocket creates a new socket. The reader must be the one which
+// has been used during handshake, as it may already contains data of
+// the first frames
+func newSocket(c net.Conn, reader *bufio.Reader, mask bool) *socket {
+	return &socket{
+		Conn:        c,
+		reader:      reader,
+		mask:        mask,
+		remain:      0,
+		readMask:    [4]byte{},
+		readMasked:  false,
+		readMaskIdx: 0,
+		control:     [frameMaxControlSize]byte{},
+		writeBuf:    nil,
+		writeLock:   sync.Mutex{},
+	}
+}
+
+// readHeader reads the header of next frame
+func (s *socket) readHeader() (opcode, uint64, error) {
+	head := [8]byte{}
+
+	_, rErr := io.ReadFull(s.reader, head[:2])
+
+	if rErr != nil {
+		return 0, 0, rErr
+	}
+
+	op := opcode(head[0] & 0x0f)
+	masked := head[1]&frameMasked != 0
+	length := uint64(head[1] & 0x7f)
+
+	switch length {
+	case 126:
+		_, rErr = io.ReadFull(s.reader, head[:2])
+
+		if rErr != nil {
+			return 0, 0, rErr
+		}
+
+		length = uint64(binary.BigEndian.Uint16(head[:2]))
+
+	case 127:
+		_, rErr = io.ReadFull(s.reader, head[:8])
+
+		if rErr != nil {
+			return 0, 0, rErr
+		}
+
+		length = binary.BigEndian.Uint64(head[:8])
+	}
+
+	s.readMasked = masked
+	s.readMaskIdx = 0
+
+	if masked {
+		_, rErr = io.ReadFull(s.reader, s.readMask[:])
+
+		if rErr != nil {
+			return 0, 0, rErr
+		}
+	}
+
+	return op, length, nil
+}
+
+// readPayload reads payload of current frame
+func (s *socket) readPayload(b []byte) (int, error) {
+	rLen, rErr := s.reader.Read(b)
+
+	if !s.readMasked {
+		return rLen, rErr
+	}
+
+	for i := 0; i < rLen; i++ {
+		b[i] ^= s.readMask[s.readMaskIdx%4]
+
+		s.readMaskIdx++
+	}
+
+	return rLen, rErr
+}
+
+// handleControl handles a control frame
+func (s *socket) handleControl(op opcode, length uint64) error {
+	if length > frameMaxControlSize {
+		return ErrInvalidFrame
+	}
+
+	payload := s.control[:length]
+
+	_, rErr := io.ReadFull(readerFunc(s.readPayload), payload)
+
+	if rErr != nil {
+		return rErr
+	}
+
+	switch op {
+	case opClose:
+		return io.EOF
+
+	case opPing:
+		_, wErr := s.writeFrame(opPong, payload)
+
+		return wErr
+	}
+
+	return nil
+}
+
+// Read reads payload of data frames
+func (s *socket) Read(b []byte) (int, error) {
+	for s.remain <= 0 {
+		op, length, hErr := s.readHeader()
+
+		if hErr != nil {
+			return 0, hErr
+		}
+
+		switch op {
+		case opContinuation:
+			fallthrough
+		case opText:
+			fallthrough
+		case opBinary:
+			s.remain = length
+
+		case opClose:
+			fallthrough
+		case opPing:
+			fallthrough
+		case opPong:
+			controlErr := s.handleControl(op, length)
+
+			if controlErr != nil {
+				return 0, controlErr
+			}
+
+		default:
+			return 0, ErrUnsupportedFrame
+		}
+	}
+
+	if uint64(len(b)) > s.remain {
+		b = b[:s.remain]
+	}
+
+	rLen, rErr := s.readPayload(b)
+
+	s.remain -= uint64(rLen)
+
+	return rLen, rErr
+}
+
+// writeFrame writes one frame
+func (s *socket) writeFrame(op opcode, b []byte) (int, error) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	size := frameMaxHeaderSize + len(b)
+
+	if len(s.writeBuf) < size {
+		s.writeBuf = make([]byte, size)
+	}
+
+	buf := s.writeBuf
+	buf[0] = frameFin | byte(op)
+	headLen := 2
+
+	switch {
+	case len(b) < 126:
+		buf[1] = byte(len(b))
+
+	case len(b) <= 0xffff:
+		buf[1] = 126
+
+		binary.BigEndian.PutUint16(buf[2:4], uint16(len(b)))
+
+		headLen = 4
+
+	default:
+		buf[1] = 127
+
+		binary.BigEndian.PutUint64(buf[2:10], uint64(len(b)))
+
+		headLen = 10
+	}
+
+	if !s.mask {
+		copy(buf[headLen:], b)
+
+		_, wErr := s.Conn.Write(buf[:headLen+len(b)])
+
+		if wErr != nil {
+			return 0, wErr
+		}
+
+		return len(b), nil
+	}
+
+	buf[1] |= frameMasked
+
+	maskKey := buf[headLen : headLen+4]
+
+	_, rErr := rand.Read(maskKey)
+
+	if rErr != nil {
+		return 0, rErr
+	}
+
+	payload := buf[headLen+4 : headLen+4+len(b)]
+
+	for i := range b {
+		payload[i] = b[i] ^ maskKey[i%4]
+	}
+
+	_, wErr := s.Conn.Write(buf[:headLen+4+len(b)])
+
+	if wErr != nil {
+		return 0, wErr
+	}
+
+	return len(b), nil
+}
+
+// Write writes data as a binary frame
+func (s *socket) Write(b []byte) (int, error) {
+	return s.writeFrame(opBinary, b)
+}
+
+// readerFunc turns a function into io.Reader
+type readerFunc func([]byte) (int, error)
+
+// Read calls the function
+func (r readerFunc) Read(b []byte) (int, error) {
+	return r(b)
+}
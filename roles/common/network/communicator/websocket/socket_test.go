@@ -0,0 +1,175 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func testMustConnect() (net.Conn, net.Conn) {
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+
+	if listenErr != nil {
+		panic(listenErr)
+	}
+
+	defer listener.Close()
+
+	accepted := make(chan net.Conn)
+
+	go func() {
+		conn, acceptErr := listener.Accept()
+
+		if acceptErr != nil {
+			panic(acceptErr)
+		}
+
+		accepted <- conn
+	}()
+
+	client, dialErr := net.Dial("tcp", listener.Addr().String())
+
+	if dialErr != nil {
+		panic(dialErr)
+	}
+
+	return client, <-accepted
+}
+
+func testMustUpgrade(path string) (*socket, *socket) {
+	clientRaw, serverRaw := testMustConnect()
+	server := make(chan *socket)
+
+	go func() {
+		s, sErr := serverHandshake(serverRaw, path, false)
+
+		if sErr != nil {
+			panic(sErr)
+		}
+
+		server <- s
+	}()
+
+	client, cErr := clientHandshake(clientRaw, "example.com", path)
+
+	if cErr != nil {
+		panic(cErr)
+	}
+
+	return client, <-server
+}
+
+func TestSocketReadWrite(t *testing.T) {
+	client, server := testMustUpgrade("/ws")
+
+	defer client.Close()
+	defer server.Close()
+
+	for _, size := range []int{1, 125, 126, 65535, 65536, 100000} {
+		data := make([]byte, size)
+
+		rand.Read(data)
+
+		for _, pair := range [][]*socket{{client, server}, {server, client}} {
+			go func(writer *socket) {
+				// Ping should be answered by the reader, and Pong should
+				// be ignored
+				writer.writeFrame(opPing, []byte("Ping"))
+				writer.Write(data)
+			}(pair[0])
+
+			result := make([]byte, size)
+
+			_, rErr := io.ReadFull(pair[1], result)
+
+			if rErr != nil {
+				t.Error("Can't read due to error:", rErr)
+
+				return
+			}
+
+			if !bytes.Equal(data, result) {
+				t.Errorf("Data of size %d is corrupted", size)
+
+				return
+			}
+		}
+	}
+}
+
+func TestSocketClose(t *testing.T) {
+	client, server := testMustUpgrade("/")
+
+	defer client.Close()
+	defer server.Close()
+
+	go client.writeFrame(opClose, nil)
+
+	_, rErr := server.Read(make([]byte, 1))
+
+	if rErr != io.EOF {
+		t.Errorf("Expecting error %s, got %v", io.EOF, rErr)
+
+		return
+	}
+}
+
+func TestHandshakeInvalidRequest(t *testing.T) {
+	clientRaw, serverRaw := testMustConnect()
+
+	defer clientRaw.Close()
+
+	go func() {
+		serverHandshake(serverRaw, "/ws", false)
+
+		serverRaw.Close()
+	}()
+
+	_, wErr := clientRaw.Write([]byte(
+		"GET /ws HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+
+	if wErr != nil {
+		t.Error("Can't write due to error:", wErr)
+
+		return
+	}
+
+	resp, respErr := http.ReadResponse(bufio.NewReader(clientRaw), nil)
+
+	if respErr != nil {
+		t.Error("Can't read response due to error:", respErr)
+
+		return
+	}
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expecting status %d, got %d",
+			http.StatusNotFound, resp.StatusCode)
+
+		return
+	}
+}
@@ -0,0 +1,176 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package conn
+
+import (
+	"bufio"
+	"compress/flate"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// CompressedStatus is the data counts of a Compressed CONN
+type CompressedStatus struct {
+	Written           uint64
+	WrittenCompressed uint64
+	Read              uint64
+	ReadCompressed    uint64
+}
+
+// compressedCounter counts data which passed through it
+type compressedCounter struct {
+	net.Conn
+
+	read    *uint64
+	written *uint64
+}
+
+// compressed compresses data with deflate
+type compressed struct {
+	net.Conn
+
+	reader    io.ReadCloser
+	writer    *flate.Writer
+	buffer    *bufio.Writer
+	status    CompressedStatus
+	closeOnce sync.Once
+	onClose   func(status CompressedStatus)
+}
+
+// NewCompressed creates a new Compressed CONN. onClose will be called
+// with the data counts once when the conn is closed
+func NewCompressed(
+	raw net.Conn,
+	level int,
+	onClose func(status CompressedStatus),
+) (net.Conn, error) {
+	c := &compressed{
+		Conn:      raw,
+		reader:    nil,
+		writer:    nil,
+		buffer:    nil,
+		status:    CompressedStatus{},
+		closeOnce: sync.Once{},
+		onClose:   onClose,
+	}
+
+	counter := &compressedCounter{
+		Conn:    raw,
+		read:    &c.status.ReadCompressed,
+		written: &c.status.WrittenCompressed,
+	}
+
+	// Collect the output of flate into a buffer, so one flush will
+	// only result one Write to the raw conn
+	c.buffer = bufio.NewWriter(counter)
+
+	writer, writerErr := flate.NewWriter(c.buffer, level)
+
+	if writerErr != nil {
+		return nil, writerErr
+	}
+
+	c.writer = writer
+	c.reader = flate.NewReader(counter)
+
+	return c, nil
+}
+
+// Read read from conn
+func (c *compressedCounter) Read(b []byte) (int, error) {
+	rLen, rErr := c.Conn.Read(b)
+
+	atomic.AddUint64(c.read, uint64(rLen))
+
+	return rLen, rErr
+}
+
+// Write write to conn
+func (c *compressedCounter) Write(b []byte) (int, error) {
+	wLen, wErr := c.Conn.Write(b)
+
+	atomic.AddUint64(c.written, uint64(wLen))
+
+	return wLen, wErr
+}
+
+// Read read from conn
+func (c *compressed) Read(b []byte) (int, error) {
+	rLen, rErr := c.reader.Read(b)
+
+	atomic.AddUint64(&c.status.Read, uint64(rLen))
+
+	// Remote will never finish the deflate stream, it just close
+	// the connection
+	if rErr == io.ErrUnexpectedEOF {
+		rErr = io.EOF
+	}
+
+	return rLen, rErr
+}
+
+// Write write to conn
+func (c *compressed) Write(b []byte) (int, error) {
+	wLen, wErr := c.writer.Write(b)
+
+	if wErr != nil {
+		return wLen, wErr
+	}
+
+	// Flush every write, so the remote can get the data right away
+	// instead of waiting for the buffer to be filled
+	flushErr := c.writer.Flush()
+
+	if flushErr != nil {
+		return wLen, flushErr
+	}
+
+	flushErr = c.buffer.Flush()
+
+	if flushErr != nil {
+		return wLen, flushErr
+	}
+
+	atomic.AddUint64(&c.status.Written, uint64(wLen))
+
+	return wLen, nil
+}
+
+// Close closes the conn
+func (c *compressed) Close() error {
+	c.closeOnce.Do(func() {
+		if c.onClose == nil {
+			return
+		}
+
+		c.onClose(CompressedStatus{
+			Written: atomic.LoadUint64(&c.status.Written),
+			WrittenCompressed: atomic.LoadUint64(
+				&c.status.WrittenCompressed),
+			Read:           atomic.LoadUint64(&c.status.Read),
+			ReadCompressed: atomic.LoadUint64(&c.status.ReadCompressed),
+		})
+	})
+
+	return c.Conn.Close()
+}
@@ -0,0 +1,103 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package conn
+
+import (
+	"compress/flate"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCompressedInteractive(t *testing.T) {
+	left, right := net.Pipe()
+	closed := make(chan CompressedStatus, 1)
+
+	writer, writerErr := NewCompressed(left, flate.BestCompression,
+		func(status CompressedStatus) {
+			closed <- status
+		})
+
+	if writerErr != nil {
+		t.Error("Failed to create writer due to error:", writerErr)
+
+		return
+	}
+
+	reader, readerErr := NewCompressed(right, flate.BestCompression, nil)
+
+	if readerErr != nil {
+		t.Error("Failed to create reader due to error:", readerErr)
+
+		return
+	}
+
+	defer reader.Close()
+
+	// Each small write must be readable right away, as an interactive
+	// relay will not write again until it's been answered
+	for _, data := range []string{"Hello", "World", "!"} {
+		written := make(chan error, 1)
+
+		go func(data []byte) {
+			_, wErr := writer.Write(data)
+
+			written <- wErr
+		}([]byte(data))
+
+		right.SetReadDeadline(time.Now().Add(time.Second))
+
+		readBuf := make([]byte, len(data))
+
+		_, rErr := io.ReadFull(reader, readBuf)
+
+		if rErr != nil {
+			t.Errorf("Can't read %q due to error: %s", data, rErr)
+
+			return
+		}
+
+		if string(readBuf) != data {
+			t.Errorf("Expecting %q, got %q", data, readBuf)
+
+			return
+		}
+
+		wErr := <-written
+
+		if wErr != nil {
+			t.Errorf("Can't write %q due to error: %s", data, wErr)
+
+			return
+		}
+	}
+
+	writer.Close()
+
+	status := <-closed
+
+	if status.Written != 11 || status.WrittenCompressed <= 0 {
+		t.Errorf("Unexpected status: %+v", status)
+
+		return
+	}
+}
@@ -0,0 +1,124 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package conn
+
+import (
+	"io"
+	"net"
+	"sync"
+
+	"github.com/nickrio/coward/common/codec"
+)
+
+// Exchanger exchanges keys through the raw conn, and returns
+// Streamers for reading and writing, and the name of the key which
+// the remote is using
+type Exchanger func(rw io.ReadWriter) (
+	reader codec.Streamer, writer codec.Streamer, identity string, err error)
+
+// exchanged exchanges keys before the first Read or Write, then
+// encodes conn data with the exchanged keys
+type exchanged struct {
+	net.Conn
+
+	exchanger Exchanger
+	lock      sync.Mutex
+	exchanged bool
+	err       error
+	identity  string
+	reader    io.Reader
+	writer    io.Writer
+}
+
+// NewExchanged creates a new Exchanged CONN
+func NewExchanged(raw net.Conn, exchanger Exchanger) net.Conn {
+	return &exchanged{
+		Conn:      raw,
+		exchanger: exchanger,
+		lock:      sync.Mutex{},
+		exchanged: false,
+		err:       nil,
+		identity:  "",
+		reader:    nil,
+		writer:    nil,
+	}
+}
+
+// exchange exchanges keys if they're not been exchanged yet
+func (c *exchanged) exchange() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.exchanged {
+		return c.err
+	}
+
+	c.exchanged = true
+
+	reader, writer, identity, exchangeErr := c.exchanger(c.Conn)
+
+	if exchangeErr != nil {
+		c.err = exchangeErr
+
+		return exchangeErr
+	}
+
+	c.identity = identity
+
+	c.reader = codec.NewReader(reader, c.Conn)
+	c.writer = codec.NewWriter(writer, c.Conn)
+
+	return nil
+}
+
+// Read read from conn
+func (c *exchanged) Read(p []byte) (int, error) {
+	exchangeErr := c.exchange()
+
+	if exchangeErr != nil {
+		return 0, exchangeErr
+	}
+
+	return c.reader.Read(p)
+}
+
+// Write write to conn
+func (c *exchanged) Write(p []byte) (int, error) {
+	exchangeErr := c.exchange()
+
+	if exchangeErr != nil {
+		return 0, exchangeErr
+	}
+
+	return c.writer.Write(p)
+}
+
+// Identity returns the name of the key which the remote is using
+func (c *exchanged) Identity() (string, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.exchanged || c.err != nil {
+		return "", false
+	}
+
+	return c.identity, true
+}
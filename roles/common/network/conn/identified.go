@@ -0,0 +1,44 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package conn
+
+import "net"
+
+// identified is a conn which knows the name of the key that the
+// remote is using
+type identified struct {
+	net.Conn
+
+	identity func() (string, bool)
+}
+
+// NewIdentified creates a new Identified CONN
+func NewIdentified(raw net.Conn, identity func() (string, bool)) net.Conn {
+	return &identified{
+		Conn:     raw,
+		identity: identity,
+	}
+}
+
+// Identity returns the name of the key which the remote is using
+func (c *identified) Identity() (string, bool) {
+	return c.identity()
+}
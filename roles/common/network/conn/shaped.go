@@ -0,0 +1,432 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package conn
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// ShapedHeaderSize is the size of the head of each shaped frame
+	ShapedHeaderSize = 4
+
+	// ShapedMaxFrameSize is the max size of a shaped frame
+	ShapedMaxFrameSize = ShapedHeaderSize + 0xffff
+
+	// shapedSaltSize is the size of the salt in the shaped stream head
+	shapedSaltSize = 16
+
+	// shapedTagSize is the size of the tag in the shaped stream head
+	shapedTagSize = 16
+
+	// shapedHeadSize is the size of the shaped stream head
+	shapedHeadSize = shapedSaltSize + shapedTagSize
+)
+
+// Shaped errors
+var (
+	ErrShapedInvalidFrame = errors.New(
+		"Invalid shaped frame")
+
+	ErrShapedInvalidHead = errors.New(
+		"Invalid shaped stream head")
+
+	ErrShapedNoKey = errors.New(
+		"Shaped stream requires at least one key")
+)
+
+// Labels used to separate HMAC and HKDF results which been calculated
+// with the same key
+var (
+	shapedTagLabel  = []byte("shaped tag")
+	shapedMaskLabel = []byte("shaped mask")
+)
+
+// ShapedSize is a frame size and it's weight in the distribution
+type ShapedSize struct {
+	Size   int
+	Weight int
+}
+
+// ShapedConfig is the shape of the traffic. Frame sizes will be picked
+// from Sizes according to their weight, or evenly between Min and Max
+// when Sizes is empty. Picked sizes will always be limited between
+// Min and Max.
+//
+// Jitter is the max random delay before each write, which will not be
+// applied to every frame the write has been split into
+type ShapedConfig struct {
+	Sizes  []ShapedSize
+	Min    int
+	Max    int
+	Jitter time.Duration
+}
+
+// shaped pads or splits data into frames which matches given size
+// distribution.
+//
+// Each direction begins with a head, which is carried by the first
+// frame:
+//
+// +------+-----+
+// | SALT | TAG |
+// +------+-----+
+// |  16  | 16  |
+// +------+-----+
+//
+// TAG authenticates the SALT with the key, so the reader can find out
+// which of the keys the writer is using.
+//
+// Frame format:
+//
+// +-------------+----------------+------+---------+
+// | DATA LENGTH | PADDING LENGTH | DATA | PADDING |
+// +-------------+----------------+------+---------+
+// |      2      |       2        |  ?   |    ?    |
+// +-------------+----------------+------+---------+
+//
+// DATA LENGTH and PADDING LENGTH are masked by a key stream which is
+// derived from the key and the SALT, so they look as random as the
+// PADDING does
+type shaped struct {
+	net.Conn
+
+	config      ShapedConfig
+	totalWeight int
+	rand        *rand.Rand
+	keys        [][]byte
+	selected    int
+	keyLock     sync.Mutex
+	writeMask   cipher.Stream
+	readMask    cipher.Stream
+	frame       []byte
+	remain      []byte
+	readBuf     []byte
+}
+
+// NewShaped creates a new Shaped CONN. Frame heads will be masked with
+// the first key, or the key which the remote is using once it's known.
+// Remote can use any of the keys
+func NewShaped(c net.Conn, config ShapedConfig, keys [][]byte) net.Conn {
+	totalWeight := 0
+
+	for _, size := range config.Sizes {
+		totalWeight += size.Weight
+	}
+
+	return &shaped{
+		Conn:        c,
+		config:      config,
+		totalWeight: totalWeight,
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		keys:        keys,
+		selected:    -1,
+		keyLock:     sync.Mutex{},
+		writeMask:   nil,
+		readMask:    nil,
+		frame:       make([]byte, shapedHeadSize+ShapedMaxFrameSize),
+		remain:      nil,
+		readBuf:     make([]byte, ShapedMaxFrameSize),
+	}
+}
+
+// shapedTag calculates the tag of the salt
+func shapedTag(key []byte, salt []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+
+	// hash.Hash never returns an error
+	mac.Write(shapedTagLabel)
+	mac.Write(salt)
+
+	return mac.Sum(nil)[:shapedTagSize]
+}
+
+// shapedMask builds the key stream which masks frame heads
+func shapedMask(key []byte, salt []byte) (cipher.Stream, error) {
+	maskKey := make([]byte, 32)
+
+	_, rErr := io.ReadFull(
+		hkdf.New(sha256.New, key, salt, shapedMaskLabel), maskKey)
+
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	block, blockErr := aes.NewCipher(maskKey)
+
+	if blockErr != nil {
+		return nil, blockErr
+	}
+
+	// Every stream has it's own salt thus it's own key, so the IV can
+	// be a fixed one
+	return cipher.NewCTR(block, make([]byte, block.BlockSize())), nil
+}
+
+// key returns the key which will be used to write the head
+func (s *shaped) key() []byte {
+	s.keyLock.Lock()
+	defer s.keyLock.Unlock()
+
+	if s.selected < 0 {
+		return s.keys[0]
+	}
+
+	return s.keys[s.selected]
+}
+
+// head builds the head of write direction into the frame buffer
+func (s *shaped) head() error {
+	if len(s.keys) <= 0 {
+		return ErrShapedNoKey
+	}
+
+	key := s.key()
+	salt := s.frame[:shapedSaltSize]
+
+	_, rErr := io.ReadFull(crand.Reader, salt)
+
+	if rErr != nil {
+		return rErr
+	}
+
+	copy(s.frame[shapedSaltSize:shapedHeadSize], shapedTag(key, salt))
+
+	mask, maskErr := shapedMask(key, salt)
+
+	if maskErr != nil {
+		return maskErr
+	}
+
+	s.writeMask = mask
+
+	return nil
+}
+
+// readHead reads the head of read direction and selects the key which
+// the head is tagged with
+func (s *shaped) readHead() error {
+	head := [shapedHeadSize]byte{}
+
+	_, rErr := io.ReadFull(s.Conn, head[:])
+
+	if rErr != nil {
+		return rErr
+	}
+
+	salt := head[:shapedSaltSize]
+
+	for idx, key := range s.keys {
+		if !hmac.Equal(shapedTag(key, salt), head[shapedSaltSize:]) {
+			continue
+		}
+
+		mask, maskErr := shapedMask(key, salt)
+
+		if maskErr != nil {
+			return maskErr
+		}
+
+		s.keyLock.Lock()
+		s.selected = idx
+		s.keyLock.Unlock()
+
+		s.readMask = mask
+
+		return nil
+	}
+
+	return ErrShapedInvalidHead
+}
+
+// size picks a frame size
+func (s *shaped) size() int {
+	size := s.config.Min
+
+	if s.totalWeight > 0 {
+		picked := s.rand.Intn(s.totalWeight)
+
+		for _, candidate := range s.config.Sizes {
+			if picked < candidate.Weight {
+				size = candidate.Size
+
+				break
+			}
+
+			picked -= candidate.Weight
+		}
+	} else if s.config.Max > s.config.Min {
+		size += s.rand.Intn(s.config.Max - s.config.Min + 1)
+	}
+
+	if size < s.config.Min {
+		size = s.config.Min
+	}
+
+	if size > s.config.Max {
+		size = s.config.Max
+	}
+
+	if size <= ShapedHeaderSize {
+		size = ShapedHeaderSize + 1
+	}
+
+	if size > ShapedMaxFrameSize {
+		size = ShapedMaxFrameSize
+	}
+
+	return size
+}
+
+// jitter waits for a random period before a write
+func (s *shaped) jitter() {
+	if s.config.Jitter <= 0 {
+		return
+	}
+
+	time.Sleep(time.Duration(s.rand.Int63n(int64(s.config.Jitter) + 1)))
+}
+
+// Write write to conn
+func (s *shaped) Write(b []byte) (int, error) {
+	totalWLen := 0
+
+	s.jitter()
+
+	for totalWLen < len(b) {
+		headLen := 0
+		frameSize := s.size()
+
+		// The first frame carries the stream head, it will be as large
+		// as other frames unless it's too small to carry the head
+		if s.writeMask == nil {
+			headErr := s.head()
+
+			if headErr != nil {
+				return totalWLen, headErr
+			}
+
+			headLen = shapedHeadSize
+
+			if frameSize <= shapedHeadSize+ShapedHeaderSize {
+				frameSize = shapedHeadSize + ShapedHeaderSize + 1
+			}
+		}
+
+		frame := s.frame[headLen:]
+		frameSize -= headLen
+		dataLen := frameSize - ShapedHeaderSize
+
+		if dataLen > len(b)-totalWLen {
+			dataLen = len(b) - totalWLen
+		}
+
+		paddingLen := frameSize - ShapedHeaderSize - dataLen
+
+		binary.BigEndian.PutUint16(frame[0:2], uint16(dataLen))
+		binary.BigEndian.PutUint16(frame[2:4], uint16(paddingLen))
+
+		s.writeMask.XORKeyStream(
+			frame[:ShapedHeaderSize], frame[:ShapedHeaderSize])
+
+		copy(frame[ShapedHeaderSize:], b[totalWLen:totalWLen+dataLen])
+
+		_, rErr := io.ReadFull(crand.Reader,
+			frame[ShapedHeaderSize+dataLen:frameSize])
+
+		if rErr != nil {
+			return totalWLen, rErr
+		}
+
+		_, wErr := s.Conn.Write(s.frame[:headLen+frameSize])
+
+		if wErr != nil {
+			return totalWLen, wErr
+		}
+
+		totalWLen += dataLen
+	}
+
+	return totalWLen, nil
+}
+
+// Read read from conn
+func (s *shaped) Read(b []byte) (int, error) {
+	if s.readMask == nil {
+		headErr := s.readHead()
+
+		if headErr != nil {
+			return 0, headErr
+		}
+	}
+
+	for len(s.remain) <= 0 {
+		_, rErr := io.ReadFull(s.Conn, s.readBuf[:ShapedHeaderSize])
+
+		if rErr != nil {
+			return 0, rErr
+		}
+
+		s.readMask.XORKeyStream(
+			s.readBuf[:ShapedHeaderSize], s.readBuf[:ShapedHeaderSize])
+
+		dataLen := int(binary.BigEndian.Uint16(s.readBuf[0:2]))
+		paddingLen := int64(binary.BigEndian.Uint16(s.readBuf[2:4]))
+
+		if dataLen <= 0 && paddingLen <= 0 {
+			return 0, ErrShapedInvalidFrame
+		}
+
+		_, rErr = io.ReadFull(s.Conn, s.readBuf[:dataLen])
+
+		if rErr != nil {
+			return 0, rErr
+		}
+
+		_, rErr = io.CopyN(ioutil.Discard, s.Conn, paddingLen)
+
+		if rErr != nil {
+			return 0, rErr
+		}
+
+		s.remain = s.readBuf[:dataLen]
+	}
+
+	copied := copy(b, s.remain)
+
+	s.remain = s.remain[copied:]
+
+	return copied, nil
+}
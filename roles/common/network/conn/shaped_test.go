@@ -0,0 +1,206 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package conn
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+type testShapedRecorder struct {
+	net.Conn
+
+	sizes  []int
+	frames [][]byte
+}
+
+func (r *testShapedRecorder) Write(b []byte) (int, error) {
+	r.sizes = append(r.sizes, len(b))
+	r.frames = append(r.frames, append([]byte{}, b...))
+
+	return r.Conn.Write(b)
+}
+
+func TestShapedReadWrite(t *testing.T) {
+	config := ShapedConfig{
+		Sizes: []ShapedSize{
+			{Size: 64, Weight: 3},
+			{Size: 576, Weight: 2},
+			{Size: 1400, Weight: 5},
+		},
+		Min: 64,
+		Max: 1400,
+	}
+	testData := [][]byte{
+		[]byte("Hello"),
+		bytes.Repeat([]byte("Test data is here"), 1000),
+		[]byte("World"),
+	}
+
+	left, right := net.Pipe()
+	recorder := &testShapedRecorder{Conn: left}
+	writer := NewShaped(recorder, config, [][]byte{[]byte("Key 2")})
+	reader := NewShaped(right, config, [][]byte{
+		[]byte("Key 1"), []byte("Key 2")})
+	written := make(chan bool)
+
+	go func() {
+		defer close(written)
+
+		for _, data := range testData {
+			_, wErr := writer.Write(data)
+
+			if wErr != nil {
+				return
+			}
+		}
+	}()
+
+	for _, data := range testData {
+		readBuf := make([]byte, len(data))
+
+		_, rErr := io.ReadFull(reader, readBuf)
+
+		if rErr != nil {
+			t.Error("Can't read due to error:", rErr)
+
+			return
+		}
+
+		if !bytes.Equal(data, readBuf) {
+			t.Errorf("Failed to read expected data, expecting %v, got %v",
+				data, readBuf)
+
+			return
+		}
+	}
+
+	<-written
+
+	for _, size := range recorder.sizes {
+		if size != 64 && size != 576 && size != 1400 {
+			t.Errorf("Unexpected frame size %d", size)
+
+			return
+		}
+	}
+
+	// Frame head of "Hello" must not be sent as it is
+	firstFrame := recorder.frames[0]
+	plainHead := []byte{0, 5, 0, byte(len(firstFrame) - shapedHeadSize -
+		ShapedHeaderSize - 5)}
+
+	if bytes.Equal(firstFrame[shapedHeadSize:shapedHeadSize+
+		ShapedHeaderSize], plainHead) {
+		t.Error("Frame head is not masked")
+
+		return
+	}
+
+	// Reply must be written with the key which the writer is using
+	go reader.Write([]byte("Reply"))
+
+	replyBuf := make([]byte, 5)
+
+	_, rErr := io.ReadFull(writer, replyBuf)
+
+	if rErr != nil {
+		t.Error("Can't read reply due to error:", rErr)
+
+		return
+	}
+
+	if string(replyBuf) != "Reply" {
+		t.Errorf("Expecting reply %q, got %q", "Reply", replyBuf)
+
+		return
+	}
+}
+
+func TestShapedJitter(t *testing.T) {
+	config := ShapedConfig{
+		Sizes:  []ShapedSize{{Size: 64, Weight: 1}},
+		Min:    64,
+		Max:    64,
+		Jitter: 20 * time.Millisecond,
+	}
+
+	left, right := net.Pipe()
+	recorder := &testShapedRecorder{Conn: left}
+	writer := NewShaped(recorder, config, [][]byte{[]byte("Key")})
+
+	defer left.Close()
+	defer right.Close()
+
+	go io.Copy(ioutil.Discard, right)
+
+	startTime := time.Now()
+
+	_, wErr := writer.Write(bytes.Repeat([]byte("Test data"), 512))
+
+	if wErr != nil {
+		t.Error("Can't write due to error:", wErr)
+
+		return
+	}
+
+	// Only the write waits for the jitter, not each of it's frames
+	elapsed := time.Now().Sub(startTime)
+
+	if len(recorder.sizes) < 50 {
+		t.Errorf("Expecting the write to be split into at least 50 "+
+			"frames, got %d", len(recorder.sizes))
+
+		return
+	}
+
+	if elapsed > 10*config.Jitter {
+		t.Errorf("Expecting the write to wait no more than %s, took %s",
+			config.Jitter, elapsed)
+
+		return
+	}
+}
+
+func TestShapedInvalidKey(t *testing.T) {
+	config := ShapedConfig{
+		Min: 64,
+		Max: 1400,
+	}
+
+	left, right := net.Pipe()
+	writer := NewShaped(left, config, [][]byte{[]byte("Key 1")})
+	reader := NewShaped(right, config, [][]byte{[]byte("Key 2")})
+
+	go writer.Write([]byte("Hello"))
+
+	_, rErr := reader.Read(make([]byte, 5))
+
+	if rErr != ErrShapedInvalidHead {
+		t.Errorf("Expecting error %s, got %v", ErrShapedInvalidHead, rErr)
+
+		return
+	}
+}
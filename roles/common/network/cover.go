@@ -0,0 +1,96 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"math/rand"
+	"time"
+
+	rbuffer "github.com/nickrio/coward/roles/common/buffer"
+	"github.com/nickrio/coward/roles/common/network/buffer"
+	"github.com/nickrio/coward/roles/common/network/messaging"
+	"github.com/nickrio/coward/roles/common/network/transporter"
+)
+
+const (
+	// MaxCoverSize is the max size of random data which a NOP message
+	// can carry
+	MaxCoverSize = rbuffer.Len
+
+	// DefaultCoverSize is the default max size of random data which a
+	// NOP message will carry
+	DefaultCoverSize = 256
+)
+
+// cover sends a NOP message which carries random data. The remote
+// will drop the message without reply
+type cover struct {
+	messaging.Messaging
+
+	server  transporter.HandlerConfig
+	buffer  buffer.Slice
+	maxSize int
+	rand    *rand.Rand
+}
+
+// NewCover creates a cover request builder which sends NOP messages
+// with no more than maxSize bytes of random data
+func NewCover(maxSize uint16) transporter.HandlerBuilder {
+	coverRand := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	return func(config transporter.HandlerConfig) transporter.Handler {
+		return &cover{
+			server:  config,
+			buffer:  config.Buffer,
+			maxSize: int(maxSize),
+			rand:    coverRand,
+		}
+	}
+}
+
+// Handle sends the NOP message
+func (c *cover) Handle() error {
+	maxSize := c.maxSize
+
+	if maxSize > len(c.buffer.Client.Buffer) {
+		maxSize = len(c.buffer.Client.Buffer)
+	}
+
+	data := c.buffer.Client.Buffer[:c.rand.Intn(maxSize+1)]
+
+	// math/rand.Rand.Read never returns an error
+	c.rand.Read(data)
+
+	_, wErr := c.Write(c.server.Server, messaging.NOP, data,
+		c.buffer.Client.ExtendedBuffer)
+
+	return wErr
+}
+
+// Error returns the error as is
+func (c *cover) Error(err error) (bool, bool, error) {
+	return false, err != nil, err
+}
+
+// Close closes the request
+func (c *cover) Close() error {
+	return nil
+}
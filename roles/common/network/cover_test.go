@@ -0,0 +1,78 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/nickrio/coward/roles/common/network/buffer"
+	"github.com/nickrio/coward/roles/common/network/messaging"
+	"github.com/nickrio/coward/roles/common/network/transporter"
+)
+
+func TestCoverSize(t *testing.T) {
+	buf := &buffer.Buffer{}
+
+	for _, maxSize := range []uint16{0, 64, MaxCoverSize * 2} {
+		builder := NewCover(maxSize)
+		expectedMax := int(maxSize)
+
+		if expectedMax > MaxCoverSize {
+			expectedMax = MaxCoverSize
+		}
+
+		for i := 0; i < 100; i++ {
+			written := &bytes.Buffer{}
+
+			handleErr := builder(transporter.HandlerConfig{
+				Server: written,
+				Buffer: buf.Slice(),
+			}).Handle()
+
+			if handleErr != nil {
+				t.Error("Failed to send cover due to error:", handleErr)
+
+				return
+			}
+
+			message := written.Bytes()
+
+			if len(message) < messaging.HeadSize ||
+				message[0] != byte(messaging.NOP) {
+				t.Errorf("Expecting a NOP message, got %v", message)
+
+				return
+			}
+
+			size := int(binary.BigEndian.Uint16(message[1:3]))
+
+			if size > expectedMax ||
+				len(message) != messaging.HeadSize+size {
+				t.Errorf("Expecting a NOP message with no more than %d "+
+					"bytes, got %d", expectedMax, size)
+
+				return
+			}
+		}
+	}
+}
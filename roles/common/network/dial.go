@@ -0,0 +1,208 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// Dial errors
+var (
+	ErrDialNoAddress = errors.New(
+		"No address to dial")
+)
+
+const (
+	// DialAttemptDelay is the recommended delay between two connection
+	// attempts of DialRace (RFC8305)
+	DialAttemptDelay = 250 * time.Millisecond
+)
+
+// dialResult is the result of a connection attempt
+type dialResult struct {
+	index int
+	conn  net.Conn
+	err   error
+}
+
+// InterleaveIPs puts IPv6 and IPv4 addresses in turn, begins with the
+// family of the first address. Addresses of the same family will stay
+// in their original order
+func InterleaveIPs(ips []net.IP) []net.IP {
+	ipv6 := make([]net.IP, 0, len(ips))
+	ipv4 := make([]net.IP, 0, len(ips))
+
+	for _, ip := range ips {
+		if ip.To4() == nil {
+			ipv6 = append(ipv6, ip)
+		} else {
+			ipv4 = append(ipv4, ip)
+		}
+	}
+
+	first, second := ipv6, ipv4
+
+	if len(ips) > 0 && ips[0].To4() != nil {
+		first, second = ipv4, ipv6
+	}
+
+	interleaved := make([]net.IP, 0, len(ips))
+
+	for len(first) > 0 || len(second) > 0 {
+		if len(first) > 0 {
+			interleaved = append(interleaved, first[0])
+			first = first[1:]
+		}
+
+		if len(second) > 0 {
+			interleaved = append(interleaved, second[0])
+			second = second[1:]
+		}
+	}
+
+	return interleaved
+}
+
+// Routable returns whether or not current host has a route to the IP.
+// No packet will be sent during the check
+func Routable(ip net.IP) bool {
+	return RouteSource(ip) != nil
+}
+
+// RouteSource returns the local address which will be used to reach
+// the IP, or nil when current host has no route for it. No packet will
+// be sent during the check
+func RouteSource(ip net.IP) net.IP {
+	conn, dialErr := net.DialUDP("udp", nil, &net.UDPAddr{
+		IP:   ip,
+		Port: 9,
+	})
+
+	if dialErr != nil {
+		return nil
+	}
+
+	defer conn.Close()
+
+	return conn.LocalAddr().(*net.UDPAddr).IP
+}
+
+// DialRace dials to the addresses Happy-Eyeballs style: The attempt to
+// the next address will be started when the previous one has failed or
+// didn't complete after attemptDelay. The first established connection
+// will be returned, and the late ones will be closed.
+// report will be called with the result of every attempt completed
+// before DialRace returns, it can be nil
+func DialRace(
+	dialType string,
+	addresses []string,
+	attemptDelay time.Duration,
+	timeout time.Duration,
+	report func(index int, err error),
+) (net.Conn, error) {
+	if len(addresses) <= 0 {
+		return nil, ErrDialNoAddress
+	}
+
+	deadline := time.Now().Add(timeout)
+	results := make(chan dialResult, len(addresses))
+	started := 0
+	failed := 0
+
+	start := func() {
+		index := started
+
+		started++
+
+		go func() {
+			conn, dialErr := net.DialTimeout(dialType, addresses[index],
+				deadline.Sub(time.Now()))
+
+			results <- dialResult{index: index, conn: conn, err: dialErr}
+		}()
+	}
+
+	start()
+
+	attemptTimer := time.NewTimer(attemptDelay)
+
+	defer attemptTimer.Stop()
+
+	for {
+		select {
+		case result := <-results:
+			if report != nil {
+				report(result.index, result.err)
+			}
+
+			if result.err == nil {
+				// Close the connections of other pending attempts
+				go func(pending int) {
+					for ; pending > 0; pending-- {
+						late := <-results
+
+						if late.err == nil {
+							late.conn.Close()
+						}
+					}
+				}(started - failed - 1)
+
+				return result.conn, nil
+			}
+
+			failed++
+
+			if started < len(addresses) {
+				start()
+
+				// Drain the timer before reset it, otherwise an expired
+				// timer will start the next attempt right away
+				if !attemptTimer.Stop() {
+					select {
+					case <-attemptTimer.C:
+					default:
+					}
+				}
+
+				attemptTimer.Reset(attemptDelay)
+
+				continue
+			}
+
+			if failed < started {
+				continue
+			}
+
+			return nil, result.err
+
+		case <-attemptTimer.C:
+			if started >= len(addresses) {
+				continue
+			}
+
+			start()
+
+			attemptTimer.Reset(attemptDelay)
+		}
+	}
+}
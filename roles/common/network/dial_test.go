@@ -0,0 +1,105 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestInterleaveIPs(t *testing.T) {
+	interleaved := InterleaveIPs([]net.IP{
+		net.ParseIP("10.0.0.1"),
+		net.ParseIP("10.0.0.2"),
+		net.ParseIP("10.0.0.3"),
+		net.ParseIP("fd00::1"),
+	})
+
+	expected := []string{"10.0.0.1", "fd00::1", "10.0.0.2", "10.0.0.3"}
+
+	for idx, ip := range interleaved {
+		if ip.String() == expected[idx] {
+			continue
+		}
+
+		t.Errorf("Expecting address %d will be %s, got %s",
+			idx, expected[idx], ip)
+
+		return
+	}
+}
+
+func TestDialRace(t *testing.T) {
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+
+	if listenErr != nil {
+		t.Error("Failed to listen due to error:", listenErr)
+
+		return
+	}
+
+	defer listener.Close()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	reported := map[int]bool{}
+
+	// Nothing is listening on 127.0.0.2
+	conn, dialErr := DialRace("tcp", []string{
+		net.JoinHostPort("127.0.0.2", port),
+		net.JoinHostPort("127.0.0.1", port),
+	}, time.Second, time.Second, func(index int, err error) {
+		reported[index] = err == nil
+	})
+
+	if dialErr != nil {
+		t.Error("Failed to dial due to error:", dialErr)
+
+		return
+	}
+
+	conn.Close()
+
+	if reported[0] || !reported[1] {
+		t.Errorf("Unexpected attempt results: %v", reported)
+
+		return
+	}
+
+	_, dialErr = DialRace("tcp", []string{
+		net.JoinHostPort("127.0.0.2", port),
+		net.JoinHostPort("127.0.0.3", port),
+	}, time.Second, time.Second, nil)
+
+	if dialErr == nil {
+		t.Error("Expecting dial to fail")
+
+		return
+	}
+
+	_, dialErr = DialRace("tcp", nil, time.Second, time.Second, nil)
+
+	if dialErr != ErrDialNoAddress {
+		t.Errorf("Expecting error %s, got %v", ErrDialNoAddress, dialErr)
+
+		return
+	}
+}
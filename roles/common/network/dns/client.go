@@ -0,0 +1,298 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Client errors
+var (
+	ErrNoServer = errors.New(
+		"No DNS server is available")
+
+	ErrUnsupportedNetwork = errors.New(
+		"DNS query can only be sent through udp or tcp")
+)
+
+const (
+	// DefaultPort is the default port of DNS servers
+	DefaultPort = "53"
+
+	// resolvConf is where system DNS servers are defined
+	resolvConf = "/etc/resolv.conf"
+)
+
+// Client sends DNS queries to DNS servers
+type Client interface {
+	Query(name string, t Type) ([]Record, error)
+}
+
+// client implements Client
+type client struct {
+	servers []string
+	network string
+	timeout time.Duration
+}
+
+// NewClient creates a new DNS Client. Queries will be sent to servers
+// in order until one of them replied
+func NewClient(
+	servers []string,
+	network string,
+	timeout time.Duration,
+) (Client, error) {
+	if network != "udp" && network != "tcp" {
+		return nil, ErrUnsupportedNetwork
+	}
+
+	if len(servers) <= 0 {
+		return nil, ErrNoServer
+	}
+
+	c := &client{
+		servers: make([]string, 0, len(servers)),
+		network: network,
+		timeout: timeout,
+	}
+
+	for _, server := range servers {
+		c.servers = append(c.servers, ServerAddress(server))
+	}
+
+	return c, nil
+}
+
+// ServerAddress appends the default port to the server address if
+// the port is not defined
+func ServerAddress(server string) string {
+	_, _, splitErr := net.SplitHostPort(server)
+
+	if splitErr == nil {
+		return server
+	}
+
+	return net.JoinHostPort(strings.Trim(server, "[]"), DefaultPort)
+}
+
+// SystemServers returns DNS servers defined in the system
+// configuration
+func SystemServers() []string {
+	servers := []string{}
+
+	file, openErr := os.Open(resolvConf)
+
+	if openErr != nil {
+		return servers
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+
+		if net.ParseIP(fields[1]) == nil {
+			continue
+		}
+
+		servers = append(servers, ServerAddress(fields[1]))
+	}
+
+	return servers
+}
+
+// Query sends the query to servers and returns resolved records.
+// ErrNameNotFound will be returned when the name does not exist
+func (c *client) Query(name string, t Type) ([]Record, error) {
+	var lastErr error
+
+	id := [2]byte{}
+
+	_, rErr := io.ReadFull(rand.Reader, id[:])
+
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	query, queryErr := buildQuery(
+		binary.BigEndian.Uint16(id[:]), name, t)
+
+	if queryErr != nil {
+		return nil, queryErr
+	}
+
+	for _, server := range c.servers {
+		records, exchangeErr := c.exchange(server, c.network, query, t)
+
+		switch exchangeErr {
+		case nil:
+			return records, nil
+
+		case ErrNameNotFound:
+			return nil, exchangeErr
+		}
+
+		lastErr = exchangeErr
+	}
+
+	return nil, lastErr
+}
+
+// exchange sends the query to the server and reads the reply
+func (c *client) exchange(
+	server string,
+	network string,
+	query []byte,
+	t Type,
+) ([]Record, error) {
+	id := binary.BigEndian.Uint16(query[0:2])
+
+	conn, dialErr := net.DialTimeout(network, server, c.timeout)
+
+	if dialErr != nil {
+		return nil, dialErr
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(c.timeout))
+
+	if network == "tcp" {
+		return c.exchangeStream(conn, id, query, t)
+	}
+
+	_, wErr := conn.Write(query)
+
+	if wErr != nil {
+		return nil, wErr
+	}
+
+	buf := [maxMessageSize]byte{}
+
+	for {
+		rLen, rErr := conn.Read(buf[:])
+
+		if rErr != nil {
+			return nil, rErr
+		}
+
+		records, truncated, parseErr := parseReply(buf[:rLen], id, t)
+
+		// Ignore replies which is not for us
+		if parseErr == ErrUnexpectedMessage {
+			continue
+		}
+
+		if parseErr != nil {
+			return nil, parseErr
+		}
+
+		if truncated {
+			return c.exchange(server, "tcp", query, t)
+		}
+
+		return records, nil
+	}
+}
+
+// exchangeStream sends the query through a stream connection, where
+// messages are prefixed by their length
+func (c *client) exchangeStream(
+	conn net.Conn,
+	id uint16,
+	query []byte,
+	t Type,
+) ([]Record, error) {
+	buf := [maxMessageSize + 2]byte{}
+
+	binary.BigEndian.PutUint16(buf[:2], uint16(len(query)))
+	copy(buf[2:], query)
+
+	_, wErr := conn.Write(buf[:len(query)+2])
+
+	if wErr != nil {
+		return nil, wErr
+	}
+
+	_, rErr := io.ReadFull(conn, buf[:2])
+
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	replyLen := int(binary.BigEndian.Uint16(buf[:2]))
+
+	_, rErr = io.ReadFull(conn, buf[:replyLen])
+
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	records, _, parseErr := parseReply(buf[:replyLen], id, t)
+
+	return records, parseErr
+}
+
+// Lookup queries both A and AAAA records of the name at the same
+// time. Error will only be returned when both queries are failed
+func Lookup(c Client, name string) ([]Record, error) {
+	type result struct {
+		records []Record
+		err     error
+	}
+
+	results := make(chan result, 2)
+
+	for _, t := range []Type{AAAA, A} {
+		go func(t Type) {
+			records, queryErr := c.Query(name, t)
+
+			results <- result{records: records, err: queryErr}
+		}(t)
+	}
+
+	first := <-results
+	second := <-results
+
+	if first.err != nil && second.err != nil {
+		if first.err == ErrNameNotFound {
+			return nil, first.err
+		}
+
+		return nil, second.err
+	}
+
+	return append(first.records, second.records...), nil
+}
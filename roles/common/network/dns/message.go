@@ -0,0 +1,250 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// Message errors
+var (
+	ErrInvalidName = errors.New(
+		"Invalid domain name")
+
+	ErrInvalidMessage = errors.New(
+		"Invalid DNS message")
+
+	ErrUnexpectedMessage = errors.New(
+		"Unexpected DNS message")
+
+	ErrNameNotFound = errors.New(
+		"Domain name not found")
+
+	ErrServerFailure = errors.New(
+		"DNS server failed to resolve the name")
+)
+
+// Type is the type of a DNS record
+type Type uint16
+
+// Supported record types
+const (
+	A    Type = 1
+	AAAA Type = 28
+)
+
+const (
+	headerSize      = 12
+	classINET       = 1
+	flagResponse    = 1 << 15
+	flagTruncated   = 1 << 9
+	flagRecursion   = 1 << 8
+	rcodeMask       = 0x000f
+	rcodeNameError  = 3
+	maxNameLength   = 253
+	maxLabelLength  = 63
+	maxMessageSize  = 65535
+	maxUDPReplySize = 512
+)
+
+// Record is a resolved address and how long it can be cached
+type Record struct {
+	IP  net.IP
+	TTL time.Duration
+}
+
+// buildQuery builds a recursive query of given name and record type
+//
+// Query format:
+//
+// +--------+-------------------------------+
+// | HEADER |            QUESTION           |
+// +--------+-------+--------+--------------+
+// |        | QNAME |  QTYPE |    QCLASS    |
+// +--------+-------+--------+--------------+
+// |   12   | Vary  |    2   |      2       |
+// +--------+-------+--------+--------------+
+func buildQuery(id uint16, name string, t Type) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+
+	if len(name) <= 0 || len(name) > maxNameLength {
+		return nil, ErrInvalidName
+	}
+
+	query := make([]byte, headerSize, headerSize+len(name)+6)
+
+	binary.BigEndian.PutUint16(query[0:2], id)
+	binary.BigEndian.PutUint16(query[2:4], flagRecursion)
+	binary.BigEndian.PutUint16(query[4:6], 1) // QDCOUNT
+
+	for _, label := range strings.Split(name, ".") {
+		if len(label) <= 0 || len(label) > maxLabelLength {
+			return nil, ErrInvalidName
+		}
+
+		query = append(query, byte(len(label)))
+		query = append(query, label...)
+	}
+
+	query = append(query, 0,
+		byte(t>>8), byte(t), 0, classINET)
+
+	return query, nil
+}
+
+// skipName returns the position right after the name starts at
+// offset
+func skipName(message []byte, offset int) (int, error) {
+	for {
+		if offset >= len(message) {
+			return 0, ErrInvalidMessage
+		}
+
+		labelLen := int(message[offset])
+
+		switch {
+		case labelLen == 0:
+			return offset + 1, nil
+
+		case labelLen&0xc0 == 0xc0:
+			// Compression pointer, which always ends the name
+			if offset+2 > len(message) {
+				return 0, ErrInvalidMessage
+			}
+
+			return offset + 2, nil
+
+		case labelLen > maxLabelLength:
+			return 0, ErrInvalidMessage
+		}
+
+		offset += labelLen + 1
+	}
+}
+
+// parseReply parses a reply of the query with given id and record
+// type. truncated will be true when the reply needs to be fetched
+// again through TCP
+func parseReply(
+	message []byte,
+	id uint16,
+	t Type,
+) (records []Record, truncated bool, err error) {
+	if len(message) < headerSize {
+		return nil, false, ErrInvalidMessage
+	}
+
+	flags := binary.BigEndian.Uint16(message[2:4])
+
+	if binary.BigEndian.Uint16(message[0:2]) != id ||
+		flags&flagResponse == 0 {
+		return nil, false, ErrUnexpectedMessage
+	}
+
+	if flags&flagTruncated != 0 {
+		return nil, true, nil
+	}
+
+	switch flags & rcodeMask {
+	case 0:
+
+	case rcodeNameError:
+		return nil, false, ErrNameNotFound
+
+	default:
+		return nil, false, ErrServerFailure
+	}
+
+	questions := int(binary.BigEndian.Uint16(message[4:6]))
+	answers := int(binary.BigEndian.Uint16(message[6:8]))
+	offset := headerSize
+
+	for i := 0; i < questions; i++ {
+		nameEnd, skipErr := skipName(message, offset)
+
+		if skipErr != nil {
+			return nil, false, skipErr
+		}
+
+		offset = nameEnd + 4 // QTYPE + QCLASS
+	}
+
+	records = make([]Record, 0, answers)
+
+	// Answer format:
+	//
+	// +------+------+-------+-----+----------+----------+
+	// | NAME | TYPE | CLASS | TTL | RDLENGTH |  RDATA   |
+	// +------+------+-------+-----+----------+----------+
+	// | Vary |  2   |   2   |  4  |    2     | RDLENGTH |
+	// +------+------+-------+-----+----------+----------+
+	for i := 0; i < answers; i++ {
+		nameEnd, skipErr := skipName(message, offset)
+
+		if skipErr != nil {
+			return nil, false, skipErr
+		}
+
+		if nameEnd+10 > len(message) {
+			return nil, false, ErrInvalidMessage
+		}
+
+		rType := Type(binary.BigEndian.Uint16(message[nameEnd:]))
+		rClass := binary.BigEndian.Uint16(message[nameEnd+2:])
+		rTTL := binary.BigEndian.Uint32(message[nameEnd+4:])
+		rDataLen := int(binary.BigEndian.Uint16(message[nameEnd+8:]))
+		rData := nameEnd + 10
+
+		if rData+rDataLen > len(message) {
+			return nil, false, ErrInvalidMessage
+		}
+
+		offset = rData + rDataLen
+
+		// CNAME records are followed by the recursive server, only
+		// the addresses are needed
+		if rType != t || rClass != classINET {
+			continue
+		}
+
+		switch {
+		case t == A && rDataLen == net.IPv4len:
+		case t == AAAA && rDataLen == net.IPv6len:
+		default:
+			return nil, false, ErrInvalidMessage
+		}
+
+		ip := make(net.IP, rDataLen)
+
+		copy(ip, message[rData:offset])
+
+		records = append(records, Record{
+			IP:  ip,
+			TTL: time.Duration(rTTL) * time.Second,
+		})
+	}
+
+	return records, false, nil
+}
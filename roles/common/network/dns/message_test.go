@@ -0,0 +1,139 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func testBuildReply(query []byte, flags uint16, answers ...[]byte) []byte {
+	reply := make([]byte, len(query))
+
+	copy(reply, query)
+
+	reply[2] = byte((flags | flagResponse) >> 8)
+	reply[3] = byte(flags | flagResponse)
+	reply[6] = 0
+	reply[7] = byte(len(answers))
+
+	for _, answer := range answers {
+		reply = append(reply, answer...)
+	}
+
+	return reply
+}
+
+func TestParseReply(t *testing.T) {
+	query, queryErr := buildQuery(1234, "www.example.com.", A)
+
+	if queryErr != nil {
+		t.Error("Failed to build query due to error:", queryErr)
+
+		return
+	}
+
+	reply := testBuildReply(query, flagRecursion,
+		// www.example.com CNAME example.com, name is a pointer to
+		// the question
+		[]byte{0xc0, 12, 0, 5, 0, 1, 0, 0, 0, 60, 0, 2, 0xc0, 16},
+		// example.com A 192.0.2.1, TTL 300
+		[]byte{0xc0, 16, 0, 1, 0, 1, 0, 0, 1, 44, 0, 4, 192, 0, 2, 1},
+		// example.com A 192.0.2.2, TTL 30
+		[]byte{0xc0, 16, 0, 1, 0, 1, 0, 0, 0, 30, 0, 4, 192, 0, 2, 2})
+
+	records, truncated, parseErr := parseReply(reply, 1234, A)
+
+	if parseErr != nil || truncated {
+		t.Errorf("Failed to parse reply: %v, truncated: %v",
+			parseErr, truncated)
+
+		return
+	}
+
+	expected := []Record{
+		{IP: net.IPv4(192, 0, 2, 1), TTL: 300 * time.Second},
+		{IP: net.IPv4(192, 0, 2, 2), TTL: 30 * time.Second},
+	}
+
+	if len(records) != len(expected) {
+		t.Errorf("Expecting %d records, got %d", len(expected), len(records))
+
+		return
+	}
+
+	for idx := range expected {
+		if !records[idx].IP.Equal(expected[idx].IP) ||
+			records[idx].TTL != expected[idx].TTL {
+			t.Errorf("Expecting record %d will be %v, got %v",
+				idx, expected[idx], records[idx])
+
+			return
+		}
+	}
+
+	_, _, parseErr = parseReply(reply, 4321, A)
+
+	if parseErr != ErrUnexpectedMessage {
+		t.Errorf("Expecting error %s, got %v", ErrUnexpectedMessage, parseErr)
+
+		return
+	}
+
+	_, _, parseErr = parseReply(
+		testBuildReply(query, rcodeNameError), 1234, A)
+
+	if parseErr != ErrNameNotFound {
+		t.Errorf("Expecting error %s, got %v", ErrNameNotFound, parseErr)
+
+		return
+	}
+
+	_, truncated, _ = parseReply(
+		testBuildReply(query, flagTruncated), 1234, A)
+
+	if !truncated {
+		t.Error("Expecting reply will be truncated")
+
+		return
+	}
+
+	_, _, parseErr = parseReply(reply[:len(reply)-2], 1234, A)
+
+	if parseErr != ErrInvalidMessage {
+		t.Errorf("Expecting error %s, got %v", ErrInvalidMessage, parseErr)
+
+		return
+	}
+}
+
+func TestBuildQueryInvalidName(t *testing.T) {
+	for _, name := range []string{"", ".", "a..b", string(make([]byte, 64))} {
+		_, queryErr := buildQuery(1, name, A)
+
+		if queryErr != ErrInvalidName {
+			t.Errorf("Expecting building query for %q will fail", name)
+
+			return
+		}
+	}
+}
@@ -0,0 +1,374 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Preference decides which address family will be put to the front
+type Preference byte
+
+// Preferences
+const (
+	PreferNone Preference = 0
+	PreferIPv4 Preference = 4
+	PreferIPv6 Preference = 6
+)
+
+// Resolver defaults
+const (
+	// DefaultNegativeTTL is how long a name which does not exist will
+	// be remembered
+	DefaultNegativeTTL = 10 * time.Second
+
+	// DefaultCacheSize is how many names will be cached by default
+	DefaultCacheSize = 1024
+
+	// systemTTL is how long the addresses resolved by the system
+	// resolver will be cached, as it don't tell the TTL
+	systemTTL = 60 * time.Second
+
+	minCacheTTL = 1 * time.Second
+	maxCacheTTL = 1 * time.Hour
+)
+
+// ResolverConfig is the configuration of a Resolver
+type ResolverConfig struct {
+	// Client which queries will be sent through, the system resolver
+	// will be used when it's nil
+	Client Client
+
+	// Fallback decides whether or not to resolve through the system
+	// resolver when the name can't be resolved through the Client
+	Fallback    bool
+	Prefer      Preference
+	NegativeTTL time.Duration
+	CacheSize   int
+}
+
+// ResolverStats is the statistics of a Resolver
+type ResolverStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+// Resolver resolves names to IP addresses, and caches the results
+type Resolver interface {
+	Resolve(name string) ([]Record, error)
+	Prefer() Preference
+	Stats() ResolverStats
+}
+
+// cacheEntry is a cached result. ready will be closed once the
+// result is filled
+type cacheEntry struct {
+	records []Record
+	err     error
+	expire  time.Time
+	ready   chan struct{}
+}
+
+// done returns whether or not the entry has been filled
+func (c *cacheEntry) done() bool {
+	select {
+	case <-c.ready:
+		return true
+
+	default:
+		return false
+	}
+}
+
+// result returns the cached records with the TTL they still have. It
+// must be called after the entry is filled
+func (c *cacheEntry) result() ([]Record, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	ttl := c.expire.Sub(time.Now())
+
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	records := make([]Record, len(c.records))
+
+	for idx, record := range c.records {
+		records[idx] = Record{IP: record.IP, TTL: ttl}
+	}
+
+	return records, nil
+}
+
+// IPs returns the addresses of the records
+func IPs(records []Record) []net.IP {
+	ips := make([]net.IP, len(records))
+
+	for idx, record := range records {
+		ips[idx] = record.IP
+	}
+
+	return ips
+}
+
+// resolver implements Resolver
+type resolver struct {
+	prefer      Preference
+	negativeTTL time.Duration
+	cacheSize   int
+	lookup      func(name string) ([]Record, error)
+	entries     map[string]*cacheEntry
+	hits        uint64
+	misses      uint64
+	lock        sync.Mutex
+}
+
+// NewResolver creates a new caching Resolver
+func NewResolver(config ResolverConfig) Resolver {
+	r := &resolver{
+		prefer:      config.Prefer,
+		negativeTTL: config.NegativeTTL,
+		cacheSize:   config.CacheSize,
+		lookup:      lookupSystem,
+		entries:     make(map[string]*cacheEntry, config.CacheSize),
+		hits:        0,
+		misses:      0,
+		lock:        sync.Mutex{},
+	}
+
+	if r.negativeTTL <= 0 {
+		r.negativeTTL = DefaultNegativeTTL
+	}
+
+	if r.cacheSize <= 0 {
+		r.cacheSize = DefaultCacheSize
+	}
+
+	if config.Client != nil {
+		r.lookup = func(name string) ([]Record, error) {
+			records, lookupErr := Lookup(config.Client, name)
+
+			// Names can be defined by the system (e.g. in the hosts
+			// file) without being known to the DNS servers
+			if !config.Fallback ||
+				(lookupErr == nil && len(records) > 0) {
+				return records, lookupErr
+			}
+
+			return lookupSystem(name)
+		}
+	}
+
+	return r
+}
+
+// lookupSystem resolves the name through the system resolver
+func lookupSystem(name string) ([]Record, error) {
+	ips, lookupErr := net.LookupIP(name)
+
+	if lookupErr != nil {
+		dnsErr, isDNSErr := lookupErr.(*net.DNSError)
+
+		if isDNSErr && dnsErr.IsNotFound {
+			return nil, ErrNameNotFound
+		}
+
+		return nil, lookupErr
+	}
+
+	records := make([]Record, len(ips))
+
+	for idx, ip := range ips {
+		records[idx] = Record{IP: ip, TTL: systemTTL}
+	}
+
+	return records, nil
+}
+
+// Resolve returns the address records of the name. The cached result
+// will be returned when it's not expired, with the TTL it has left.
+// Concurrent calls of a same name will share one lookup
+func (r *resolver) Resolve(name string) ([]Record, error) {
+	name = strings.Trim(strings.ToLower(strings.TrimSpace(name)), ".")
+
+	if ip := net.ParseIP(name); ip != nil {
+		return []Record{{IP: ip, TTL: 0}}, nil
+	}
+
+	r.lock.Lock()
+
+	entry, found := r.entries[name]
+
+	if found && (!entry.done() || time.Now().Before(entry.expire)) {
+		r.hits++
+
+		r.lock.Unlock()
+
+		<-entry.ready
+
+		return entry.result()
+	}
+
+	r.misses++
+
+	entry = &cacheEntry{
+		records: nil,
+		err:     nil,
+		expire:  time.Time{},
+		ready:   make(chan struct{}),
+	}
+
+	r.entries[name] = entry
+
+	r.evict()
+
+	r.lock.Unlock()
+
+	records, lookupErr := r.lookup(name)
+
+	if lookupErr == nil && len(records) <= 0 {
+		lookupErr = ErrNameNotFound
+	}
+
+	r.lock.Lock()
+
+	switch lookupErr {
+	case nil:
+		entry.records = r.order(records)
+		entry.expire = time.Now().Add(cacheTTL(records))
+
+	case ErrNameNotFound:
+		entry.err = lookupErr
+		entry.expire = time.Now().Add(r.negativeTTL)
+
+	default:
+		// Don't remember temporary failures
+		entry.err = lookupErr
+
+		if r.entries[name] == entry {
+			delete(r.entries, name)
+		}
+	}
+
+	close(entry.ready)
+
+	r.lock.Unlock()
+
+	return entry.result()
+}
+
+// Prefer returns which address family will be put to the front
+func (r *resolver) Prefer() Preference {
+	return r.prefer
+}
+
+// Stats returns the statistics of the Resolver
+func (r *resolver) Stats() ResolverStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return ResolverStats{
+		Hits:    r.hits,
+		Misses:  r.misses,
+		Entries: len(r.entries),
+	}
+}
+
+// order puts the records of the preferred family to the front
+func (r *resolver) order(records []Record) []Record {
+	preferred := make([]Record, 0, len(records))
+	others := make([]Record, 0, len(records))
+
+	for _, record := range records {
+		isIPv4 := record.IP.To4() != nil
+
+		switch {
+		case r.prefer == PreferIPv4 && isIPv4:
+			fallthrough
+		case r.prefer == PreferIPv6 && !isIPv4:
+			fallthrough
+		case r.prefer == PreferNone:
+			preferred = append(preferred, record)
+
+		default:
+			others = append(others, record)
+		}
+	}
+
+	return append(preferred, others...)
+}
+
+// evict removes expired entries when there are too many of them,
+// then removes random ones if it's still too many. Must be called
+// with the lock held
+func (r *resolver) evict() {
+	if len(r.entries) <= r.cacheSize {
+		return
+	}
+
+	now := time.Now()
+
+	for name, entry := range r.entries {
+		if !entry.done() || now.Before(entry.expire) {
+			continue
+		}
+
+		delete(r.entries, name)
+	}
+
+	for name, entry := range r.entries {
+		if len(r.entries) <= r.cacheSize {
+			break
+		}
+
+		if !entry.done() {
+			continue
+		}
+
+		delete(r.entries, name)
+	}
+}
+
+// cacheTTL returns how long the records can be cached, which is the
+// smallest TTL of them
+func cacheTTL(records []Record) time.Duration {
+	ttl := maxCacheTTL
+
+	for _, record := range records {
+		if record.TTL >= ttl {
+			continue
+		}
+
+		ttl = record.TTL
+	}
+
+	if ttl < minCacheTTL {
+		return minCacheTTL
+	}
+
+	return ttl
+}
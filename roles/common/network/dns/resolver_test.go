@@ -0,0 +1,298 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testNewResolver(
+	prefer Preference,
+	cacheSize int,
+	lookup func(name string) ([]Record, error),
+) *resolver {
+	r := NewResolver(ResolverConfig{
+		Client:      nil,
+		Fallback:    false,
+		Prefer:      prefer,
+		NegativeTTL: 0,
+		CacheSize:   cacheSize,
+	}).(*resolver)
+
+	r.lookup = lookup
+
+	return r
+}
+
+func TestResolverCache(t *testing.T) {
+	lookups := 0
+	r := testNewResolver(PreferNone, 4, func(name string) ([]Record, error) {
+		lookups++
+
+		switch name {
+		case "example.com":
+			return []Record{
+				{IP: net.ParseIP("192.0.2.1"), TTL: time.Minute},
+			}, nil
+
+		case "temporary.example.com":
+			return nil, errors.New("Temporary failure")
+		}
+
+		return nil, ErrNameNotFound
+	})
+
+	for i := 0; i < 3; i++ {
+		records, resolveErr := r.Resolve("Example.COM.")
+
+		if resolveErr != nil {
+			t.Error("Can't resolve due to error:", resolveErr)
+
+			return
+		}
+
+		if len(records) != 1 ||
+			!records[0].IP.Equal(net.ParseIP("192.0.2.1")) {
+			t.Errorf("Unexpected records: %v", records)
+
+			return
+		}
+
+		if records[0].TTL <= 0 || records[0].TTL > time.Minute {
+			t.Errorf("Unexpected TTL: %s", records[0].TTL)
+
+			return
+		}
+
+		_, resolveErr = r.Resolve("nonexistent.example.com")
+
+		if resolveErr != ErrNameNotFound {
+			t.Errorf("Expecting error %s, got %v",
+				ErrNameNotFound, resolveErr)
+
+			return
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		_, resolveErr := r.Resolve("temporary.example.com")
+
+		if resolveErr == nil {
+			t.Error("Expecting an error")
+
+			return
+		}
+	}
+
+	if lookups != 4 {
+		t.Errorf("Expecting %d lookups, got %d", 4, lookups)
+
+		return
+	}
+
+	stats := r.Stats()
+
+	if stats.Hits != 4 || stats.Misses != 4 || stats.Entries != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+
+		return
+	}
+
+	// Expired entry must be looked up again
+	r.entries["example.com"].expire = time.Now()
+
+	r.Resolve("example.com")
+
+	if lookups != 5 {
+		t.Errorf("Expecting %d lookups, got %d", 5, lookups)
+
+		return
+	}
+}
+
+func TestResolverShareLookup(t *testing.T) {
+	lookups := 0
+	release := make(chan struct{})
+	wait := sync.WaitGroup{}
+	r := testNewResolver(PreferNone, 4, func(name string) ([]Record, error) {
+		lookups++
+
+		<-release
+
+		return []Record{{IP: net.ParseIP("192.0.2.1"), TTL: 0}}, nil
+	})
+
+	for i := 0; i < 10; i++ {
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+
+			records, resolveErr := r.Resolve("example.com")
+
+			if resolveErr != nil || len(records) != 1 {
+				t.Errorf("Unexpected result: %v, %v", records, resolveErr)
+			}
+		}()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	close(release)
+
+	wait.Wait()
+
+	if lookups != 1 {
+		t.Errorf("Expecting %d lookup, got %d", 1, lookups)
+
+		return
+	}
+}
+
+func TestResolverPrefer(t *testing.T) {
+	records := []Record{
+		{IP: net.ParseIP("2001:db8::1"), TTL: time.Minute},
+		{IP: net.ParseIP("192.0.2.1"), TTL: time.Minute},
+		{IP: net.ParseIP("2001:db8::2"), TTL: time.Minute},
+		{IP: net.ParseIP("192.0.2.2"), TTL: time.Minute},
+	}
+	tests := map[Preference]string{
+		PreferNone: "2001:db8::1 192.0.2.1 2001:db8::2 192.0.2.2",
+		PreferIPv4: "192.0.2.1 192.0.2.2 2001:db8::1 2001:db8::2",
+		PreferIPv6: "2001:db8::1 2001:db8::2 192.0.2.1 192.0.2.2",
+	}
+
+	for prefer, expected := range tests {
+		resolved, _ := testNewResolver(prefer, 4, func(string) ([]Record, error) {
+			return records, nil
+		}).Resolve("example.com")
+
+		result := ""
+
+		for idx, record := range resolved {
+			if idx > 0 {
+				result += " "
+			}
+
+			result += record.IP.String()
+		}
+
+		if result != expected {
+			t.Errorf("Expecting %s, got %s", expected, result)
+
+			return
+		}
+	}
+}
+
+func TestResolverEvict(t *testing.T) {
+	r := testNewResolver(PreferNone, 2, func(name string) ([]Record, error) {
+		return []Record{{IP: net.ParseIP("192.0.2.1"), TTL: time.Minute}}, nil
+	})
+
+	for _, name := range []string{"a.com", "b.com", "c.com", "d.com"} {
+		r.Resolve(name)
+	}
+
+	if r.Stats().Entries > 2 {
+		t.Errorf("Expecting at most %d entries, got %d",
+			2, r.Stats().Entries)
+
+		return
+	}
+}
+
+func TestResolverTTL(t *testing.T) {
+	r := testNewResolver(PreferNone, 4, func(string) ([]Record, error) {
+		return []Record{
+			{IP: net.ParseIP("192.0.2.1"), TTL: 5 * time.Minute},
+			{IP: net.ParseIP("192.0.2.2"), TTL: 2 * time.Minute},
+		}, nil
+	})
+
+	records, resolveErr := r.Resolve("example.com")
+
+	if resolveErr != nil {
+		t.Error("Can't resolve due to error:", resolveErr)
+
+		return
+	}
+
+	for _, record := range records {
+		if record.TTL <= time.Minute || record.TTL > 2*time.Minute {
+			t.Errorf("Unexpected TTL of %s: %s", record.IP, record.TTL)
+
+			return
+		}
+	}
+
+	// Cached records must only carry the TTL they have left
+	r.entries["example.com"].expire = time.Now().Add(30 * time.Second)
+
+	records, _ = r.Resolve("example.com")
+
+	for _, record := range records {
+		if record.TTL <= 0 || record.TTL > 30*time.Second {
+			t.Errorf("Unexpected TTL of %s: %s", record.IP, record.TTL)
+
+			return
+		}
+	}
+}
+
+type testFailingClient struct{}
+
+func (c testFailingClient) Query(name string, t Type) ([]Record, error) {
+	return nil, errors.New("Query failed")
+}
+
+func TestResolverFallback(t *testing.T) {
+	for _, fallback := range []bool{false, true} {
+		records, resolveErr := NewResolver(ResolverConfig{
+			Client:      testFailingClient{},
+			Fallback:    fallback,
+			Prefer:      PreferNone,
+			NegativeTTL: 0,
+			CacheSize:   0,
+		}).Resolve("localhost")
+
+		if !fallback {
+			if resolveErr == nil {
+				t.Error("Expecting an error without fallback")
+
+				return
+			}
+
+			continue
+		}
+
+		if resolveErr != nil || len(records) <= 0 {
+			t.Errorf("Expecting localhost will be resolved by the "+
+				"system resolver, got %v, %v", records, resolveErr)
+
+			return
+		}
+	}
+}
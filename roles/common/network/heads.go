@@ -0,0 +1,91 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"sync"
+	"time"
+)
+
+// Heads records session heads which been received recently, so a
+// recorded session that been replayed to us can be rejected
+type Heads interface {
+	Accept(head []byte) bool
+}
+
+// heads implements Heads
+type heads struct {
+	expire    time.Duration
+	seen      map[string]time.Time
+	lastSweep time.Time
+	lock      sync.Mutex
+}
+
+// NewHeads creates a new Heads. A head will be remembered until
+// expire duration has passed
+func NewHeads(expire time.Duration) Heads {
+	return &heads{
+		expire:    expire,
+		seen:      make(map[string]time.Time, 256),
+		lastSweep: time.Now(),
+		lock:      sync.Mutex{},
+	}
+}
+
+// sweep removes expired heads
+func (h *heads) sweep(now time.Time) {
+	if now.Sub(h.lastSweep) < h.expire {
+		return
+	}
+
+	for head, expireAt := range h.seen {
+		if now.Before(expireAt) {
+			continue
+		}
+
+		delete(h.seen, head)
+	}
+
+	h.lastSweep = now
+}
+
+// Accept returns true when the head is new, and false when the
+// head has already been received before
+func (h *heads) Accept(head []byte) bool {
+	now := time.Now()
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.sweep(now)
+
+	headKey := string(head)
+
+	expireAt, found := h.seen[headKey]
+
+	if found && now.Before(expireAt) {
+		return false
+	}
+
+	h.seen[headKey] = now.Add(h.expire)
+
+	return true
+}
@@ -0,0 +1,73 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHeadsAccept(t *testing.T) {
+	h := NewHeads(1 * time.Second)
+
+	if !h.Accept([]byte("HEAD1")) {
+		t.Error("Failed to accept a new head")
+
+		return
+	}
+
+	if !h.Accept([]byte("HEAD2")) {
+		t.Error("Failed to accept a new head")
+
+		return
+	}
+
+	if h.Accept([]byte("HEAD1")) {
+		t.Error("Expecting replayed head to be rejected")
+
+		return
+	}
+}
+
+func TestHeadsExpire(t *testing.T) {
+	h := NewHeads(10 * time.Millisecond)
+
+	if !h.Accept([]byte("HEAD1")) {
+		t.Error("Failed to accept a new head")
+
+		return
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if !h.Accept([]byte("HEAD1")) {
+		t.Error("Expecting expired head to be accepted again")
+
+		return
+	}
+
+	if len(h.(*heads).seen) != 1 {
+		t.Errorf("Expecting expired heads to be removed, got %d heads",
+			len(h.(*heads).seen))
+
+		return
+	}
+}
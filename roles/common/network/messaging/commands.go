@@ -48,4 +48,5 @@ const (
 	Unsupported    common.Command = 21
 	Invalid        common.Command = 22
 	UnknownCommand common.Command = 23
+	BindTCP        common.Command = 24 // Actions (Continued)
 )
@@ -0,0 +1,74 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package messaging
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// Ping errors
+var (
+	ErrInvalidPingLatency = errors.New(
+		"Invalid ping latency")
+)
+
+// PingLatencySize is the size of the encoded ping latency
+const PingLatencySize = 4
+
+// Ping Latency format:
+//
+// +-----------+
+// |  LATENCY  |
+// +-----------+
+// |     4     |
+// +-----------+
+//
+// LATENCY: uint32, microseconds spent by the server to connect the
+//          destination
+
+// EncodePingLatency encodes the latency into the buffer
+func EncodePingLatency(latency time.Duration, buf []byte) (int, error) {
+	if len(buf) < PingLatencySize {
+		return 0, ErrInvalidPingLatency
+	}
+
+	microseconds := latency / time.Microsecond
+
+	if microseconds > 0xffffffff {
+		microseconds = 0xffffffff
+	}
+
+	binary.BigEndian.PutUint32(buf[:PingLatencySize], uint32(microseconds))
+
+	return PingLatencySize, nil
+}
+
+// DecodePingLatency decodes the latency from the data
+func DecodePingLatency(data []byte) (time.Duration, error) {
+	if len(data) != PingLatencySize {
+		return 0, ErrInvalidPingLatency
+	}
+
+	return time.Duration(binary.BigEndian.Uint32(data)) * time.Microsecond,
+		nil
+}
@@ -0,0 +1,65 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package messaging
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPingLatency(t *testing.T) {
+	buf := [PingLatencySize]byte{}
+
+	for _, latency := range []time.Duration{
+		0, 1500 * time.Microsecond, 3 * time.Second,
+	} {
+		encodedLen, encodeErr := EncodePingLatency(latency, buf[:])
+
+		if encodeErr != nil {
+			t.Error("Can't encode latency due to error:", encodeErr)
+
+			return
+		}
+
+		decoded, decodeErr := DecodePingLatency(buf[:encodedLen])
+
+		if decodeErr != nil {
+			t.Error("Can't decode latency due to error:", decodeErr)
+
+			return
+		}
+
+		if decoded != latency {
+			t.Errorf("Expecting latency %s, got %s", latency, decoded)
+
+			return
+		}
+	}
+
+	_, decodeErr := DecodePingLatency(buf[:2])
+
+	if decodeErr != ErrInvalidPingLatency {
+		t.Errorf("Expecting error %s, got %v",
+			ErrInvalidPingLatency, decodeErr)
+
+		return
+	}
+}
@@ -0,0 +1,130 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package messaging
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+// Resolve errors
+var (
+	ErrResolveBufferTooSmall = errors.New(
+		"Resolve Buffer is too small")
+
+	ErrInvalidResolveRecord = errors.New(
+		"Invalid resolve record")
+)
+
+// MaxResolveRecords is the max amount of records a ResolveHost reply
+// can carry
+const MaxResolveRecords = 16
+
+// ResolveRecord is an address carried by the ResolveHost reply
+type ResolveRecord struct {
+	IP  net.IP
+	TTL uint32
+}
+
+// ResolveRecord format:
+//
+// +--------+-----+----------+
+// | IPSIZE | TTL |    IP    |
+// +--------+-----+----------+
+// |   1    |  4  |  IPSIZE  |
+// +--------+-----+----------+
+//
+// IPSIZE: 4 for IPv4, 16 for IPv6
+// TTL:    uint32, how many seconds the address stays valid, 0 when
+//         unknown
+
+// EncodeResolveRecords encodes records into the buffer, records
+// beyond MaxResolveRecords will be ignored
+func EncodeResolveRecords(records []ResolveRecord, buf []byte) (int, error) {
+	written := 0
+
+	if len(records) > MaxResolveRecords {
+		records = records[:MaxResolveRecords]
+	}
+
+	for _, record := range records {
+		ip := record.IP.To4()
+
+		if ip == nil {
+			ip = record.IP.To16()
+		}
+
+		if ip == nil {
+			return 0, ErrInvalidResolveRecord
+		}
+
+		recordLen := 5 + len(ip)
+
+		if len(buf[written:]) < recordLen {
+			return 0, ErrResolveBufferTooSmall
+		}
+
+		buf[written] = byte(len(ip))
+
+		binary.BigEndian.PutUint32(buf[written+1:written+5], record.TTL)
+
+		copy(buf[written+5:written+recordLen], ip)
+
+		written += recordLen
+	}
+
+	return written, nil
+}
+
+// DecodeResolveRecords decodes records from the data
+func DecodeResolveRecords(data []byte) ([]ResolveRecord, error) {
+	records := make([]ResolveRecord, 0, MaxResolveRecords)
+
+	for len(data) > 0 {
+		if len(data) < 5 {
+			return nil, ErrInvalidResolveRecord
+		}
+
+		ipLen := int(data[0])
+
+		if ipLen != net.IPv4len && ipLen != net.IPv6len {
+			return nil, ErrInvalidResolveRecord
+		}
+
+		if len(data) < 5+ipLen {
+			return nil, ErrInvalidResolveRecord
+		}
+
+		ip := make(net.IP, ipLen)
+
+		copy(ip, data[5:5+ipLen])
+
+		records = append(records, ResolveRecord{
+			IP:  ip,
+			TTL: binary.BigEndian.Uint32(data[1:5]),
+		})
+
+		data = data[5+ipLen:]
+	}
+
+	return records, nil
+}
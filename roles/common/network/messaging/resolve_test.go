@@ -0,0 +1,90 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package messaging
+
+import (
+	"net"
+	"testing"
+)
+
+func TestResolveRecords(t *testing.T) {
+	records := []ResolveRecord{
+		{IP: net.ParseIP("192.0.2.1"), TTL: 300},
+		{IP: net.ParseIP("2001:db8::1"), TTL: 0},
+	}
+	buf := [64]byte{}
+
+	encodedLen, encodeErr := EncodeResolveRecords(records, buf[:])
+
+	if encodeErr != nil {
+		t.Error("Can't encode records due to error:", encodeErr)
+
+		return
+	}
+
+	if encodedLen != 5+4+5+16 {
+		t.Errorf("Expecting encoded length %d, got %d", 5+4+5+16, encodedLen)
+
+		return
+	}
+
+	decoded, decodeErr := DecodeResolveRecords(buf[:encodedLen])
+
+	if decodeErr != nil {
+		t.Error("Can't decode records due to error:", decodeErr)
+
+		return
+	}
+
+	if len(decoded) != len(records) {
+		t.Errorf("Expecting %d records, got %d", len(records), len(decoded))
+
+		return
+	}
+
+	for idx := range records {
+		if !decoded[idx].IP.Equal(records[idx].IP) ||
+			decoded[idx].TTL != records[idx].TTL {
+			t.Errorf("Expecting record %v, got %v",
+				records[idx], decoded[idx])
+
+			return
+		}
+	}
+
+	_, encodeErr = EncodeResolveRecords(records, buf[:10])
+
+	if encodeErr != ErrResolveBufferTooSmall {
+		t.Errorf("Expecting error %s, got %v",
+			ErrResolveBufferTooSmall, encodeErr)
+
+		return
+	}
+
+	_, decodeErr = DecodeResolveRecords(buf[:encodedLen-1])
+
+	if decodeErr != ErrInvalidResolveRecord {
+		t.Errorf("Expecting error %s, got %v",
+			ErrInvalidResolveRecord, decodeErr)
+
+		return
+	}
+}
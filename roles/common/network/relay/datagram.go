@@ -0,0 +1,298 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"io"
+
+	"github.com/nickrio/coward/common"
+	cbuffer "github.com/nickrio/coward/roles/common/buffer"
+	"github.com/nickrio/coward/roles/common/network/buffer"
+	"github.com/nickrio/coward/roles/common/network/conn"
+	"github.com/nickrio/coward/roles/common/network/messaging"
+)
+
+// datagram is a UDP data exchanger which sends packets through a
+// datagram connection instead of the partner stream. The partner
+// stream is only used to carry control messages
+//
+// Notice:
+//
+// - Here is how we use buffers:
+//
+//   Partner: Read:
+//                t.buffer.Server.Buffer
+//            Write:
+//                t.buffer.Server.ExtendedBuffer
+//
+//   Terminal: Read:
+//                t.buffer.Client.Buffer
+//            Write:
+//                t.packetBuffer.ExtendedBuffer
+//
+//   Datagram: Read:
+//                t.packetBuffer.Buffer
+//            Write:
+//                t.buffer.Client.Buffer
+//
+type datagram struct {
+	messaging.Messaging
+
+	handler      UDPHandler
+	terminal     conn.UDPReadWriteCloser
+	partner      io.ReadWriter
+	datagram     io.ReadWriteCloser
+	buffer       buffer.Slice
+	packetBuffer cbuffer.Pair
+	closeChan    chan bool
+}
+
+// NewDatagramRelay creates a new UDP relay which exchanges packets
+// through the datagram connection
+func NewDatagramRelay(
+	handler UDPHandler,
+	terminal conn.UDPReadWriteCloser,
+	partner io.ReadWriter,
+	datagramConn io.ReadWriteCloser,
+	buffer buffer.Slice,
+	closeChan chan bool,
+) Relay {
+	return &datagram{
+		handler:      handler,
+		terminal:     terminal,
+		partner:      partner,
+		datagram:     datagramConn,
+		buffer:       buffer,
+		packetBuffer: cbuffer.Pair{},
+		closeChan:    closeChan,
+	}
+}
+
+func (d *datagram) passTerminalPacket() error {
+	clientBufferLen := len(d.buffer.Client.Buffer)
+
+	for {
+		exReadLen, exReadErr := d.handler.Receive(
+			d.terminal,
+			d.buffer.Client.Buffer,
+			d.buffer.Server.ExtendedBuffer[:clientBufferLen])
+
+		if exReadErr != nil {
+			return exReadErr
+		}
+
+		_, datagramWriteErr := d.datagram.Write(
+			d.buffer.Client.Buffer[:exReadLen])
+
+		if datagramWriteErr != nil {
+			return datagramWriteErr
+		}
+	}
+}
+
+func (d *datagram) passDatagramPacket() error {
+	forbiddenBuf := [messaging.HeadSize]byte{}
+
+	for {
+		readLen, readErr := d.datagram.Read(d.packetBuffer.Buffer[:])
+
+		if readErr != nil {
+			return readErr
+		}
+
+		// Packets which can't be sent is dropped just like how
+		// it will be on the real network
+		_, sendErr := d.handler.Send(d.terminal,
+			d.packetBuffer.Buffer[:readLen], d.packetBuffer.ExtendedBuffer[:])
+
+		if sendErr != ErrDestinationForbidden {
+			continue
+		}
+
+		_, wErr := d.Write(d.partner, messaging.Forbidden, nil,
+			forbiddenBuf[:])
+
+		if wErr != nil {
+			return wErr
+		}
+	}
+}
+
+func (d *datagram) handlePartnerStream() error {
+	proc := common.NewProccessors().
+		Register(messaging.Forbidden, func(
+			b []byte,
+			rw io.ReadWriter,
+			size uint16,
+		) error {
+			// Errors of a single packet can't be reported through
+			// SOCKS5, so the packet is considered lost
+			if size <= 0 {
+				return nil
+			}
+
+			_, rErr := io.ReadFull(rw, b[:size])
+
+			return rErr
+		}).
+		Register(messaging.Closed, func(
+			b []byte,
+			rw io.ReadWriter,
+			size uint16,
+		) error {
+			d.terminal.Close()
+
+			if size <= 0 {
+				return ErrTerminalConnectionClosed
+			}
+
+			io.ReadFull(rw, b[:size])
+
+			return ErrTerminalConnectionClosed
+		}).
+		Register(messaging.EOF, func(
+			b []byte,
+			rw io.ReadWriter,
+			size uint16,
+		) error {
+			if size <= 0 {
+				return ErrPartnerCurrentSessionCompleted
+			}
+
+			io.ReadFull(rw, b[:size])
+
+			return ErrPartnerCurrentSessionCompleted
+		})
+
+	for {
+		partnerStreamDispatchErr := d.Dispatch(d.partner,
+			d.buffer.Server.Buffer, proc)
+
+		if partnerStreamDispatchErr == nil {
+			continue
+		}
+
+		return partnerStreamDispatchErr
+	}
+}
+
+func (d *datagram) Relay() error {
+	var resultErr error
+	tmpBuf := [8]byte{}
+
+	defer d.datagram.Close()
+
+	readyErr := d.handler.Ready()
+
+	if readyErr != nil {
+		return readyErr
+	}
+
+	parter2terminalErrorChan := make(SignalChan)
+	terminal2datagramErrorChan := make(SignalChan)
+	datagram2terminalErrorChan := make(SignalChan)
+
+	defer func() {
+		close(parter2terminalErrorChan)
+		close(terminal2datagramErrorChan)
+		close(datagram2terminalErrorChan)
+	}()
+
+	go func() {
+		parter2terminalErrorChan <- d.handlePartnerStream()
+	}()
+
+	go func() {
+		terminal2datagramErrorChan <- d.passTerminalPacket()
+	}()
+
+	go func() {
+		datagram2terminalErrorChan <- d.passDatagramPacket()
+	}()
+
+	select {
+	// Partner connection disconnected
+	case resultErr = <-parter2terminalErrorChan:
+		d.terminal.Close()
+		d.datagram.Close()
+
+		<-terminal2datagramErrorChan
+		<-datagram2terminalErrorChan
+
+		switch resultErr {
+		case ErrPartnerCurrentSessionCompleted:
+			// Do nothing
+
+		case ErrTerminalConnectionClosed:
+			d.Write(d.partner, messaging.EOF, nil,
+				d.buffer.Server.ExtendedBuffer)
+		}
+
+	// Terminal connection disconnected
+	case resultErr = <-terminal2datagramErrorChan:
+		d.datagram.Close()
+
+		<-datagram2terminalErrorChan
+
+		d.Write(d.partner, messaging.Closed, nil,
+			d.buffer.Server.ExtendedBuffer)
+
+		// Wait for p2d connection to complete
+		<-parter2terminalErrorChan
+
+	// Datagram connection disconnected
+	case resultErr = <-datagram2terminalErrorChan:
+		d.terminal.Close()
+
+		<-terminal2datagramErrorChan
+
+		d.Write(d.partner, messaging.Closed, nil,
+			d.buffer.Server.ExtendedBuffer)
+
+		<-parter2terminalErrorChan
+
+	// Quitter chan
+	case resultErr = <-d.handler.Quitter():
+		d.Write(d.partner, messaging.Closed, nil, tmpBuf[:])
+
+		<-parter2terminalErrorChan
+
+		d.terminal.Close()
+		d.datagram.Close()
+
+		<-terminal2datagramErrorChan
+		<-datagram2terminalErrorChan
+
+	// Close chan
+	case <-d.closeChan:
+		d.Write(d.partner, messaging.Closed, nil, tmpBuf[:])
+
+		<-parter2terminalErrorChan
+
+		d.terminal.Close()
+		d.datagram.Close()
+
+		<-terminal2datagramErrorChan
+		<-datagram2terminalErrorChan
+	}
+
+	return resultErr
+}
@@ -32,4 +32,7 @@ var (
 
 	ErrRelayQuit = errors.New(
 		"Relay quitted")
+
+	ErrDestinationForbidden = errors.New(
+		"Destination is forbidden")
 )
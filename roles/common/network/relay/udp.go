@@ -97,6 +97,7 @@ func (u *udp) passTerminalPacket() error {
 
 func (u *udp) handlePartnerStream() error {
 	closing := false
+	forbiddenBuf := [messaging.HeadSize]byte{}
 	proc := common.NewProccessors().
 		Register(messaging.Datagram, func(
 			b []byte,
@@ -116,12 +117,34 @@ func (u *udp) handlePartnerStream() error {
 			_, wErr := u.handler.Send(u.terminal, b[:readLen],
 				u.buffer.Client.ExtendedBuffer)
 
+			if wErr == ErrDestinationForbidden {
+				_, fErr := u.Write(u.partner, messaging.Forbidden, nil,
+					forbiddenBuf[:])
+
+				return fErr
+			}
+
 			if wErr != nil {
 				closing = true
 			}
 
 			return nil
 		}).
+		Register(messaging.Forbidden, func(
+			b []byte,
+			rw io.ReadWriter,
+			size uint16,
+		) error {
+			// Errors of a single packet can't be reported through
+			// SOCKS5, so the packet is considered lost
+			if size <= 0 {
+				return nil
+			}
+
+			_, rErr := io.ReadFull(rw, b[:size])
+
+			return rErr
+		}).
 		Register(messaging.Closed, func(
 			b []byte,
 			rw io.ReadWriter,
@@ -23,7 +23,11 @@ package relay
 import "github.com/nickrio/coward/roles/common/network/conn"
 
 // UDPHandler is the UDP data handler since the server and
-// proxy handle data differenctly
+// proxy handle data differenctly.
+//
+// Send returns ErrDestinationForbidden when the packet is not allowed
+// to be sent to it's destination. Only that packet will be dropped,
+// and the partner will be told with a Forbidden message
 type UDPHandler interface {
 	Ready() error
 	Quitter() SignalChan
@@ -22,6 +22,7 @@ package balancer
 
 import (
 	"sync"
+	"time"
 
 	"github.com/nickrio/coward/roles/common/network/transporter"
 	"github.com/nickrio/coward/roles/common/network/transporter/clients"
@@ -35,9 +36,17 @@ type Balancer interface {
 		DelayFeedingbackRequestBuilder,
 		transporter.RequestOption,
 	) error
+	Probe(string, DelayFeedingbackRequestBuilder)
 	Kickoff()
 }
 
+// ProbeTimeout is how long at most a Probe will wait for the replies
+const ProbeTimeout = 3 * time.Second
+
+// ProbeWait is how long at most the first requests to a target will
+// wait for the Probe to pick a transport
+const ProbeWait = 500 * time.Millisecond
+
 // balancer implements Balancer
 type balancer struct {
 	dests destinations
@@ -69,6 +78,17 @@ func (b *balancer) Request(
 	return b.dests.Get(target).Request(builder, option)
 }
 
+// Probe ranks the transports for the target by measuring the latency
+// of the probe request through each one of them. Only the first Probe
+// of the target will actually send the requests, and the Probe will
+// return once one of them replied or ProbeWait has passed
+func (b *balancer) Probe(
+	target string,
+	builder DelayFeedingbackRequestBuilder,
+) {
+	b.dests.Get(target).Probe(builder, ProbeTimeout, ProbeWait)
+}
+
 // Kickoff tears current balancer down
 func (b *balancer) Kickoff() {
 	b.dests.Clear()
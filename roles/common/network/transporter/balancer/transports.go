@@ -23,8 +23,10 @@ package balancer
 import (
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/nickrio/coward/common"
+	"github.com/nickrio/coward/roles/common/network/buffer"
 	"github.com/nickrio/coward/roles/common/network/transporter"
 	"github.com/nickrio/coward/roles/common/network/transporter/clients"
 )
@@ -50,6 +52,8 @@ type transports struct {
 	sorted     []*transport
 	sortLock   sync.RWMutex
 	requesting common.Counter
+	probe      sync.Once
+	ranked     chan struct{}
 }
 
 // iterate iterates through all transports
@@ -118,9 +122,18 @@ func (t *transports) rehead() {
 		return
 	}
 
+	t.promote(newHead)
+}
+
+// promote puts the transport to the head
+func (t *transports) promote(newHead *transport) {
 	t.pole.Lock.Lock()
 	defer t.pole.Lock.Unlock()
 
+	if newHead == t.pole.Head {
+		return
+	}
+
 	newHead.Before(t.pole.Head)
 
 	t.sortLock.Lock()
@@ -164,6 +177,99 @@ func (t *transports) Register(idx int, c clients.Client) {
 	tsp.After(t.pole.Tail)
 }
 
+// Probe starts sending the probe request through all transports at
+// the first call, then waits until the first transport replied or the
+// wait has expired. Requests will be handled by the current head if
+// the probe can't be completed during the wait
+func (t *transports) Probe(
+	builder DelayFeedingbackRequestBuilder,
+	timeout time.Duration,
+	wait time.Duration,
+) {
+	if len(t.transports) <= 1 {
+		return
+	}
+
+	t.probe.Do(func() {
+		t.ranked = make(chan struct{})
+
+		go func() {
+			defer close(t.ranked)
+
+			t.rank(builder, timeout)
+		}()
+	})
+
+	waitTimer := time.NewTimer(wait)
+
+	defer waitTimer.Stop()
+
+	select {
+	case <-t.ranked:
+	case <-waitTimer.C:
+	}
+}
+
+// rank sends the probe request through all transports, and puts the
+// transport which replied first to the head
+func (t *transports) rank(
+	builder DelayFeedingbackRequestBuilder, timeout time.Duration) {
+	probed := make(chan *transport, len(t.transports))
+
+	for _, tsp := range t.transports {
+		go func(tsp *transport) {
+			succeed := false
+			buf := buffer.Buffer{}
+
+			tsp.Client.Request(
+				func(cfg transporter.HandlerConfig) transporter.Handler {
+					return builder(cfg, func(delay time.Duration) {
+						tsp.Delay.Add(delay.Seconds())
+
+						succeed = true
+					})
+				}, transporter.RequestOption{
+					Buffer:    buf.Slice(),
+					Canceller: nil,
+					Delay:     func(float64, uint64) {},
+					Error: func(
+						retry bool, reset bool, err error,
+					) (bool, bool, error) {
+						return false, reset, err
+					},
+				})
+
+			if !succeed {
+				probed <- nil
+
+				return
+			}
+
+			probed <- tsp
+		}(tsp)
+	}
+
+	timeoutTimer := time.NewTimer(timeout)
+
+	defer timeoutTimer.Stop()
+
+	for remaining := len(t.transports); remaining > 0; remaining-- {
+		select {
+		case tsp := <-probed:
+			if tsp == nil {
+				continue
+			}
+
+			t.promote(tsp)
+
+			return
+
+		case <-timeoutTimer.C:
+			return
+		}
+	}
+}
+
 // Request sends request to server
 func (t *transports) Request(
 	builder DelayFeedingbackRequestBuilder,
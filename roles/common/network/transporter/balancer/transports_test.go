@@ -746,3 +746,188 @@ func BenchmarkTransportsRequest100Requests(b *testing.B) {
 		wait.Wait()
 	}
 }
+
+type probeTSPClient struct {
+	*dummyTSPClient
+
+	latency time.Duration
+	failed  bool
+}
+
+func (p *probeTSPClient) Request(
+	builder transporter.HandlerBuilder,
+	option transporter.RequestOption,
+) (bool, error) {
+	time.Sleep(p.latency)
+
+	if p.failed {
+		option.Error(false, false, clients.ErrClientsNotFound)
+
+		return true, clients.ErrClientsNotFound
+	}
+
+	return p.dummyTSPClient.Request(builder, option)
+}
+
+func TestTransportsProbe(t *testing.T) {
+	csNames := []string{}
+	cs := testTransportBuildTSPClients(3)
+	tsps := &transports{
+		pole: transportPole{
+			Head: nil,
+			Tail: nil,
+			Lock: sync.RWMutex{},
+		},
+		transports: make([]*transport, 3),
+		sorted:     make([]*transport, 3),
+		sortLock:   sync.RWMutex{},
+	}
+
+	// A failed, C replies before B
+	tsps.Register(0, &probeTSPClient{
+		dummyTSPClient: cs[0].(*dummyTSPClient),
+		latency:        0,
+		failed:         true,
+	})
+	tsps.Register(1, &probeTSPClient{
+		dummyTSPClient: cs[1].(*dummyTSPClient),
+		latency:        200 * time.Millisecond,
+		failed:         false,
+	})
+	tsps.Register(2, &probeTSPClient{
+		dummyTSPClient: cs[2].(*dummyTSPClient),
+		latency:        10 * time.Millisecond,
+		failed:         false,
+	})
+
+	probeCount := common.NewCounter(0)
+	probeStart := time.Now()
+
+	for i := 0; i < 3; i++ {
+		tsps.Probe(func(
+			cfg transporter.HandlerConfig,
+			delayFeedback func(time.Duration),
+		) transporter.Handler {
+			probeCount.Add(1)
+
+			return &dummyTSPHandler{
+				actualDelay:   false,
+				delay:         10 * time.Millisecond,
+				delayCallback: delayFeedback,
+			}
+		}, time.Second, time.Second)
+	}
+
+	if time.Now().Sub(probeStart) >= 200*time.Millisecond {
+		t.Error("Expecting Probe only waits for the first reply")
+
+		return
+	}
+
+	tsps.pole.Lock.RLock()
+
+	tsps.iterate(func(tsp *transport) bool {
+		csNames = append(csNames, tsp.Client.(*probeTSPClient).name)
+
+		return true
+	})
+
+	tsps.pole.Lock.RUnlock()
+
+	if strings.Join(csNames, " ") != "ClientC ClientA ClientB" {
+		t.Errorf("Failed to expecting probed order %s, got %s",
+			"ClientC ClientA ClientB", strings.Join(csNames, " "))
+
+		return
+	}
+
+	tsps.sortLock.RLock()
+	sortedHeadName := tsps.sorted[0].Client.(*probeTSPClient).name
+	tsps.sortLock.RUnlock()
+
+	if sortedHeadName != "ClientC" {
+		t.Errorf("Failed to expecting sorted head %s, got %s",
+			"ClientC", sortedHeadName)
+
+		return
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	probeCount.Load(func(count uint64) {
+		if count == 2 {
+			return
+		}
+
+		t.Errorf("Expecting %d probe requests, got %d", 2, count)
+	})
+}
+
+func TestTransportsProbeWait(t *testing.T) {
+	cs := testTransportBuildTSPClients(2)
+	tsps := &transports{
+		pole: transportPole{
+			Head: nil,
+			Tail: nil,
+			Lock: sync.RWMutex{},
+		},
+		transports: make([]*transport, 2),
+		sorted:     make([]*transport, 2),
+		sortLock:   sync.RWMutex{},
+	}
+
+	// Both replies after the wait
+	tsps.Register(0, &probeTSPClient{
+		dummyTSPClient: cs[0].(*dummyTSPClient),
+		latency:        300 * time.Millisecond,
+		failed:         false,
+	})
+	tsps.Register(1, &probeTSPClient{
+		dummyTSPClient: cs[1].(*dummyTSPClient),
+		latency:        200 * time.Millisecond,
+		failed:         false,
+	})
+
+	probeStart := time.Now()
+
+	tsps.Probe(func(
+		cfg transporter.HandlerConfig,
+		delayFeedback func(time.Duration),
+	) transporter.Handler {
+		return &dummyTSPHandler{
+			actualDelay:   false,
+			delay:         10 * time.Millisecond,
+			delayCallback: delayFeedback,
+		}
+	}, time.Second, 20*time.Millisecond)
+
+	if time.Now().Sub(probeStart) >= 150*time.Millisecond {
+		t.Error("Expecting Probe stops waiting when the wait expired")
+
+		return
+	}
+
+	tsps.pole.Lock.RLock()
+	headName := tsps.pole.Head.Client.(*probeTSPClient).name
+	tsps.pole.Lock.RUnlock()
+
+	if headName != "ClientA" {
+		t.Errorf("Expecting head %s before probe completed, got %s",
+			"ClientA", headName)
+
+		return
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	tsps.pole.Lock.RLock()
+	headName = tsps.pole.Head.Client.(*probeTSPClient).name
+	tsps.pole.Lock.RUnlock()
+
+	if headName != "ClientB" {
+		t.Errorf("Expecting head %s after probe completed, got %s",
+			"ClientB", headName)
+
+		return
+	}
+}
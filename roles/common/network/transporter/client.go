@@ -22,11 +22,13 @@ package transporter
 
 import (
 	"errors"
+	"math/rand"
 	"sync"
 	"time"
 
 	ccommon "github.com/nickrio/coward/common"
 	"github.com/nickrio/coward/common/locked"
+	"github.com/nickrio/coward/roles/common/network/buffer"
 )
 
 // Transporter client general errors
@@ -53,6 +55,14 @@ type Client interface {
 	Kickoff()
 }
 
+// ClientCover is the configuration of cover requests. When enabled,
+// a request built by Handler will be sent through a connected but
+// idle connection after a random delay which averages Interval
+type ClientCover struct {
+	Interval time.Duration
+	Handler  HandlerBuilder
+}
+
 // client implements Client
 type client struct {
 	retry           uint8
@@ -65,6 +75,12 @@ type client struct {
 	waitingRequests ccommon.Counter
 	avgConnSelDelay ccommon.Averager
 	requestWait     sync.WaitGroup
+	cover           ClientCover
+	coverPending    bool
+	coverLock       sync.Mutex
+	coverBuffer     buffer.Buffer
+	coverRand       *rand.Rand
+	datagram        DatagramDialer
 }
 
 // NewClient creates a new Transporter client
@@ -74,6 +90,8 @@ func NewClient(
 	concurrence uint16,
 	retry uint8,
 	reuseConn bool,
+	cover ClientCover,
+	datagram DatagramDialer,
 ) Client {
 	c := &client{
 		retry:           retry,
@@ -86,6 +104,12 @@ func NewClient(
 		waitingRequests: ccommon.NewCounter(0),
 		avgConnSelDelay: ccommon.NewLockedAverager(int(concurrence)),
 		requestWait:     sync.WaitGroup{},
+		cover:           cover,
+		coverPending:    false,
+		coverLock:       sync.Mutex{},
+		coverBuffer:     buffer.Buffer{},
+		coverRand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		datagram:        datagram,
 	}
 
 	for clientID := range c.clients {
@@ -202,6 +226,109 @@ func (c *client) getConnection(
 	return conn, nil
 }
 
+// put puts the connection back to the connection pool, and returns
+// whether or not the connection is still connected
+func (c *client) put(conn ClientConn) bool {
+	if !conn.Connected() {
+		c.idleConnChan <- conn
+
+		return false
+	}
+
+	c.liveConnChan <- conn
+
+	return true
+}
+
+// release puts the connection back to the connection pool, and
+// schedules a cover request if the connection is still connected
+func (c *client) release(conn ClientConn) {
+	if !c.put(conn) {
+		return
+	}
+
+	c.scheduleCover()
+}
+
+// scheduleCover schedules a cover request if there isn't one
+// scheduled already
+func (c *client) scheduleCover() {
+	if c.cover.Interval <= 0 || c.cover.Handler == nil {
+		return
+	}
+
+	c.coverLock.Lock()
+	defer c.coverLock.Unlock()
+
+	if c.coverPending {
+		return
+	}
+
+	c.coverPending = true
+
+	// Wait for a random period between 0.5 and 1.5 times of the
+	// Interval
+	delay := c.cover.Interval/2 +
+		time.Duration(c.coverRand.Int63n(int64(c.cover.Interval)+1))
+
+	time.AfterFunc(delay, c.sendCover)
+}
+
+// sendCover sends a cover request through a idle connection. The
+// cover requests will stop when there is no connected idle connection
+// left in the connection pool, and restart after one been released
+func (c *client) sendCover() {
+	var conn ClientConn
+
+	// Only one cover request will be sent at a time, so the next one
+	// can only be scheduled after current one is completed
+	defer func() {
+		c.coverLock.Lock()
+		c.coverPending = false
+		c.coverLock.Unlock()
+
+		if conn != nil {
+			c.put(conn)
+		}
+
+		if len(c.liveConnChan) <= 0 {
+			return
+		}
+
+		c.scheduleCover()
+	}()
+
+	if c.disabled.Get() {
+		return
+	}
+
+	select {
+	case conn = <-c.liveConnChan:
+	default:
+		return
+	}
+
+	conn.Rewind()
+
+	if !conn.Connected() {
+		return
+	}
+
+	handler := c.cover.Handler(HandlerConfig{
+		Server:   &wrapped{ReadWriteCloser: conn},
+		Buffer:   c.coverBuffer.Slice(),
+		Datagram: nil,
+	})
+
+	handleErr := handler.Handle()
+
+	handler.Close()
+
+	if handleErr != nil {
+		conn.Close()
+	}
+}
+
 // request fetchs a available connection, and send request with it
 // returns NeedsRetry bool, error error
 func (c *client) request(
@@ -237,16 +364,13 @@ func (c *client) request(
 			})
 		}
 
-		if conn.Connected() {
-			c.liveConnChan <- conn
-		} else {
-			c.idleConnChan <- conn
-		}
+		c.release(conn)
 	}()
 
 	handler = builder(HandlerConfig{
-		Server: &wrapped{ReadWriteCloser: conn},
-		Buffer: opt.Buffer,
+		Server:   &wrapped{ReadWriteCloser: conn},
+		Buffer:   opt.Buffer,
+		Datagram: c.datagram,
 	})
 
 	handlerErr := handler.Handle()
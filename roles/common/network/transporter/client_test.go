@@ -0,0 +1,208 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package transporter
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nickrio/coward/roles/common/network/buffer"
+)
+
+type testClientConn struct {
+	lock        sync.Mutex
+	connected   bool
+	busy        bool
+	covers      int
+	interleaved bool
+}
+
+func (t *testClientConn) Name() string {
+	return "Test"
+}
+
+func (t *testClientConn) Read(b []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (t *testClientConn) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (t *testClientConn) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.connected = false
+
+	return nil
+}
+
+func (t *testClientConn) Dial() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.connected = true
+
+	return nil
+}
+
+func (t *testClientConn) Rewind() {}
+
+func (t *testClientConn) Connected() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.connected
+}
+
+func (t *testClientConn) Covers() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.covers
+}
+
+type testClientHandler func() error
+
+func (t testClientHandler) Handle() error {
+	return t()
+}
+
+func (t testClientHandler) Close() error {
+	return nil
+}
+
+func (t testClientHandler) Error(err error) (bool, bool, error) {
+	return false, false, err
+}
+
+func TestClientCover(t *testing.T) {
+	interval := 10 * time.Millisecond
+	conn := &testClientConn{}
+	buf := &buffer.Buffer{}
+	c := NewClient(func() ClientConn {
+		return conn
+	}, time.Second, 1, 1, true, ClientCover{
+		Interval: interval,
+		Handler: func(HandlerConfig) Handler {
+			return testClientHandler(func() error {
+				conn.lock.Lock()
+				defer conn.lock.Unlock()
+
+				if conn.busy {
+					conn.interleaved = true
+				}
+
+				conn.covers++
+
+				return nil
+			})
+		},
+	}, nil)
+
+	// Nothing to cover before a connection is established
+	time.Sleep(5 * interval)
+
+	if conn.Covers() != 0 {
+		t.Errorf("Expecting no cover before connected, got %d",
+			conn.Covers())
+
+		return
+	}
+
+	coversBefore, coversAfter := 0, 0
+
+	_, reqErr := c.Request(func(HandlerConfig) Handler {
+		return testClientHandler(func() error {
+			conn.lock.Lock()
+			conn.busy = true
+			coversBefore = conn.covers
+			conn.lock.Unlock()
+
+			time.Sleep(10 * interval)
+
+			conn.lock.Lock()
+			conn.busy = false
+			coversAfter = conn.covers
+			conn.lock.Unlock()
+
+			return nil
+		})
+	}, RequestOption{
+		Buffer:    buf.Slice(),
+		Canceller: make(Signal),
+		Delay:     nil,
+		Error: func(bool, bool, error) (bool, bool, error) {
+			return false, false, nil
+		},
+	})
+
+	if reqErr != nil {
+		t.Error("Failed to request due to error:", reqErr)
+
+		return
+	}
+
+	if coversBefore != coversAfter {
+		t.Errorf("Expecting no cover during the request, got %d",
+			coversAfter-coversBefore)
+
+		return
+	}
+
+	// The connection is idle now, covers should be sent through it
+	time.Sleep(10 * interval)
+
+	if conn.Covers() < 2 {
+		t.Errorf("Expecting covers through the idle connection, got %d",
+			conn.Covers())
+
+		return
+	}
+
+	// And stopped after it's disconnected
+	conn.Close()
+
+	time.Sleep(3 * interval)
+
+	covers := conn.Covers()
+
+	time.Sleep(5 * interval)
+
+	if conn.Covers() != covers {
+		t.Errorf("Expecting no cover after disconnected, got %d",
+			conn.Covers()-covers)
+
+		return
+	}
+
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+
+	if conn.interleaved {
+		t.Error("Expecting covers to never interleave with requests")
+
+		return
+	}
+}
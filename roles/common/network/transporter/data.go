@@ -22,6 +22,7 @@ package transporter
 
 import (
 	"io"
+	"net"
 
 	"github.com/nickrio/coward/roles/common/network/buffer"
 )
@@ -98,6 +99,13 @@ type ServerConnListener interface {
 // a ServerClientConn
 type ServerClientInfo interface {
 	Name() string
+
+	// LocalAddr returns the address which the client has connected to
+	LocalAddr() net.Addr
+
+	// Identity returns the name of the key which the client is using.
+	// It's only known after data has been received from the client
+	Identity() (string, bool)
 }
 
 // ServerClientConn represents a connection that initialized by a
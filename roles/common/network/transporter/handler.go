@@ -22,6 +22,7 @@ package transporter
 
 import (
 	"io"
+	"net"
 
 	"github.com/nickrio/coward/roles/common/network/buffer"
 )
@@ -35,9 +36,15 @@ type Handler interface {
 
 // HandlerConfig is the configuration of a handler
 type HandlerConfig struct {
-	Server io.ReadWriter
-	Buffer buffer.Slice
+	Server   io.ReadWriter
+	Buffer   buffer.Slice
+	Datagram DatagramDialer
 }
 
+// DatagramDialer dials a datagram connection to given port of the
+// server which the Transporter is connected to. It will be nil when
+// datagram transport is disabled
+type DatagramDialer func(port uint16) (net.Conn, error)
+
 // HandlerBuilder is what creates a handler
 type HandlerBuilder func(HandlerConfig) Handler
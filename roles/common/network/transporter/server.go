@@ -72,8 +72,9 @@ func (s *server) handle(
 	option.Connected(clientConn)
 
 	handler := option.Handler(HandlerConfig{
-		Server: &wrapped{ReadWriteCloser: clientConn},
-		Buffer: option.Buffer,
+		Server:   &wrapped{ReadWriteCloser: clientConn},
+		Buffer:   option.Buffer,
+		Datagram: nil,
 	})
 
 	defer func() {
@@ -0,0 +1,124 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package proxy
+
+import (
+	ccommon "github.com/nickrio/coward/common"
+	"github.com/nickrio/coward/common/logger"
+	"github.com/nickrio/coward/roles/common/network/transporter"
+	"github.com/nickrio/coward/roles/proxy/common"
+)
+
+// access checks the permission of a client according to the key it's
+// using
+type access struct {
+	keys   common.Keys
+	client transporter.ServerClientInfo
+	logger logger.Logger
+	key    *common.KeyItem
+}
+
+// newAccess creates a new Access for the client
+func newAccess(
+	keys common.Keys,
+	client transporter.ServerClientInfo,
+	log logger.Logger,
+) common.Access {
+	return &access{
+		keys:   keys,
+		client: client,
+		logger: log,
+		key:    nil,
+	}
+}
+
+// identify finds out which key the client is using
+func (a *access) identify() (*common.KeyItem, error) {
+	if a.key != nil {
+		return a.key, nil
+	}
+
+	name, identified := a.client.Identity()
+
+	if !identified {
+		a.logger.Warningf("Can't identify which key the client is using")
+
+		return nil, common.ErrKeyUnidentified
+	}
+
+	key, keyErr := a.keys.Get(name)
+
+	if keyErr != nil {
+		a.logger.Warningf("Client is using an unknown key \"%s\"", name)
+
+		return nil, common.ErrKeyUnidentified
+	}
+
+	a.logger.Infof("Identified as key \"%s\"", name)
+
+	a.key = key
+
+	return key, nil
+}
+
+// Command checks whether or not the client can execute the command
+func (a *access) Command(cmd ccommon.Command) error {
+	key, keyErr := a.identify()
+
+	if keyErr != nil {
+		return keyErr
+	}
+
+	if key.Command(cmd) {
+		return nil
+	}
+
+	for name, command := range common.KeyCommands {
+		if command != cmd {
+			continue
+		}
+
+		a.logger.Warningf("Key \"%s\" is not permitted to %s",
+			key.Name, name)
+
+		break
+	}
+
+	return common.ErrKeyNotPermitted
+}
+
+// Channel checks whether or not the client can open the channel
+func (a *access) Channel(id byte) error {
+	key, keyErr := a.identify()
+
+	if keyErr != nil {
+		return keyErr
+	}
+
+	if key.Channel(id) {
+		return nil
+	}
+
+	a.logger.Warningf("Key \"%s\" is not permitted to open channel %d",
+		key.Name, id)
+
+	return common.ErrKeyNotPermitted
+}
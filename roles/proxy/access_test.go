@@ -0,0 +1,128 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package proxy
+
+import (
+	"net"
+	"testing"
+
+	ccommon "github.com/nickrio/coward/common"
+	"github.com/nickrio/coward/common/logger"
+	"github.com/nickrio/coward/roles/common/network/messaging"
+	"github.com/nickrio/coward/roles/proxy/common"
+)
+
+type testClientInfo struct {
+	identity   string
+	identified bool
+}
+
+func (t testClientInfo) Name() string {
+	return "Test"
+}
+
+func (t testClientInfo) LocalAddr() net.Addr {
+	return nil
+}
+
+func (t testClientInfo) Identity() (string, bool) {
+	return t.identity, t.identified
+}
+
+func TestAccess(t *testing.T) {
+	cfg, cfgErr := testParseConfig("-ek 0123456789abcdef0 " +
+		"-k {-n reader -k 0123456789abcdef1 -c connect_host resolve_host " +
+		"-ch 2}")
+
+	if cfgErr != nil {
+		t.Error("Failed to parse config due to error:", cfgErr)
+
+		return
+	}
+
+	commandTests := []struct {
+		Identity   string
+		Identified bool
+		Command    ccommon.Command
+		Err        error
+	}{
+		{"", false, messaging.ConnectHost, common.ErrKeyUnidentified},
+		{"unknown", true, messaging.ConnectHost, common.ErrKeyUnidentified},
+		{"reader", true, messaging.ConnectHost, nil},
+		{"reader", true, messaging.ResolveHost, nil},
+		{"reader", true, messaging.ConnectIPv4, common.ErrKeyNotPermitted},
+		{"reader", true, messaging.RelayUDP, common.ErrKeyNotPermitted},
+		{"reader", true, messaging.BindTCP, common.ErrKeyNotPermitted},
+		{DefaultKeyName, true, messaging.ConnectIPv4, nil},
+		{DefaultKeyName, true, messaging.RelayUDP, nil},
+		{DefaultKeyName, true, messaging.PingHost, nil},
+	}
+
+	for _, test := range commandTests {
+		a := newAccess(cfg.SelectedKeys, testClientInfo{
+			identity:   test.Identity,
+			identified: test.Identified,
+		}, logger.NewDitch())
+
+		accessErr := a.Command(test.Command)
+
+		if accessErr != test.Err {
+			t.Errorf("Expecting key \"%s\" to get error %v for command "+
+				"%d, got %v", test.Identity, test.Err, test.Command,
+				accessErr)
+
+			return
+		}
+	}
+
+	channelTests := []struct {
+		Identity   string
+		Identified bool
+		Channel    byte
+		Err        error
+	}{
+		{"", false, 2, common.ErrKeyUnidentified},
+		{"unknown", true, 2, common.ErrKeyUnidentified},
+		{"reader", true, 2, nil},
+		{"reader", true, 1, common.ErrKeyNotPermitted},
+		{"reader", true, 3, common.ErrKeyNotPermitted},
+		{DefaultKeyName, true, 1, nil},
+		{DefaultKeyName, true, 2, nil},
+		{DefaultKeyName, true, 3, common.ErrKeyNotPermitted},
+	}
+
+	for _, test := range channelTests {
+		a := newAccess(cfg.SelectedKeys, testClientInfo{
+			identity:   test.Identity,
+			identified: test.Identified,
+		}, logger.NewDitch())
+
+		accessErr := a.Channel(test.Channel)
+
+		if accessErr != test.Err {
+			t.Errorf("Expecting key \"%s\" to get error %v for channel "+
+				"%d, got %v", test.Identity, test.Err, test.Channel,
+				accessErr)
+
+			return
+		}
+	}
+}
@@ -0,0 +1,288 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Destination errors
+var (
+	ErrDestinationForbidden = errors.New(
+		"Access to the destination is forbidden")
+
+	ErrDestinationInvalidNetwork = errors.New(
+		"Invalid destination network")
+
+	ErrDestinationInvalidPortRange = errors.New(
+		"Invalid destination port range")
+
+	ErrDestinationInvalidDomain = errors.New(
+		"Invalid destination domain")
+)
+
+// PortRange is a range of ports, both ends are included
+type PortRange struct {
+	From uint16
+	To   uint16
+}
+
+// ParsePortRange parses a port (e.g. 80) or a port range (e.g.
+// 8000-8080)
+func ParsePortRange(portRange string) (PortRange, error) {
+	from, to := portRange, portRange
+
+	dashIdx := strings.IndexByte(portRange, '-')
+
+	if dashIdx >= 0 {
+		from, to = portRange[:dashIdx], portRange[dashIdx+1:]
+	}
+
+	fromPort, fromErr := strconv.ParseUint(strings.TrimSpace(from), 10, 16)
+
+	if fromErr != nil {
+		return PortRange{}, ErrDestinationInvalidPortRange
+	}
+
+	toPort, toErr := strconv.ParseUint(strings.TrimSpace(to), 10, 16)
+
+	if toErr != nil || toPort < fromPort {
+		return PortRange{}, ErrDestinationInvalidPortRange
+	}
+
+	return PortRange{From: uint16(fromPort), To: uint16(toPort)}, nil
+}
+
+// ParseNetwork parses a CIDR (e.g. 10.0.0.0/8) or a single IP address
+func ParseNetwork(network string) (*net.IPNet, error) {
+	if strings.IndexByte(network, '/') < 0 {
+		ip := net.ParseIP(network)
+
+		if ip == nil {
+			return nil, ErrDestinationInvalidNetwork
+		}
+
+		if ipv4 := ip.To4(); ipv4 != nil {
+			return &net.IPNet{IP: ipv4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+
+	_, ipNet, parseErr := net.ParseCIDR(network)
+
+	if parseErr != nil {
+		return nil, ErrDestinationInvalidNetwork
+	}
+
+	return ipNet, nil
+}
+
+// ParseDomain normalizes a domain suffix (e.g. example.com, which
+// matches both example.com and www.example.com)
+func ParseDomain(domain string) (string, error) {
+	normalized := strings.Trim(strings.ToLower(
+		strings.TrimSpace(domain)), ".")
+
+	if normalized == "" {
+		return "", ErrDestinationInvalidDomain
+	}
+
+	return normalized, nil
+}
+
+// Destination is a rule that decides whether or not a destination
+// can be accessed. Empty conditions matches everything, otherwise all
+// defined conditions must be matched for the rule to be applied
+type Destination struct {
+	Allow    bool
+	Networks []*net.IPNet
+	Ports    []PortRange
+	Domains  []string
+}
+
+// match returns whether or not the rule can be applied to the
+// destination
+func (d Destination) match(host string, ip net.IP, port uint16) bool {
+	if len(d.Networks) > 0 {
+		matched := false
+
+		for _, network := range d.Networks {
+			if !network.Contains(ip) {
+				continue
+			}
+
+			matched = true
+
+			break
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	if len(d.Ports) > 0 {
+		matched := false
+
+		for _, portRange := range d.Ports {
+			if port < portRange.From || port > portRange.To {
+				continue
+			}
+
+			matched = true
+
+			break
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	if len(d.Domains) > 0 {
+		// Destinations which been requested by IP address never
+		// matches domain conditions
+		if host == "" {
+			return false
+		}
+
+		matched := false
+
+		for _, domain := range d.Domains {
+			if host != domain && !strings.HasSuffix(host, "."+domain) {
+				continue
+			}
+
+			matched = true
+
+			break
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchHost returns whether or not the rule can be applied to the
+// host regardless of it's address and port, and whether or not the
+// rule may be applied once the address and port is known
+func (d Destination) matchHost(host string) (bool, bool) {
+	if len(d.Domains) > 0 {
+		matched := false
+
+		for _, domain := range d.Domains {
+			if host != domain && !strings.HasSuffix(host, "."+domain) {
+				continue
+			}
+
+			matched = true
+
+			break
+		}
+
+		if !matched {
+			return false, false
+		}
+	}
+
+	if len(d.Networks) > 0 || len(d.Ports) > 0 {
+		return false, true
+	}
+
+	return true, true
+}
+
+// Destinations is the Destination access policy, the first matched
+// rule decides whether or not a destination can be accessed
+type Destinations struct {
+	Rules []Destination
+	Deny  bool
+}
+
+// Permit checks whether or not the destination can be accessed. The
+// host is the domain name which the client requested, or empty when
+// the client requested an IP address. The ip is the address which
+// will actually be accessed
+func (d Destinations) Permit(host string, ip net.IP, port uint16) error {
+	host = strings.Trim(strings.ToLower(host), ".")
+
+	for _, rule := range d.Rules {
+		if !rule.match(host, ip, port) {
+			continue
+		}
+
+		if !rule.Allow {
+			return ErrDestinationForbidden
+		}
+
+		return nil
+	}
+
+	if d.Deny {
+		return ErrDestinationForbidden
+	}
+
+	return nil
+}
+
+// PermitHost checks whether or not the host can be accessed before
+// it's been resolved. The host is only forbidden when it can't be
+// accessed on any address or port
+func (d Destinations) PermitHost(host string) error {
+	host = strings.Trim(strings.ToLower(host), ".")
+
+	for _, rule := range d.Rules {
+		matched, mayMatch := rule.matchHost(host)
+
+		if !mayMatch {
+			continue
+		}
+
+		// The rule may or may not be applied depends on the address
+		// and port. If it allows, some access to the host is permitted
+		if !matched {
+			if rule.Allow {
+				return nil
+			}
+
+			continue
+		}
+
+		if !rule.Allow {
+			return ErrDestinationForbidden
+		}
+
+		return nil
+	}
+
+	if d.Deny {
+		return ErrDestinationForbidden
+	}
+
+	return nil
+}
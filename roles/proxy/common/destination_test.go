@@ -0,0 +1,212 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParsePortRange(t *testing.T) {
+	tests := map[string]PortRange{
+		"80":        {From: 80, To: 80},
+		"8000-8080": {From: 8000, To: 8080},
+		" 1 - 2 ":   {From: 1, To: 2},
+	}
+
+	for input, expected := range tests {
+		result, parseErr := ParsePortRange(input)
+
+		if parseErr != nil {
+			t.Errorf("Can't parse %q due to error: %s", input, parseErr)
+
+			return
+		}
+
+		if result != expected {
+			t.Errorf("Expecting %v for %q, got %v", expected, input, result)
+
+			return
+		}
+	}
+
+	for _, input := range []string{"", "a", "80-", "8080-8000", "65536"} {
+		_, parseErr := ParsePortRange(input)
+
+		if parseErr != ErrDestinationInvalidPortRange {
+			t.Errorf("Expecting error %s for %q, got %v",
+				ErrDestinationInvalidPortRange, input, parseErr)
+
+			return
+		}
+	}
+}
+
+func testMustParseNetwork(network string) *net.IPNet {
+	ipNet, parseErr := ParseNetwork(network)
+
+	if parseErr != nil {
+		panic(parseErr)
+	}
+
+	return ipNet
+}
+
+func TestDestinationsPermit(t *testing.T) {
+	destinations := Destinations{
+		Rules: []Destination{
+			{
+				Allow:   true,
+				Domains: []string{"allowed.internal"},
+			},
+			{
+				Allow: false,
+				Networks: []*net.IPNet{
+					testMustParseNetwork("10.0.0.0/8"),
+					testMustParseNetwork("169.254.169.254"),
+					testMustParseNetwork("fc00::/7"),
+				},
+			},
+			{
+				Allow:   false,
+				Domains: []string{"blocked.com"},
+			},
+			{
+				Allow: true,
+				Ports: []PortRange{{From: 80, To: 80}, {From: 443, To: 443}},
+			},
+		},
+		Deny: true,
+	}
+
+	tests := []struct {
+		Host    string
+		IP      string
+		Port    uint16
+		Permits bool
+	}{
+		{"", "93.184.216.34", 80, true},
+		{"", "93.184.216.34", 22, false},
+		{"", "10.1.2.3", 80, false},
+		{"", "169.254.169.254", 80, false},
+		{"", "fd00::1", 443, false},
+		{"", "2001:db8::1", 443, true},
+		{"www.blocked.com", "93.184.216.34", 80, false},
+		{"blocked.com.", "93.184.216.34", 80, false},
+		{"notblocked.com", "93.184.216.34", 80, true},
+		{"db.allowed.internal", "10.1.2.3", 5432, true},
+	}
+
+	for _, test := range tests {
+		permitErr := destinations.Permit(
+			test.Host, net.ParseIP(test.IP), test.Port)
+
+		if test.Permits && permitErr != nil {
+			t.Errorf("Expecting %s:%s:%d to be permitted, got error %s",
+				test.Host, test.IP, test.Port, permitErr)
+
+			return
+		}
+
+		if !test.Permits && permitErr != ErrDestinationForbidden {
+			t.Errorf("Expecting %s:%s:%d to be forbidden, got %v",
+				test.Host, test.IP, test.Port, permitErr)
+
+			return
+		}
+	}
+
+	permitErr := Destinations{}.Permit("", net.ParseIP("10.0.0.1"), 22)
+
+	if permitErr != nil {
+		t.Errorf("Expecting empty Destinations to permit everything, "+
+			"got error %s", permitErr)
+
+		return
+	}
+}
+
+func TestDestinationsPermitHost(t *testing.T) {
+	destinations := Destinations{
+		Rules: []Destination{
+			{
+				Allow:   false,
+				Domains: []string{"blocked.com"},
+			},
+			{
+				Allow:   false,
+				Domains: []string{"internal"},
+				Ports:   []PortRange{{From: 22, To: 22}},
+			},
+			{
+				Allow:   true,
+				Domains: []string{"internal", "example.com"},
+			},
+			{
+				Allow: true,
+				Networks: []*net.IPNet{
+					testMustParseNetwork("192.0.2.0/24"),
+				},
+			},
+		},
+		Deny: true,
+	}
+
+	tests := []struct {
+		Host    string
+		Permits bool
+	}{
+		{"www.blocked.com", false},
+		{"Blocked.com.", false},
+		{"db.internal", true},
+		{"www.example.com", true},
+		{"other.com", true},
+	}
+
+	for _, test := range tests {
+		permitErr := destinations.PermitHost(test.Host)
+
+		if test.Permits && permitErr != nil {
+			t.Errorf("Expecting %s to be permitted, got error %s",
+				test.Host, permitErr)
+
+			return
+		}
+
+		if !test.Permits && permitErr != ErrDestinationForbidden {
+			t.Errorf("Expecting %s to be forbidden, got %v",
+				test.Host, permitErr)
+
+			return
+		}
+	}
+
+	permitErr := Destinations{
+		Rules: []Destination{{Allow: true, Domains: []string{"a.com"}}},
+		Deny:  true,
+	}.PermitHost("b.com")
+
+	if permitErr != ErrDestinationForbidden {
+		t.Errorf("Expecting b.com to be forbidden, got %v", permitErr)
+
+		return
+	}
+}
@@ -0,0 +1,136 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"errors"
+	"math"
+
+	"github.com/nickrio/coward/common"
+	"github.com/nickrio/coward/roles/common/network/messaging"
+)
+
+// Key errors
+var (
+	ErrKeyInvalidName = errors.New(
+		"Invalid key name")
+
+	ErrKeyAlreadyExisted = errors.New(
+		"Key already existed")
+
+	ErrKeyNotExisted = errors.New(
+		"Key not existed")
+
+	ErrKeyUnidentified = errors.New(
+		"Can't identify which key the client is using")
+
+	ErrKeyNotPermitted = errors.New(
+		"Key is not permitted to perform the request")
+)
+
+// KeyCommands is the commands which can be permitted to a key
+var KeyCommands = map[string]common.Command{
+	"connect_host": messaging.ConnectHost,
+	"connect_ipv4": messaging.ConnectIPv4,
+	"connect_ipv6": messaging.ConnectIPv6,
+	"relay_udp":    messaging.RelayUDP,
+	"bind_tcp":     messaging.BindTCP,
+	"resolve_host": messaging.ResolveHost,
+	"ping_host":    messaging.PingHost,
+	"ping_ipv4":    messaging.PingIPv4,
+	"ping_ipv6":    messaging.PingIPv6,
+}
+
+// Access checks whether or not the client is permitted to perform
+// a request
+type Access interface {
+	Command(cmd common.Command) error
+	Channel(id byte) error
+}
+
+// Key is the permission of a named proxy key
+type Key struct {
+	Name     string
+	Commands []common.Command
+	Channels []byte
+}
+
+// KeyItem is the item of key
+type KeyItem struct {
+	Name     string
+	commands map[common.Command]bool
+	channels [math.MaxUint8 + 1]bool
+}
+
+// Keys is the Key Search List
+type Keys map[string]*KeyItem
+
+// Add adds one item to the Key Search List
+func (k Keys) Add(key Key) error {
+	if key.Name == "" {
+		return ErrKeyInvalidName
+	}
+
+	if _, existed := k[key.Name]; existed {
+		return ErrKeyAlreadyExisted
+	}
+
+	item := &KeyItem{
+		Name:     key.Name,
+		commands: make(map[common.Command]bool, len(key.Commands)),
+		channels: [math.MaxUint8 + 1]bool{},
+	}
+
+	for _, cmd := range key.Commands {
+		item.commands[cmd] = true
+	}
+
+	for _, id := range key.Channels {
+		item.channels[id] = true
+	}
+
+	k[key.Name] = item
+
+	return nil
+}
+
+// Get gets an item from Key Search List
+func (k Keys) Get(name string) (*KeyItem, error) {
+	item, existed := k[name]
+
+	if !existed {
+		return nil, ErrKeyNotExisted
+	}
+
+	return item, nil
+}
+
+// Command returns whether or not the key is permitted to execute
+// the command
+func (k *KeyItem) Command(cmd common.Command) bool {
+	return k.commands[cmd]
+}
+
+// Channel returns whether or not the key is permitted to open the
+// channel
+func (k *KeyItem) Channel(id byte) bool {
+	return k.channels[id]
+}
@@ -0,0 +1,95 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"testing"
+
+	ccommon "github.com/nickrio/coward/common"
+	"github.com/nickrio/coward/roles/common/network/messaging"
+)
+
+func TestKeys(t *testing.T) {
+	keys := Keys{}
+
+	addTests := []struct {
+		Key Key
+		Err error
+	}{
+		{Key{Name: ""}, ErrKeyInvalidName},
+		{Key{
+			Name:     "reader",
+			Commands: []ccommon.Command{messaging.ConnectHost},
+			Channels: []byte{2},
+		}, nil},
+		{Key{Name: "reader"}, ErrKeyAlreadyExisted},
+		{Key{Name: "nothing"}, nil},
+	}
+
+	for _, test := range addTests {
+		addErr := keys.Add(test.Key)
+
+		if addErr != test.Err {
+			t.Errorf("Expecting adding key \"%s\" to get error %v, got %v",
+				test.Key.Name, test.Err, addErr)
+
+			return
+		}
+	}
+
+	_, getErr := keys.Get("unknown")
+
+	if getErr != ErrKeyNotExisted {
+		t.Errorf("Expecting error %s, got %v", ErrKeyNotExisted, getErr)
+
+		return
+	}
+
+	permitTests := []struct {
+		Name    string
+		Command ccommon.Command
+		Channel byte
+		Permits bool
+	}{
+		{"reader", messaging.ConnectHost, 2, true},
+		{"reader", messaging.ConnectIPv4, 1, false},
+		{"nothing", messaging.ConnectHost, 2, false},
+	}
+
+	for _, test := range permitTests {
+		key, keyErr := keys.Get(test.Name)
+
+		if keyErr != nil {
+			t.Error("Failed to get key due to error:", keyErr)
+
+			return
+		}
+
+		if key.Command(test.Command) != test.Permits ||
+			key.Channel(test.Channel) != test.Permits {
+			t.Errorf("Expecting key \"%s\" to be permitted to command %d "+
+				"and channel %d: %t", test.Name, test.Command,
+				test.Channel, test.Permits)
+
+			return
+		}
+	}
+}
@@ -24,13 +24,19 @@ import (
 	"time"
 
 	"github.com/nickrio/coward/common/logger"
+	"github.com/nickrio/coward/roles/common/network/communicator/datagram"
+	"github.com/nickrio/coward/roles/common/network/dns"
 	"github.com/nickrio/coward/roles/proxy/common"
 )
 
 // Config is the server configuation
 type Config struct {
 	Channels       common.Channels
+	Keys           common.Keys
+	Destinations   common.Destinations
 	Logger         logger.Logger
 	ConnectTimeout time.Duration
 	IdleTimeout    time.Duration
+	Datagram       *datagram.Server
+	Resolver       dns.Resolver
 }
@@ -0,0 +1,331 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package handler
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/nickrio/coward/common"
+	"github.com/nickrio/coward/roles/common/network"
+	"github.com/nickrio/coward/roles/common/network/address"
+	"github.com/nickrio/coward/roles/common/network/conn"
+	"github.com/nickrio/coward/roles/common/network/dns"
+	"github.com/nickrio/coward/roles/common/network/messaging"
+	"github.com/nickrio/coward/roles/common/network/relay"
+)
+
+// Bind TCP errors
+var (
+	ErrInvalidBindAddress = errors.New(
+		"Invalid bind address")
+
+	ErrFailedToListenBindPort = errors.New(
+		"Failed to listen the bind port")
+
+	ErrBindTimeout = errors.New(
+		"No inbound connection before timeout")
+
+	ErrBindCancelled = errors.New(
+		"Client has stopped waiting for the inbound connection")
+)
+
+func (h *handler) bindTCP(
+	buffer []byte,
+	client io.ReadWriter,
+	size uint16,
+) error {
+	// Bind TCP format:
+	//
+	// +-----+------+---------------------+
+	// | CMD | SIZE | ADDRESS INFORMATION |
+	// +-----+------+---------------------+
+	// |  1  |  2   |        SIZE         |
+	// +-----+------+---------------------+
+	//
+	// Expected:
+	// CMD:                 24
+	// ADDRESS INFORMATION: The address of the peer which is expected
+	//                      to connect
+	//
+	// Replies OK with the ADDRESS INFORMATION of the listening port
+	// first, then another OK with the ADDRESS INFORMATION of the peer
+	// once it's connected.
+	//
+	// After the first reply, the client must send one message: OK when
+	// it has received the second OK, the peer connection will be
+	// relayed after that. Or Closed when it no longer waits for the
+	// peer, which can be sent at any time, including after an error
+	// reply
+
+	if size <= 0 {
+		return ErrInvalidBindAddress
+	}
+
+	_, readAddrErr := io.ReadFull(client, buffer[:size])
+
+	if readAddrErr != nil {
+		return readAddrErr
+	}
+
+	var host string
+	var expected []net.IP
+
+	aType, addr, port, _, unpackErr := address.Default.Unpack(buffer[:size])
+
+	if unpackErr != nil {
+		return ErrInvalidBindAddress
+	}
+
+	switch aType {
+	case address.IPv4:
+		fallthrough
+	case address.IPv6:
+		expected = []net.IP{append(net.IP{}, addr...)}
+
+	case address.Domain:
+		host = strings.TrimSpace(strings.ToLower(string(addr)))
+
+		records, resolveErr := h.resolver.Resolve(host)
+
+		if resolveErr != nil || len(records) <= 0 {
+			return ErrHostNotFound
+		}
+
+		expected = dns.IPs(records)
+
+	default:
+		return ErrInvalidBindAddress
+	}
+
+	for _, ip := range expected {
+		switch {
+		case ip.IsLoopback():
+			return ErrLoopbackAddressIsForbidden
+
+		case ip.IsUnspecified():
+			return ErrZeroAddressIsForbidden
+		}
+	}
+
+	// Listen on the interface which the peer will be reaching, or the
+	// one which the client is connected to when it's unknown
+	listenIP := network.RouteSource(expected[0])
+
+	if listenIP == nil {
+		localAddr, isTCPAddr := h.localAddr.(*net.TCPAddr)
+
+		if !isTCPAddr {
+			return ErrDestinationUnconnectable
+		}
+
+		listenIP = localAddr.IP
+	}
+
+	listener, listenErr := net.ListenTCP("tcp", &net.TCPAddr{
+		IP:   listenIP,
+		Port: 0,
+	})
+
+	if listenErr != nil {
+		return ErrFailedToListenBindPort
+	}
+
+	defer listener.Close()
+
+	bound := address.TCP(*listener.Addr().(*net.TCPAddr))
+
+	boundLen, encodeErr := bound.Encode(buffer)
+
+	if encodeErr != nil {
+		return ErrFailedToListenBindPort
+	}
+
+	_, wErr := h.Write(client, messaging.OK, buffer[:boundLen],
+		h.buffer.Client.ExtendedBuffer)
+
+	if wErr != nil {
+		return ErrFailedSendConnectConfirmSignal
+	}
+
+	// The client connection is read for the answer while waiting, so
+	// the peer must connect before the client connection is idle
+	listener.SetDeadline(time.Now().Add(h.connectTimeout))
+
+	accepted := make(chan *net.TCPConn, 1)
+	answered := make(chan error, 1)
+
+	go func() {
+		accepted <- h.bindAccept(listener, host, expected, port)
+	}()
+
+	go func() {
+		answered <- h.bindAnswer(client)
+	}()
+
+	var peerConn *net.TCPConn
+
+	select {
+	case peerConn = <-accepted:
+
+	case answerErr := <-answered:
+		listener.Close()
+
+		peerConn = <-accepted
+
+		if peerConn != nil {
+			peerConn.Close()
+		}
+
+		return answerErr
+	}
+
+	// No more peers
+	listener.Close()
+
+	if peerConn == nil {
+		_, wErr = h.Write(client, messaging.Timeout, nil,
+			h.buffer.Client.ExtendedBuffer)
+
+		if wErr != nil {
+			return ErrFailedSendConnectConfirmSignal
+		}
+
+		return <-answered
+	}
+
+	peer := address.TCP(*peerConn.RemoteAddr().(*net.TCPAddr))
+
+	peerLen, encodeErr := peer.Encode(buffer)
+
+	if encodeErr != nil {
+		peerConn.Close()
+
+		return ErrFailedToListenBindPort
+	}
+
+	targetConn := conn.NewTimed(conn.NewError(peerConn))
+
+	targetConn.SetTimeout(h.idleTimeout)
+
+	defer targetConn.Close()
+
+	_, wErr = h.Write(client, messaging.OK, buffer[:peerLen],
+		h.buffer.Client.ExtendedBuffer)
+
+	if wErr != nil {
+		return ErrFailedSendConnectConfirmSignal
+	}
+
+	answerErr := <-answered
+
+	if answerErr != nil {
+		return answerErr
+	}
+
+	return relay.NewTCPRelay(
+		targetConn, h.client, h.buffer, h.closeChan).Relay()
+}
+
+// bindAccept waits for the expected peer to connect. It returns nil
+// when the listener is closed or timed out
+func (h *handler) bindAccept(
+	listener *net.TCPListener,
+	host string,
+	expected []net.IP,
+	port uint16,
+) *net.TCPConn {
+	for {
+		accepted, acceptErr := listener.AcceptTCP()
+
+		if acceptErr != nil {
+			return nil
+		}
+
+		peerAddr := accepted.RemoteAddr().(*net.TCPAddr)
+
+		if !h.bindPermitted(host, expected, peerAddr, port) {
+			accepted.Close()
+
+			continue
+		}
+
+		return accepted
+	}
+}
+
+// bindAnswer reads the message which the client sends after the first
+// reply. It returns nil when the client is ready for the relay
+func (h *handler) bindAnswer(client io.ReadWriter) error {
+	// The handler is still dispatching current request, so the message
+	// must be read by another Messaging
+	answer := messaging.Messaging{}
+	buf := [messaging.HeadSize]byte{}
+
+	return answer.Dispatch(client, buf[:], common.NewProccessors().
+		Register(messaging.OK, func(
+			b []byte, rw io.ReadWriter, size uint16) error {
+			_, rErr := io.ReadFull(rw, b[:size])
+
+			return rErr
+		}).
+		Register(messaging.Closed, func(
+			b []byte, rw io.ReadWriter, size uint16) error {
+			_, rErr := io.ReadFull(rw, b[:size])
+
+			if rErr != nil {
+				return rErr
+			}
+
+			return ErrBindCancelled
+		}))
+}
+
+// bindPermitted returns whether or not the inbound connection can be
+// accepted. The peer must be the expected one, and be permitted by the
+// Destination policy
+func (h *handler) bindPermitted(
+	host string,
+	expected []net.IP,
+	peer *net.TCPAddr,
+	port uint16,
+) bool {
+	if peer.IP.IsLoopback() || peer.IP.IsUnspecified() {
+		return false
+	}
+
+	if h.destinations.Permit(host, peer.IP, port) != nil {
+		return false
+	}
+
+	for _, ip := range expected {
+		if !ip.Equal(peer.IP) {
+			continue
+		}
+
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,217 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nickrio/coward/roles/common/network/address"
+	"github.com/nickrio/coward/roles/common/network/messaging"
+	pcommon "github.com/nickrio/coward/roles/proxy/common"
+)
+
+func testBindPeerIP(t *testing.T) net.IP {
+	addrs, _ := net.InterfaceAddrs()
+
+	for _, addr := range addrs {
+		ipNet, isIPNet := addr.(*net.IPNet)
+
+		if !isIPNet || ipNet.IP.IsLoopback() || ipNet.IP.To4() == nil {
+			continue
+		}
+
+		return ipNet.IP.To4()
+	}
+
+	t.Skip("No non-loopback IPv4 address to test with")
+
+	return nil
+}
+
+func testBindRequest(t *testing.T, h *testHandler, ip net.IP) {
+	buf := [64]byte{}
+
+	packLen, packErr := address.Default.Pack(address.IPv4, ip, 0, buf[:])
+
+	if packErr != nil {
+		t.Fatal("Failed to pack address due to error:", packErr)
+	}
+
+	h.Serve()
+
+	wErr := h.Write(messaging.BindTCP, buf[:packLen])
+
+	if wErr != nil {
+		t.Fatal("Failed to write due to error:", wErr)
+	}
+}
+
+func testBindReply(t *testing.T, h *testHandler) *net.TCPAddr {
+	cmd, data, rErr := h.Read()
+
+	if rErr != nil {
+		t.Fatal("Failed to read due to error:", rErr)
+	}
+
+	if cmd != messaging.OK {
+		t.Fatalf("Expecting reply %d, got %d", messaging.OK, cmd)
+	}
+
+	replied := address.TCP{}
+
+	_, decodeErr := replied.Decode(data)
+
+	if decodeErr != nil {
+		t.Fatal("Failed to decode reply due to error:", decodeErr)
+	}
+
+	addr := net.TCPAddr(replied)
+
+	return &addr
+}
+
+func TestBindTCPZeroAddress(t *testing.T) {
+	h := testNewHandler(&pcommon.Destinations{}, nil)
+
+	defer h.Close()
+
+	testBindRequest(t, h, net.IPv4zero.To4())
+
+	// The handler returns the error, which will then be replied as
+	// Forbidden by the transporter
+	result := h.Result()
+
+	if result != ErrZeroAddressIsForbidden {
+		t.Errorf("Expecting error %s, got %v",
+			ErrZeroAddressIsForbidden, result)
+
+		return
+	}
+}
+
+func TestBindTCPCancelled(t *testing.T) {
+	ip := testBindPeerIP(t)
+	h := testNewHandler(&pcommon.Destinations{}, nil)
+
+	defer h.Close()
+
+	testBindRequest(t, h, ip)
+
+	bound := testBindReply(t, h)
+
+	if bound.IP.IsUnspecified() {
+		t.Errorf("Expecting a specified bound address, got %s", bound)
+
+		return
+	}
+
+	h.Write(messaging.Closed, nil)
+
+	result := h.Result()
+
+	if result != ErrBindCancelled {
+		t.Errorf("Expecting error %s, got %v", ErrBindCancelled, result)
+
+		return
+	}
+
+	peer, dialErr := net.DialTimeout("tcp", bound.String(), time.Second)
+
+	if dialErr == nil {
+		peer.Close()
+
+		t.Error("Expecting the bound port will be closed")
+
+		return
+	}
+}
+
+func TestBindTCPRelay(t *testing.T) {
+	ip := testBindPeerIP(t)
+	h := testNewHandler(&pcommon.Destinations{}, nil)
+
+	defer h.Close()
+
+	testBindRequest(t, h, ip)
+
+	bound := testBindReply(t, h)
+
+	dialer := net.Dialer{
+		LocalAddr: &net.TCPAddr{IP: ip},
+		Timeout:   time.Second,
+	}
+
+	peer, dialErr := dialer.Dial("tcp", bound.String())
+
+	if dialErr != nil {
+		t.Error("Failed to dial due to error:", dialErr)
+
+		return
+	}
+
+	defer peer.Close()
+
+	connected := testBindReply(t, h)
+
+	if connected.String() != peer.LocalAddr().String() {
+		t.Errorf("Expecting peer %s, got %s", peer.LocalAddr(), connected)
+
+		return
+	}
+
+	h.Write(messaging.OK, nil)
+
+	peer.Write([]byte("Hello"))
+
+	cmd, data, rErr := h.Read()
+
+	if rErr != nil {
+		t.Error("Failed to read due to error:", rErr)
+
+		return
+	}
+
+	if cmd != messaging.Streaming || !bytes.Equal(data, []byte("Hello")) {
+		t.Errorf("Expecting the data of the peer, got %d %v", cmd, data)
+
+		return
+	}
+
+	h.Write(messaging.Closed, nil)
+
+	cmd, _, rErr = h.Read()
+
+	if rErr != nil || (cmd != messaging.EOF && cmd != messaging.Closed) {
+		t.Errorf("Expecting EOF or Closed, got %d (%v)", cmd, rErr)
+
+		return
+	}
+
+	if h.Result() == io.ErrNoProgress {
+		t.Error("Expecting the relay will be completed")
+
+		return
+	}
+}
@@ -67,6 +67,12 @@ func (h *handler) channelTCP(
 	}
 
 	// Yeah, only read the first byte as Channel ID
+	accessErr := h.access.Channel(buffer[0])
+
+	if accessErr != nil {
+		return accessErr
+	}
+
 	ch, chErr := h.channels.Get(buffer[0])
 
 	if chErr != nil || ch.Protocol != network.TCP {
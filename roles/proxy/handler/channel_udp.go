@@ -105,6 +105,12 @@ func (h *handler) channelUDP(
 	}
 
 	// Yeah, only read the first byte as Channel ID
+	accessErr := h.access.Channel(buffer[0])
+
+	if accessErr != nil {
+		return accessErr
+	}
+
 	ch, chErr := h.channels.Get(buffer[0])
 
 	if chErr != nil || ch.Protocol != network.UDP {
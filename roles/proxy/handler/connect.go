@@ -26,7 +26,9 @@ import (
 	"net"
 	"strconv"
 
+	"github.com/nickrio/coward/roles/common/network"
 	"github.com/nickrio/coward/roles/common/network/conn"
+	"github.com/nickrio/coward/roles/common/network/dns"
 	"github.com/nickrio/coward/roles/common/network/messaging"
 	"github.com/nickrio/coward/roles/common/network/relay"
 )
@@ -58,33 +60,116 @@ var (
 		"Remote connection is closed")
 )
 
-func (h *handler) connect(
-	ip net.IP,
+// destinationRequest performs a request to the destination
+type destinationRequest func(
+	host string,
+	ips []net.IP,
 	port uint16,
 	buffer []byte,
 	client io.ReadWriter,
-) error {
-	if ip == nil {
-		return ErrInvalidAddress
+) error
+
+// connectable returns addresses which can be connected and permitted
+// by the Destination policy, the addresses which current host has
+// routes for will be put to the front
+func (h *handler) connectable(
+	host string, ips []net.IP, port uint16) ([]net.IP, error) {
+	var forbiddenErr error
+
+	routable := make([]net.IP, 0, len(ips))
+	unroutable := make([]net.IP, 0, len(ips))
+
+	for _, ip := range ips {
+		switch {
+		case ip == nil:
+			continue
+
+		case ip.IsLoopback():
+			forbiddenErr = ErrLoopbackAddressIsForbidden
+
+			continue
+
+		case ip.IsUnspecified():
+			forbiddenErr = ErrZeroAddressIsForbidden
+
+			continue
+		}
+
+		permitErr := h.destinations.Permit(host, ip, port)
+
+		if permitErr != nil {
+			forbiddenErr = permitErr
+
+			continue
+		}
+
+		if network.Routable(ip) {
+			routable = append(routable, ip)
+		} else {
+			unroutable = append(unroutable, ip)
+		}
 	}
 
-	if ip.IsLoopback() {
-		return ErrLoopbackAddressIsForbidden
+	if len(routable) <= 0 && len(unroutable) <= 0 {
+		if forbiddenErr != nil {
+			return nil, forbiddenErr
+		}
+
+		return nil, ErrInvalidAddress
 	}
 
-	if ip.IsUnspecified() {
-		return ErrLoopbackAddressIsForbidden
+	// Keep the order of the Resolver when an address family is
+	// preferred, otherwise both families will be tried in turn
+	if h.resolver.Prefer() != dns.PreferNone {
+		return append(routable, unroutable...), nil
 	}
 
+	return append(network.InterleaveIPs(routable), unroutable...), nil
+}
+
+// dial connects to the destination
+func (h *handler) dial(
+	host string, ips []net.IP, port uint16) (net.Conn, error) {
 	if port <= 0 {
-		return ErrZeroPortIsForbidden
+		return nil, ErrZeroPortIsForbidden
 	}
 
-	target, targetConnErr := net.DialTimeout("tcp", net.JoinHostPort(
-		ip.String(), strconv.FormatUint(uint64(port), 10)), h.connectTimeout)
+	addresses, addressErr := h.connectable(host, ips, port)
+
+	if addressErr != nil {
+		return nil, addressErr
+	}
+
+	targets := make([]string, len(addresses))
+
+	for idx, address := range addresses {
+		targets[idx] = net.JoinHostPort(
+			address.String(), strconv.FormatUint(uint64(port), 10))
+	}
+
+	// Try the addresses in turn with staggered parallel attempts, so
+	// a broken address won't fail the whole request
+	target, targetConnErr := network.DialRace("tcp", targets,
+		network.DialAttemptDelay, h.connectTimeout, nil)
+
+	if targetConnErr != nil {
+		return nil, ErrDestinationUnconnectable
+	}
+
+	return target, nil
+}
+
+func (h *handler) connect(
+	host string,
+	ips []net.IP,
+	port uint16,
+	buffer []byte,
+	client io.ReadWriter,
+) error {
+	target, targetConnErr := h.dial(host, ips, port)
 
 	if targetConnErr != nil {
-		return ErrDestinationUnconnectable
+		return targetConnErr
 	}
 
 	targetConn := conn.NewTimed(conn.NewError(target))
@@ -23,10 +23,10 @@ package handler
 import (
 	"errors"
 	"io"
-	"net"
 	"strings"
 
 	"github.com/nickrio/coward/common/types"
+	"github.com/nickrio/coward/roles/common/network/dns"
 )
 
 // Connect Host errors
@@ -43,7 +43,16 @@ func (h *handler) connectHost(
 	client io.ReadWriter,
 	size uint16,
 ) error {
-	// Connect Host format:
+	return h.requestHost(buffer, client, size, h.connect)
+}
+
+func (h *handler) requestHost(
+	buffer []byte,
+	client io.ReadWriter,
+	size uint16,
+	request destinationRequest,
+) error {
+	// Connect Host format (Ping Host shares the same format):
 	//
 	// +-----+------+------------+--------+
 	// | CMD | SIZE |    Host    |  Port  |
@@ -67,13 +76,16 @@ func (h *handler) connectHost(
 		return readAddrErr
 	}
 
-	address, addrEesloveErr := net.LookupIP(strings.TrimSpace(
-		strings.ToLower(string(buffer[:size-2]))))
+	host := strings.TrimSpace(strings.ToLower(string(buffer[:size-2])))
+
+	records, addrEesloveErr := h.resolver.Resolve(host)
 
 	if addrEesloveErr != nil {
 		return ErrHostNotFound
 	}
 
+	address := dns.IPs(records)
+
 	port := types.EncodableUint16(0)
 
 	decodeErr := port.DecodeBytes(buffer[size-2 : size])
@@ -82,5 +94,5 @@ func (h *handler) connectHost(
 		return ErrDecodingPortBytes
 	}
 
-	return h.connect(address[0], uint16(port), buffer, client)
+	return request(host, address, uint16(port), buffer, client)
 }
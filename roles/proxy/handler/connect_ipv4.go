@@ -39,7 +39,16 @@ func (h *handler) connectIPv4(
 	client io.ReadWriter,
 	size uint16,
 ) error {
-	// Connect IPv4 format:
+	return h.requestIPv4(buffer, client, size, h.connect)
+}
+
+func (h *handler) requestIPv4(
+	buffer []byte,
+	client io.ReadWriter,
+	size uint16,
+	request destinationRequest,
+) error {
+	// Connect IPv4 format (Ping IPv4 shares the same format):
 	//
 	// +-----+------+----------+--------+
 	// | CMD | SIZE |    IP    |  Port  |
@@ -78,5 +87,5 @@ func (h *handler) connectIPv4(
 		return ErrDecodingPortBytes
 	}
 
-	return h.connect(ipv4Addr, uint16(port), buffer, client)
+	return request("", []net.IP{ipv4Addr}, uint16(port), buffer, client)
 }
@@ -39,7 +39,16 @@ func (h *handler) connectIPv6(
 	client io.ReadWriter,
 	size uint16,
 ) error {
-	// Connect IPv6 format:
+	return h.requestIPv6(buffer, client, size, h.connect)
+}
+
+func (h *handler) requestIPv6(
+	buffer []byte,
+	client io.ReadWriter,
+	size uint16,
+	request destinationRequest,
+) error {
+	// Connect IPv6 format (Ping IPv6 shares the same format):
 	//
 	// +-----+------+----------+--------+
 	// | CMD | SIZE |    IP    |  Port  |
@@ -73,5 +82,5 @@ func (h *handler) connectIPv6(
 		return ErrDecodingPortBytes
 	}
 
-	return h.connect(ipv6Addr, uint16(port), buffer, client)
+	return request("", []net.IP{ipv6Addr}, uint16(port), buffer, client)
 }
@@ -23,11 +23,14 @@ package handler
 import (
 	"io"
 	"math/rand"
+	"net"
 	"time"
 
 	"github.com/nickrio/coward/common"
 	"github.com/nickrio/coward/common/codec"
 	"github.com/nickrio/coward/roles/common/network/buffer"
+	"github.com/nickrio/coward/roles/common/network/communicator/datagram"
+	"github.com/nickrio/coward/roles/common/network/dns"
 	"github.com/nickrio/coward/roles/common/network/messaging"
 	"github.com/nickrio/coward/roles/common/network/transporter"
 	pcommon "github.com/nickrio/coward/roles/proxy/common"
@@ -37,9 +40,14 @@ type handler struct {
 	messaging.Messaging
 
 	client         io.ReadWriter
+	localAddr      net.Addr
 	buffer         buffer.Slice
 	proc           common.Proccessors
 	channels       *pcommon.Channels
+	access         pcommon.Access
+	destinations   *pcommon.Destinations
+	resolver       dns.Resolver
+	datagram       *datagram.Server
 	connectTimeout time.Duration
 	idleTimeout    time.Duration
 	tempBuf        [8]byte
@@ -47,19 +55,30 @@ type handler struct {
 	closeChan      chan bool
 }
 
-// NewHandler creates a new server handler
+// NewHandler creates a new server handler. localAddr is the address
+// which the client has connected to
 func NewHandler(
 	config transporter.HandlerConfig,
+	localAddr net.Addr,
 	connectTimeout time.Duration,
 	idleTimeout time.Duration,
 	channels *pcommon.Channels,
+	access pcommon.Access,
+	destinations *pcommon.Destinations,
+	resolver dns.Resolver,
+	datagramServer *datagram.Server,
 	closeChan chan bool,
 ) transporter.Handler {
 	h := &handler{
 		client:         config.Server,
+		localAddr:      localAddr,
 		buffer:         config.Buffer,
 		proc:           nil,
 		channels:       channels,
+		access:         access,
+		destinations:   destinations,
+		resolver:       resolver,
+		datagram:       datagramServer,
 		connectTimeout: connectTimeout,
 		idleTimeout:    idleTimeout,
 		tempBuf:        [8]byte{},
@@ -69,16 +88,52 @@ func NewHandler(
 
 	h.proc = common.NewProccessors().
 		Register(messaging.NOP, h.nop).
-		Register(messaging.RelayUDP, h.udp).
-		Register(messaging.ConnectHost, h.connectHost).
-		Register(messaging.ConnectIPv4, h.connectIPv4).
-		Register(messaging.ConnectIPv6, h.connectIPv6).
+		Register(messaging.RelayUDP,
+			h.permit(messaging.RelayUDP, h.udp)).
+		Register(messaging.ConnectHost,
+			h.permit(messaging.ConnectHost, h.connectHost)).
+		Register(messaging.ConnectIPv4,
+			h.permit(messaging.ConnectIPv4, h.connectIPv4)).
+		Register(messaging.ConnectIPv6,
+			h.permit(messaging.ConnectIPv6, h.connectIPv6)).
+		Register(messaging.BindTCP,
+			h.permit(messaging.BindTCP, h.bindTCP)).
+		Register(messaging.ResolveHost,
+			h.permit(messaging.ResolveHost, h.resolveHost)).
+		Register(messaging.PingHost,
+			h.permit(messaging.PingHost, h.pingHost)).
+		Register(messaging.PingIPv4,
+			h.permit(messaging.PingIPv4, h.pingIPv4)).
+		Register(messaging.PingIPv6,
+			h.permit(messaging.PingIPv6, h.pingIPv6)).
 		Register(messaging.ChannelTCP, h.channelTCP).
 		Register(messaging.ChannelUDP, h.channelUDP)
 
 	return h
 }
 
+// permit only executes the proccessor when the client is permitted to
+// execute the command
+func (h *handler) permit(
+	cmd common.Command, proc common.Proccessor) common.Proccessor {
+	return func(buffer []byte, client io.ReadWriter, size uint16) error {
+		accessErr := h.access.Command(cmd)
+
+		if accessErr == nil {
+			return proc(buffer, client, size)
+		}
+
+		// Discard the request data so the connection can be reused
+		_, rErr := io.ReadFull(client, buffer[:size])
+
+		if rErr != nil {
+			return rErr
+		}
+
+		return accessErr
+	}
+}
+
 func (h *handler) Handle() error {
 	return h.Dispatch(h.client, h.buffer.Client.Buffer, h.proc)
 }
@@ -96,7 +151,8 @@ func (h *handler) Error(err error) (bool, bool, error) {
 
 	// If it's a decode error, send random bytes back so the
 	// client can figure out that server is receiving invalid
-	// data and retry connection after that
+	// data and retry connection after that. The communicator
+	// will drop the reply if the client never been authenticated
 
 	switch e := handleErr.(type) {
 	case codec.Error:
@@ -137,6 +193,10 @@ func (h *handler) Error(err error) (bool, bool, error) {
 			fallthrough
 		case ErrInvalidHostAddressPortLength:
 			fallthrough
+		case ErrInvalidResolveHostLength:
+			fallthrough
+		case ErrInvalidBindAddress:
+			fallthrough
 		case ErrDecodingPortBytes:
 			h.Write(h.client, messaging.Invalid, nil,
 				h.buffer.Client.ExtendedBuffer)
@@ -149,22 +209,40 @@ func (h *handler) Error(err error) (bool, bool, error) {
 			h.Write(h.client, messaging.Unconnectable, nil,
 				h.buffer.Client.ExtendedBuffer)
 
+		case pcommon.ErrKeyUnidentified:
+			fallthrough
+		case pcommon.ErrKeyNotPermitted:
+			fallthrough
 		case ErrLoopbackAddressIsForbidden:
 			fallthrough
 		case ErrZeroAddressIsForbidden:
 			fallthrough
 		case ErrZeroPortIsForbidden:
+			fallthrough
+		case pcommon.ErrDestinationForbidden:
 			h.Write(h.client, messaging.Forbidden, nil,
 				h.buffer.Client.ExtendedBuffer)
 
 		case ErrInvalidUDPEphemeralPortAddr:
 			fallthrough
 		case ErrFailedToOpenUDPEphemeralPort:
+			fallthrough
+		case ErrFailedToOpenDatagramSession:
+			fallthrough
+		case ErrFailedToEncodeResolveRecords:
+			fallthrough
+		case ErrFailedToEncodePingLatency:
+			fallthrough
+		case ErrFailedToListenBindPort:
 			h.Write(h.client, messaging.InternalError, nil,
 				h.buffer.Client.ExtendedBuffer)
 
 			return false, false, err
 
+		case ErrBindTimeout:
+			h.Write(h.client, messaging.Timeout, nil,
+				h.buffer.Client.ExtendedBuffer)
+
 		case ErrFailedSendConnectConfirmSignal:
 			return false, true, err
 
@@ -0,0 +1,133 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package handler
+
+import (
+	"io"
+	"net"
+	"time"
+
+	ccommon "github.com/nickrio/coward/common"
+	"github.com/nickrio/coward/common/types"
+	"github.com/nickrio/coward/roles/common/network/buffer"
+	"github.com/nickrio/coward/roles/common/network/communicator/datagram"
+	"github.com/nickrio/coward/roles/common/network/dns"
+	"github.com/nickrio/coward/roles/common/network/messaging"
+	"github.com/nickrio/coward/roles/common/network/transporter"
+	pcommon "github.com/nickrio/coward/roles/proxy/common"
+)
+
+type testAccess struct{}
+
+func (t testAccess) Command(cmd ccommon.Command) error {
+	return nil
+}
+
+func (t testAccess) Channel(id byte) error {
+	return nil
+}
+
+// testHandler is a handler which serves requests written to Client
+type testHandler struct {
+	Client  net.Conn
+	handler transporter.Handler
+	result  chan error
+	buf     [messaging.HeadSize + 1024]byte
+}
+
+func testNewHandler(
+	destinations *pcommon.Destinations,
+	datagramServer *datagram.Server,
+) *testHandler {
+	client, server := net.Pipe()
+	buf := &buffer.Buffer{}
+
+	return &testHandler{
+		Client: client,
+		handler: NewHandler(transporter.HandlerConfig{
+			Server: server,
+			Buffer: buf.Slice(),
+		}, server.LocalAddr(), time.Second, 10*time.Second, nil,
+			testAccess{}, destinations,
+			dns.NewResolver(dns.ResolverConfig{}), datagramServer,
+			make(chan bool)),
+		result: make(chan error, 1),
+	}
+}
+
+// Serve handles one request in the background
+func (t *testHandler) Serve() {
+	go func() {
+		t.result <- t.handler.Handle()
+	}()
+}
+
+// Result waits for the request to be handled
+func (t *testHandler) Result() error {
+	select {
+	case err := <-t.result:
+		return err
+
+	case <-time.After(time.Second):
+		return io.ErrNoProgress
+	}
+}
+
+// Write writes a message to the handler
+func (t *testHandler) Write(cmd ccommon.Command, data []byte) error {
+	_, wErr := (&messaging.Messaging{}).Write(t.Client, cmd, data, t.buf[:])
+
+	return wErr
+}
+
+// Read reads a message from the handler
+func (t *testHandler) Read() (ccommon.Command, []byte, error) {
+	t.Client.SetReadDeadline(time.Now().Add(time.Second))
+
+	_, rErr := io.ReadFull(t.Client, t.buf[:messaging.HeadSize])
+
+	if rErr != nil {
+		return 0, nil, rErr
+	}
+
+	size := types.EncodableUint16(0)
+
+	decodeErr := size.DecodeBytes(t.buf[1:messaging.HeadSize])
+
+	if decodeErr != nil {
+		return 0, nil, decodeErr
+	}
+
+	data := make([]byte, size)
+
+	_, rErr = io.ReadFull(t.Client, data)
+
+	if rErr != nil {
+		return 0, nil, rErr
+	}
+
+	return ccommon.Command(t.buf[0]), data, nil
+}
+
+// Close closes the client side of the handler
+func (t *testHandler) Close() error {
+	return t.Client.Close()
+}
@@ -20,12 +20,10 @@
 
 package handler
 
-import (
-	"io"
-
-	"github.com/nickrio/coward/roles/common/network/messaging"
-)
+import "io"
 
+// nop drops the NOP message without reply. NOP messages are sent by
+// clients to cover idle connections
 func (h *handler) nop(
 	buffer []byte,
 	client io.ReadWriter,
@@ -33,16 +31,5 @@ func (h *handler) nop(
 ) error {
 	_, rErr := io.ReadFull(client, buffer[:size])
 
-	if rErr != nil {
-		return rErr
-	}
-
-	_, wErr := h.Write(
-		client, messaging.NOP, nil, h.buffer.Client.ExtendedBuffer)
-
-	if wErr != nil {
-		return wErr
-	}
-
-	return nil
+	return rErr
 }
@@ -0,0 +1,68 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package handler
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/nickrio/coward/roles/common/network/messaging"
+	pcommon "github.com/nickrio/coward/roles/proxy/common"
+)
+
+func TestNOP(t *testing.T) {
+	h := testNewHandler(&pcommon.Destinations{}, nil)
+
+	defer h.Close()
+
+	// NOP messages must be dropped, and the connection must stay
+	// usable for the next request
+	for _, size := range []int{0, 1, 256} {
+		h.Serve()
+
+		wErr := h.Write(messaging.NOP, bytes.Repeat([]byte{1}, size))
+
+		if wErr != nil {
+			t.Error("Failed to write due to error:", wErr)
+
+			return
+		}
+
+		result := h.Result()
+
+		if result != nil {
+			t.Errorf("Expecting NOP to be dropped, got error %v", result)
+
+			return
+		}
+	}
+
+	_, _, rErr := h.Read()
+
+	netErr, isNetErr := rErr.(net.Error)
+
+	if !isNetErr || !netErr.Timeout() {
+		t.Errorf("Expecting no reply to NOP, got %v", rErr)
+
+		return
+	}
+}
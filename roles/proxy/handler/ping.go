@@ -0,0 +1,93 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package handler
+
+import (
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/nickrio/coward/roles/common/network/messaging"
+)
+
+// Ping errors
+var (
+	ErrFailedToEncodePingLatency = errors.New(
+		"Failed to encode ping latency")
+)
+
+func (h *handler) pingIPv4(
+	buffer []byte,
+	client io.ReadWriter,
+	size uint16,
+) error {
+	return h.requestIPv4(buffer, client, size, h.ping)
+}
+
+func (h *handler) pingIPv6(
+	buffer []byte,
+	client io.ReadWriter,
+	size uint16,
+) error {
+	return h.requestIPv6(buffer, client, size, h.ping)
+}
+
+func (h *handler) pingHost(
+	buffer []byte,
+	client io.ReadWriter,
+	size uint16,
+) error {
+	return h.requestHost(buffer, client, size, h.ping)
+}
+
+// ping measures how long it takes to connect the destination, and
+// replies OK with the latency as messaging Ping Latency
+func (h *handler) ping(
+	host string,
+	ips []net.IP,
+	port uint16,
+	buffer []byte,
+	client io.ReadWriter,
+) error {
+	startTime := time.Now()
+
+	target, targetConnErr := h.dial(host, ips, port)
+
+	if targetConnErr != nil {
+		return targetConnErr
+	}
+
+	latency := time.Now().Sub(startTime)
+
+	target.Close()
+
+	latencyLen, encodeErr := messaging.EncodePingLatency(latency, buffer)
+
+	if encodeErr != nil {
+		return ErrFailedToEncodePingLatency
+	}
+
+	_, wErr := h.Write(client, messaging.OK, buffer[:latencyLen],
+		h.buffer.Client.ExtendedBuffer)
+
+	return wErr
+}
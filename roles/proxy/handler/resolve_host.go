@@ -0,0 +1,113 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package handler
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/nickrio/coward/roles/common/network/messaging"
+)
+
+// Resolve Host errors
+var (
+	ErrInvalidResolveHostLength = errors.New(
+		"Invalid resolve host length")
+
+	ErrFailedToEncodeResolveRecords = errors.New(
+		"Failed to encode resolve records")
+)
+
+func (h *handler) resolveHost(
+	buffer []byte,
+	client io.ReadWriter,
+	size uint16,
+) error {
+	// Resolve Host format:
+	//
+	// +-----+------+------------+
+	// | CMD | SIZE |    Host    |
+	// +-----+------+------------+
+	// |  1  |  2   |    Host    |
+	// +-----+------+------------+
+	//
+	// Expected:
+	// CMD:    7
+	// SIZE:   Host
+	// Host:   String
+	//
+	// Replies OK with resolved addresses as messaging.ResolveRecord
+
+	if size <= 0 || size > 255 {
+		if size > 0 {
+			_, rErr := io.ReadFull(client, buffer[:size])
+
+			if rErr != nil {
+				return rErr
+			}
+		}
+
+		return ErrInvalidResolveHostLength
+	}
+
+	_, readHostErr := io.ReadFull(client, buffer[:size])
+
+	if readHostErr != nil {
+		return readHostErr
+	}
+
+	host := strings.TrimSpace(strings.ToLower(string(buffer[:size])))
+
+	permitErr := h.destinations.PermitHost(host)
+
+	if permitErr != nil {
+		return permitErr
+	}
+
+	resolved, resolveErr := h.resolver.Resolve(host)
+
+	if resolveErr != nil || len(resolved) <= 0 {
+		return ErrHostNotFound
+	}
+
+	records := make([]messaging.ResolveRecord, 0, len(resolved))
+
+	for _, record := range resolved {
+		records = append(records, messaging.ResolveRecord{
+			IP:  record.IP,
+			TTL: uint32(record.TTL / time.Second),
+		})
+	}
+
+	recordsLen, encodeErr := messaging.EncodeResolveRecords(
+		records, buffer)
+
+	if encodeErr != nil {
+		return ErrFailedToEncodeResolveRecords
+	}
+
+	_, wErr := h.Write(client, messaging.OK, buffer[:recordsLen],
+		h.buffer.Client.ExtendedBuffer)
+
+	return wErr
+}
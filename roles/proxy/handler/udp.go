@@ -21,12 +21,16 @@
 package handler
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"net"
+	"strings"
 
 	"github.com/nickrio/coward/roles/common/network/address"
+	"github.com/nickrio/coward/roles/common/network/communicator/datagram"
 	"github.com/nickrio/coward/roles/common/network/conn"
+	"github.com/nickrio/coward/roles/common/network/dns"
 	"github.com/nickrio/coward/roles/common/network/messaging"
 	"github.com/nickrio/coward/roles/common/network/relay"
 )
@@ -53,13 +57,17 @@ var (
 
 	ErrFailedToOpenUDPEphemeralPort = errors.New(
 		"Failed to open new UDP ephemeral port")
+
+	ErrFailedToOpenDatagramSession = errors.New(
+		"Failed to open new datagram session")
 )
 
 // udpHandle is the UDP relay handler
 type udpHandle struct {
 	lastIP        net.IP
 	lastPort      int
-	isValidTarget func(*net.UDPAddr) error
+	resolver      dns.Resolver
+	isValidTarget func(host string, udpAddr *net.UDPAddr) error
 }
 
 // Ready is a callback that will be call when relay is ready to
@@ -94,6 +102,8 @@ func (h *udpHandle) Send(
 
 	udpAddr := net.UDPAddr{}
 
+	var targetVerifyErr error
+
 	switch atype {
 	case address.IPv4:
 		fallthrough
@@ -101,28 +111,42 @@ func (h *udpHandle) Send(
 		udpAddr.IP = addr
 		udpAddr.Port = int(port)
 
+		targetVerifyErr = h.isValidTarget("", &udpAddr)
+
 	case address.Domain:
-		ips, lookupErr := net.LookupIP(string(addr))
+		host := strings.ToLower(string(addr))
+		records, lookupErr := h.resolver.Resolve(host)
 
-		if lookupErr != nil || len(ips) < 1 {
+		if lookupErr != nil || len(records) < 1 {
 			return 0, ErrFailedResolveHost
 		}
 
-		udpAddr.IP = ips[0]
 		udpAddr.Port = int(port)
 
+		// Use the first resolved address which is permitted
+		for _, record := range records {
+			udpAddr.IP = record.IP
+
+			targetVerifyErr = h.isValidTarget(host, &udpAddr)
+
+			if targetVerifyErr == nil {
+				break
+			}
+		}
+
 	case address.UseLast:
 		udpAddr.IP = h.lastIP
 		udpAddr.Port = h.lastPort
 
+		targetVerifyErr = h.isValidTarget("", &udpAddr)
+
 	default:
 		return 0, ErrUnsupportedUDPAddressType
 	}
 
-	targetVerifyErr := h.isValidTarget(&udpAddr)
-
+	// Only this packet will be dropped, the relay will tell the client
 	if targetVerifyErr != nil {
-		return 0, targetVerifyErr
+		return 0, relay.ErrDestinationForbidden
 	}
 
 	// Handle the data & send
@@ -167,6 +191,52 @@ func (h *handler) udp(
 	client io.ReadWriter,
 	size uint16,
 ) error {
+	var datagramConn io.ReadWriteCloser
+
+	// Request data:
+	//
+	// +----------+
+	// | DATAGRAM |
+	// +----------+
+	// |    0-1   |
+	// +----------+
+	//
+	// Old clients sends no data. When DATAGRAM is defined, client
+	// wants the packets to be relayed through a datagram session
+	if size > 0 {
+		_, rErr := io.ReadFull(client, buffer[:size])
+
+		if rErr != nil {
+			return rErr
+		}
+
+		if h.datagram != nil &&
+			bytes.Equal(buffer[:size], datagram.Request) {
+			session, sessionErr := datagram.NewSession()
+
+			if sessionErr != nil {
+				return ErrFailedToOpenDatagramSession
+			}
+
+			datagramConn, sessionErr = h.datagram.Open(session)
+
+			if sessionErr != nil {
+				return ErrFailedToOpenDatagramSession
+			}
+
+			defer datagramConn.Close()
+
+			_, encodeErr := datagram.Offer{
+				Port:    h.datagram.Port(),
+				Session: session,
+			}.Encode(buffer)
+
+			if encodeErr != nil {
+				return ErrFailedToOpenDatagramSession
+			}
+		}
+	}
+
 	if udpEphemeralListenErr != nil {
 		return ErrInvalidUDPEphemeralPortAddr
 	}
@@ -181,36 +251,48 @@ func (h *handler) udp(
 
 	defer udpConn.Close()
 
-	// Tell client we all set and ready to roll
-	_, wErr := h.Write(client, messaging.OK, nil,
-		h.buffer.Client.ExtendedBuffer)
+	handle := &udpHandle{
+		resolver: h.resolver,
+		isValidTarget: func(host string, udpAddr *net.UDPAddr) error {
+			if udpAddr.IP.IsUnspecified() {
+				return ErrZeroAddressIsForbidden
+			}
 
-	if wErr != nil {
-		return ErrFailedSendConnectConfirmSignal
+			if udpAddr.IP.IsLoopback() {
+				return ErrLoopbackAddressIsForbidden
+			}
+
+			if udpAddr.Port <= 0 {
+				return ErrZeroPortIsForbidden
+			}
+
+			return h.destinations.Permit(host, udpAddr.IP,
+				uint16(udpAddr.Port))
+		},
 	}
 
-	// Starting relay
-	return relay.NewUDPRelay(
-		&udpHandle{
-			isValidTarget: func(udpAddr *net.UDPAddr) error {
-				if udpAddr.IP.IsUnspecified() {
-					return ErrZeroAddressIsForbidden
-				}
+	if datagramConn == nil {
+		// Tell client we all set and ready to roll
+		_, wErr := h.Write(client, messaging.OK, nil,
+			h.buffer.Client.ExtendedBuffer)
 
-				if udpAddr.IP.IsLoopback() {
-					return ErrLoopbackAddressIsForbidden
-				}
+		if wErr != nil {
+			return ErrFailedSendConnectConfirmSignal
+		}
 
-				if udpAddr.Port <= 0 {
-					return ErrZeroPortIsForbidden
-				}
+		// Starting relay
+		return relay.NewUDPRelay(handle, udpConn, h.client, h.buffer,
+			h.closeChan).Relay()
+	}
 
-				return nil
-			},
-		},
-		udpConn,
-		h.client,
-		h.buffer,
-		h.closeChan,
-	).Relay()
+	// Tell client where to send the packets
+	_, wErr := h.Write(client, messaging.OK, buffer[:datagram.OfferSize],
+		h.buffer.Client.ExtendedBuffer)
+
+	if wErr != nil {
+		return ErrFailedSendConnectConfirmSignal
+	}
+
+	return relay.NewDatagramRelay(handle, udpConn, h.client, datagramConn,
+		h.buffer, h.closeChan).Relay()
 }
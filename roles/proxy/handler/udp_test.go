@@ -0,0 +1,178 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package handler
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/nickrio/coward/roles/common/network/address"
+	"github.com/nickrio/coward/roles/common/network/communicator/datagram"
+	"github.com/nickrio/coward/roles/common/network/messaging"
+	pcommon "github.com/nickrio/coward/roles/proxy/common"
+)
+
+func testDeniedDestinations() *pcommon.Destinations {
+	_, network, _ := net.ParseCIDR("10.0.0.0/8")
+
+	return &pcommon.Destinations{
+		Rules: []pcommon.Destination{
+			{Allow: false, Networks: []*net.IPNet{network}},
+		},
+		Deny: false,
+	}
+}
+
+func testDeniedPacket() []byte {
+	packet := make([]byte, 64)
+	target := address.UDP(net.UDPAddr{
+		IP:   net.ParseIP("10.0.0.1"),
+		Port: 53,
+	})
+
+	encodeLen, _ := target.Encode(packet)
+
+	return append(packet[:encodeLen], []byte("Hello")...)
+}
+
+func testRelayUDPClose(t *testing.T, h *testHandler) {
+	h.Write(messaging.Closed, nil)
+
+	cmd, _, rErr := h.Read()
+
+	if rErr != nil || (cmd != messaging.EOF && cmd != messaging.Closed) {
+		t.Errorf("Expecting EOF or Closed, got %d (%v)", cmd, rErr)
+
+		return
+	}
+
+	if h.Result() == io.ErrNoProgress {
+		t.Error("Expecting the relay to be completed")
+
+		return
+	}
+}
+
+func TestRelayUDPDenied(t *testing.T) {
+	h := testNewHandler(testDeniedDestinations(), nil)
+
+	defer h.Close()
+
+	h.Serve()
+
+	h.Write(messaging.RelayUDP, nil)
+
+	cmd, _, rErr := h.Read()
+
+	if rErr != nil || cmd != messaging.OK {
+		t.Errorf("Expecting OK, got %d (%v)", cmd, rErr)
+
+		return
+	}
+
+	// The association must stay usable after a packet is denied
+	for i := 0; i < 2; i++ {
+		h.Write(messaging.Datagram, testDeniedPacket())
+
+		cmd, _, rErr = h.Read()
+
+		if rErr != nil || cmd != messaging.Forbidden {
+			t.Errorf("Expecting Forbidden, got %d (%v)", cmd, rErr)
+
+			return
+		}
+	}
+
+	testRelayUDPClose(t, h)
+}
+
+func TestRelayUDPDatagramDenied(t *testing.T) {
+	server := datagram.NewServer(net.ParseIP("127.0.0.1"), 0)
+
+	listenErr := server.Listen()
+
+	if listenErr != nil {
+		t.Error("Failed to listen due to error:", listenErr)
+
+		return
+	}
+
+	defer server.Close()
+
+	h := testNewHandler(testDeniedDestinations(), server)
+
+	defer h.Close()
+
+	h.Serve()
+
+	h.Write(messaging.RelayUDP, datagram.Request)
+
+	cmd, data, rErr := h.Read()
+
+	if rErr != nil || cmd != messaging.OK {
+		t.Errorf("Expecting OK, got %d (%v)", cmd, rErr)
+
+		return
+	}
+
+	offer, offerErr := datagram.DecodeOffer(data)
+
+	if offerErr != nil {
+		t.Error("Failed to decode offer due to error:", offerErr)
+
+		return
+	}
+
+	conn, dialErr := net.Dial("udp",
+		(&net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: int(offer.Port)}).
+			String())
+
+	if dialErr != nil {
+		t.Error("Failed to dial due to error:", dialErr)
+
+		return
+	}
+
+	client, clientErr := datagram.NewClient(conn, offer.Session)
+
+	if clientErr != nil {
+		t.Error("Failed to create client due to error:", clientErr)
+
+		return
+	}
+
+	defer client.Close()
+
+	for i := 0; i < 2; i++ {
+		client.Write(testDeniedPacket())
+
+		cmd, _, rErr = h.Read()
+
+		if rErr != nil || cmd != messaging.Forbidden {
+			t.Errorf("Expecting Forbidden, got %d (%v)", cmd, rErr)
+
+			return
+		}
+	}
+
+	testRelayUDPClose(t, h)
+}
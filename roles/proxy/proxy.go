@@ -22,20 +22,28 @@ package proxy
 
 import (
 	"sync"
+	"time"
 
 	"github.com/nickrio/coward/common/codec"
 	"github.com/nickrio/coward/common/logger"
 	"github.com/nickrio/coward/common/role"
+	"github.com/nickrio/coward/common/streamer/timed"
 	"github.com/nickrio/coward/roles/common/network/buffer"
+	"github.com/nickrio/coward/roles/common/network/dns"
 	"github.com/nickrio/coward/roles/common/network/transporter"
 	"github.com/nickrio/coward/roles/proxy/handler"
 )
 
+// resolverReportInterval is how often the Resolver statistics will be
+// logged
+const resolverReportInterval = 10 * time.Minute
+
 type proxy struct {
 	transporter  transporter.Server
 	config       Config
 	clientWaiter sync.WaitGroup
 	serverWaiter sync.WaitGroup
+	reportStop   chan struct{}
 	shuttingDown bool
 	closeNotify  chan<- bool
 }
@@ -50,6 +58,7 @@ func New(
 		config:       cfg,
 		clientWaiter: sync.WaitGroup{},
 		serverWaiter: sync.WaitGroup{},
+		reportStop:   nil,
 		shuttingDown: false,
 		closeNotify:  nil,
 	}
@@ -60,6 +69,20 @@ func New(
 func (s *proxy) Spawn(closeNotify chan<- bool) error {
 	var listenErr error
 
+	if s.config.Datagram != nil {
+		datagramErr := s.config.Datagram.Listen()
+
+		if datagramErr != nil {
+			s.config.Logger.Errorf(
+				"Can't listen datagram due to error %s", datagramErr)
+
+			return datagramErr
+		}
+
+		s.config.Logger.Infof("Datagram is up, listening %s",
+			s.config.Datagram.Addr())
+	}
+
 	acceptMeta := make(chan transporter.ServerConnAccepterMeta)
 
 	s.serverWaiter.Add(1)
@@ -78,6 +101,10 @@ func (s *proxy) Spawn(closeNotify chan<- bool) error {
 		// If we don't wait, listenErr may contains nothing
 		s.serverWaiter.Wait()
 
+		if s.config.Datagram != nil {
+			s.config.Datagram.Close()
+		}
+
 		if listenErr != nil {
 			s.config.Logger.Errorf("Can't listen due to error %s", listenErr)
 
@@ -88,6 +115,12 @@ func (s *proxy) Spawn(closeNotify chan<- bool) error {
 	s.config.Logger.Infof(
 		"Server is up, listening %s", accepterInfo.Name())
 
+	if s.config.Resolver != nil {
+		s.reportStop = make(chan struct{})
+
+		go s.reportResolver(s.reportStop)
+	}
+
 	s.closeNotify = closeNotify
 	s.shuttingDown = false
 
@@ -110,15 +143,60 @@ func (s *proxy) Unspawn() error {
 		s.closeNotify <- true
 	}()
 
+	if s.config.Datagram != nil {
+		s.config.Datagram.Close()
+	}
+
 	s.config.Logger.Infof("Waiting port to be closed")
 
 	s.serverWaiter.Wait()
 
+	if s.reportStop != nil {
+		close(s.reportStop)
+
+		s.logResolverStats(s.config.Resolver.Stats())
+	}
+
 	s.config.Logger.Infof("Server is down")
 
 	return nil
 }
 
+// reportResolver logs the Resolver statistics periodically when it
+// has been changed
+func (s *proxy) reportResolver(stop chan struct{}) {
+	ticker := time.NewTicker(resolverReportInterval)
+
+	defer ticker.Stop()
+
+	last := dns.ResolverStats{}
+
+	for {
+		select {
+		case <-ticker.C:
+			stats := s.config.Resolver.Stats()
+
+			if stats.Hits == last.Hits && stats.Misses == last.Misses {
+				continue
+			}
+
+			last = stats
+
+			s.logResolverStats(stats)
+
+		case <-stop:
+			return
+		}
+	}
+}
+
+// logResolverStats logs the Resolver statistics
+func (s *proxy) logResolverStats(stats dns.ResolverStats) {
+	s.config.Logger.Context("Resolver").Infof(
+		"Cache %d hits, %d misses, %d host names cached",
+		stats.Hits, stats.Misses, stats.Entries)
+}
+
 func (s *proxy) serve(
 	log logger.Logger,
 	acceptInfo chan transporter.ServerConnAccepterMeta,
@@ -127,13 +205,17 @@ func (s *proxy) serve(
 		func(client transporter.ServerClientInfo) transporter.ServeOption {
 			buf := buffer.Buffer{}
 			clientLog := log.Context(client.Name())
+			clientAccess := newAccess(s.config.Keys, client, clientLog)
 
 			return transporter.ServeOption{
 				Buffer: buf.Slice(),
 				Handler: func(
 					hc transporter.HandlerConfig) transporter.Handler {
-					return handler.NewHandler(hc, s.config.ConnectTimeout,
-						s.config.IdleTimeout, &s.config.Channels, nil)
+					return handler.NewHandler(hc, client.LocalAddr(),
+						s.config.ConnectTimeout,
+						s.config.IdleTimeout, &s.config.Channels, clientAccess,
+						&s.config.Destinations, s.config.Resolver,
+						s.config.Datagram, nil)
 				},
 				Connected: func(clientInfo transporter.ServerClientInfo) {
 					clientLog.Debugf("Connected")
@@ -158,6 +240,12 @@ func (s *proxy) serve(
 						// If it's a Transporter error
 						switch tspErr := e.Raw().(type) {
 						case codec.Error:
+							if tspErr == timed.ErrClockSkew {
+								clientLog.Warningf("Clock skew: %s", tspErr)
+
+								break
+							}
+
 							clientLog.Warningf("Decode error: %s", tspErr)
 						}
 
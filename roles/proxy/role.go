@@ -24,16 +24,25 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	ccmd "github.com/nickrio/coward/common"
 	"github.com/nickrio/coward/common/logger"
 	"github.com/nickrio/coward/common/print"
 	"github.com/nickrio/coward/common/role"
 	"github.com/nickrio/coward/roles/common/network"
 	ccommon "github.com/nickrio/coward/roles/common/network/communicator/common"
 	"github.com/nickrio/coward/roles/common/network/communicator/common/wrapper"
+	"github.com/nickrio/coward/roles/common/network/communicator/datagram"
+	"github.com/nickrio/coward/roles/common/network/communicator/mux"
 	"github.com/nickrio/coward/roles/common/network/communicator/tcp"
+	"github.com/nickrio/coward/roles/common/network/communicator/tls"
+	"github.com/nickrio/coward/roles/common/network/communicator/websocket"
+	"github.com/nickrio/coward/roles/common/network/dns"
+	"github.com/nickrio/coward/roles/common/network/messaging"
 	"github.com/nickrio/coward/roles/common/network/transporter"
 	"github.com/nickrio/coward/roles/proxy/common"
 )
@@ -99,27 +108,158 @@ func (c *ConfigChannel) Verify() error {
 	return nil
 }
 
+// DefaultKeyName is the name of the key defined by Encryption Key
+const DefaultKeyName = "default"
+
+// PreviousKeyName is the name prefix of previous Encryption Keys
+const PreviousKeyName = "previous-"
+
+// ConfigPreviousKey is the bare configuration of a previous
+// Encryption Key
+type ConfigPreviousKey struct {
+	SelectedExpire time.Time
+	Key            string `json:"key" cfg:"k,-key:Previous Key (or Passphrase) for the encryption algorithm"`
+	Expire         string `json:"expire" cfg:"e,-expire:When the key will no longer be accepted, in RFC 3339 format (e.g. 2017-01-02T15:04:05Z)"`
+}
+
+// VerifyKey verify Key field
+func (c *ConfigPreviousKey) VerifyKey() error {
+	if len(c.Key) < 16 {
+		return fmt.Errorf("Previous Key must no shorter than 16 charactors")
+	}
+
+	return nil
+}
+
+// VerifyExpire verify Expire field
+func (c *ConfigPreviousKey) VerifyExpire() error {
+	expire, expireErr := time.Parse(time.RFC3339, c.Expire)
+
+	if expireErr != nil {
+		return fmt.Errorf("Invalid expire time \"%s\": %s",
+			c.Expire, expireErr)
+	}
+
+	c.SelectedExpire = expire
+
+	return nil
+}
+
+// Verify verify current ConfigPreviousKey object
+func (c *ConfigPreviousKey) Verify() error {
+	if c.Key == "" {
+		return fmt.Errorf("Previous Key must be defined")
+	}
+
+	if c.Expire == "" {
+		return fmt.Errorf("Previous Key Expire must be defined")
+	}
+
+	return nil
+}
+
+// ConfigKey is the bare configuration of a named proxy key
+type ConfigKey struct {
+	Name     string   `json:"name" cfg:"n,-name:Name of the key, will be used to identify the client"`
+	Key      string   `json:"key" cfg:"k,-key:Key (or Passphrase) for the encryption algorithm"`
+	Commands []string `json:"commands" cfg:"c,-commands:Requests which this key is permitted to perform"`
+	Channels []byte   `json:"channels" cfg:"ch,-channels:IDs of Channels which this key is permitted to open"`
+}
+
+// VerifyName verify Name field
+func (c *ConfigKey) VerifyName() error {
+	if c.Name == "" {
+		return fmt.Errorf("Key Name must not be empty")
+	}
+
+	return nil
+}
+
+// VerifyKey verify Key field
+func (c *ConfigKey) VerifyKey() error {
+	if len(c.Key) < 16 {
+		return fmt.Errorf("Key must no shorter than 16 charactors")
+	}
+
+	return nil
+}
+
+// commands returns the commands which the key is permitted to perform.
+// Verifiers of slice fields will not be called by the configurator,
+// so Commands must be checked here
+func (c ConfigKey) commands() ([]ccmd.Command, error) {
+	commands := make([]ccmd.Command, 0, len(c.Commands))
+
+	for _, command := range c.Commands {
+		cmd, found := common.KeyCommands[command]
+
+		if !found {
+			return nil, fmt.Errorf("Command \"%s\" is undefined", command)
+		}
+
+		commands = append(commands, cmd)
+	}
+
+	return commands, nil
+}
+
+// Verify verify current ConfigKey object
+func (c *ConfigKey) Verify() error {
+	if c.Name == "" {
+		return fmt.Errorf("Key Name must be defined")
+	}
+
+	if c.Key == "" {
+		return fmt.Errorf("Key must be defined")
+	}
+
+	return nil
+}
+
 // ConfigInput is the bare configuration of proxy backend server
 type ConfigInput struct {
-	encryptAlgos        []wrapper.Wrapper
-	encryptAlgosList    []string
-	noisers             []wrapper.Disrupter
-	noisersList         []string
-	connPersistentSet   bool
-	SelectedEncryptAlgo func(key []byte) ccommon.ConnWrapper
-	SelectedNoiser      func(setting []byte) ccommon.ConnDisrupter
-	SelectedChannels    common.Channels
-	ListenIface         net.IP
-	ListenAddr          string          `json:"listen_address" cfg:"la,-listen-address:Which address this backend server will listen on"`
-	ListenPort          uint16          `json:"listen_port" cfg:"lp,-listen-port:Which port this backend server will listen on"`
-	IdleTimeout         uint16          `json:"idle_timeout" cfg:"it,-idle-timeout:How long the connection can stay idle before been taken down"`
-	ConnectTimeout      uint16          `json:"connection_timeout" cfg:"ct,-connection-timeout:The maximum wait time when we trying to establish a connection"`
-	ConnPersistent      bool            `json:"connection_persistent" cfg:"cp,-connection-persistent:Whether or not to reuse idle connections for another request"`
-	EncryptionAlgorithm string          `json:"encryption_algorithm" cfg:"ea,-encryption-algorithm:Which algorithm will be used to encrypt and obscure data"`
-	EncryptionKey       string          `json:"encrypt_key" cfg:"ek,-encryption-key:Key (or Passphrase) for the encryption algorithm"`
-	Noiser              string          `json:"noiser" cfg:"nr,-noiser:Which Disruptor will be used for decharacterization"`
-	NoiserData          string          `json:"noiser_data" cfg:"nd,-noiser-data:Disruptor configuration string"`
-	Channels            []ConfigChannel `json:"channels" cfg:"ch,-channels:Pre-defined destination"`
+	encryptAlgos           []wrapper.Wrapper
+	encryptAlgosList       []string
+	noisers                []wrapper.Disrupter
+	noisersList            []string
+	stages                 wrapper.Stages
+	connPersistentSet      bool
+	failureWaitSet         bool
+	SelectedChannels       common.Channels
+	SelectedKeys           common.Keys
+	SelectedKeyring        ccommon.Keys
+	SelectedDestinations   common.Destinations
+	SelectedDNSPrefer      dns.Preference
+	SelectedTLSCertificate tls.Certificate
+	ListenIface            net.IP
+	ListenAddr             string                `json:"listen_address" cfg:"la,-listen-address:Which address this backend server will listen on"`
+	ListenPort             uint16                `json:"listen_port" cfg:"lp,-listen-port:Which port this backend server will listen on"`
+	IdleTimeout            uint16                `json:"idle_timeout" cfg:"it,-idle-timeout:How long the connection can stay idle before been taken down"`
+	ConnectTimeout         uint16                `json:"connection_timeout" cfg:"ct,-connection-timeout:The maximum wait time when we trying to establish a connection"`
+	ConnPersistent         bool                  `json:"connection_persistent" cfg:"cp,-connection-persistent:Whether or not to reuse idle connections for another request"`
+	FailureWait            uint16                `json:"failure_wait" cfg:"fw,-failure-wait:How long at most a connection which failed to authenticate will be read and discarded before been closed silently"`
+	Fallback               string                `json:"fallback" cfg:"fb,-fallback:Address (host:port) of a server which connections that failed to authenticate will be forwarded to. When TLS is enabled, it will receive the decrypted data. With WebSocket, requests which are not upgrade requests will be forwarded as well"`
+	Multiplex              bool                  `json:"multiplex" cfg:"mx,-multiplex:Whether or not to accept multiplexed connections, which carry multiple requests at same time"`
+	TLSCertificate         string                `json:"tls_certificate" cfg:"tc,-tls-certificate:Path to the PEM encoded certificate file, connections will be served through TLS when defined"`
+	TLSKey                 string                `json:"tls_key" cfg:"tk,-tls-key:Path to the PEM encoded private key file of the TLS certificate"`
+	WebSocket              bool                  `json:"websocket" cfg:"ws,-websocket:Whether or not to accept connections which carry data inside WebSocket messages, so it can be put behind HTTP reverse proxies"`
+	WebSocketPath          string                `json:"websocket_path" cfg:"wp,-websocket-path:Path which WebSocket upgrade requests will be accepted on"`
+	DatagramPort           uint16                `json:"datagram_port" cfg:"dp,-datagram-port:Which UDP port the relayed UDP packets can be exchanged through as encrypted datagrams, 0 to disable"`
+	Bind                   bool                  `json:"bind" cfg:"bd,-bind:Whether or not to accept BIND requests, which open ports on this server for the inbound connections of clients. Named Keys must also be permitted to perform bind_tcp"`
+	EncryptionAlgorithm    string                `json:"encryption_algorithm" cfg:"ea,-encryption-algorithm:Which algorithm will be used to encrypt and obscure data"`
+	EncryptionKey          string                `json:"encrypt_key" cfg:"ek,-encryption-key:Key (or Passphrase) for the encryption algorithm"`
+	PreviousKeys           []ConfigPreviousKey   `json:"previous_keys" cfg:"pk,-previous-keys:Previous Encryption Keys which will still be accepted until they expire"`
+	Noiser                 string                `json:"noiser" cfg:"nr,-noiser:Which Disruptor will be used for decharacterization"`
+	NoiserData             string                `json:"noiser_data" cfg:"nd,-noiser-data:Disruptor configuration string"`
+	Channels               []ConfigChannel       `json:"channels" cfg:"ch,-channels:Pre-defined destination"`
+	Keys                   []ConfigKey           `json:"keys" cfg:"k,-keys:Named keys which clients can use in addition to the Encryption Key"`
+	Pipeline               []wrapper.ConfigStage `json:"pipeline" cfg:"pl,-pipeline:Stages which data will go through in order, replaces Encryption Algorithm and Noiser"`
+	Destinations           []ConfigDestination   `json:"destinations" cfg:"ds,-destinations:Rules which decide whether or not a destination can be accessed, the first matched rule applies"`
+	DestinationDefault     string                `json:"destination_default" cfg:"dd,-destination-default:Whether to allow or deny access to the destinations which matched no Destinations rule"`
+	DNSServer              string                `json:"dns_server" cfg:"ns,-dns-server:Address (host:port) of the DNS server which host names will be resolved through, port 53 will be used when omitted. System resolver will be used when undefined"`
+	DNSNetwork             string                `json:"dns_network" cfg:"nn,-dns-network:Whether to query the DNS Server through udp or tcp"`
+	DNSPrefer              string                `json:"dns_prefer" cfg:"np,-dns-prefer:Which address family will be tried first when connecting to a host name"`
+	DNSCacheSize           uint16                `json:"dns_cache_size" cfg:"nc,-dns-cache-size:How many resolved host names will be cached"`
 }
 
 // GetDescription get additional information of a field
@@ -133,15 +273,52 @@ func (c ConfigInput) GetDescription(fieldPath string) string {
 				strings.Join(c.encryptAlgosList, "\r\n- ")
 		}
 
+		result += fmt.Sprintf("\r\n\r\nExcept plain, clocks of the "+
+			"clients must not differ from this server by more than %s, "+
+			"or their connections will be dropped", network.KeyTimeTolerance)
+
 	case "/Noiser":
 		if len(c.noisersList) > 0 {
 			result = "Available noisers are:\r\n- " +
 				strings.Join(c.noisersList, "\r\n- ")
 		}
 
+	case "/Pipeline/Name":
+		if len(c.stages) > 0 {
+			result = "Available stages are:\r\n- " +
+				strings.Join(c.stages.Names(), "\r\n- ")
+		}
+
 	case "/Channels/Protocol":
 		result = "Available protocols are:\r\n- " +
 			strings.Join([]string{"tcp", "udp"}, "\r\n- ")
+
+	case "/DestinationDefault":
+		fallthrough
+	case "/Destinations/Action":
+		result = "Available actions are:\r\n- " +
+			strings.Join([]string{
+				DestinationAllow, DestinationDeny}, "\r\n- ")
+
+	case "/DNSNetwork":
+		result = "Available networks are:\r\n- " +
+			strings.Join([]string{"udp", "tcp"}, "\r\n- ")
+
+	case "/DNSPrefer":
+		result = "Available address families are:\r\n- " +
+			strings.Join([]string{"ipv4", "ipv6"}, "\r\n- ")
+
+	case "/Keys/Commands":
+		commands := make([]string, 0, len(common.KeyCommands))
+
+		for command := range common.KeyCommands {
+			commands = append(commands, command)
+		}
+
+		sort.Strings(commands)
+
+		result = "Available commands are:\r\n- " +
+			strings.Join(commands, "\r\n- ")
 	}
 
 	return result
@@ -154,8 +331,6 @@ func (c *ConfigInput) VerifyEncryptionAlgorithm() error {
 			continue
 		}
 
-		c.SelectedEncryptAlgo = algo.Wrapper
-
 		return nil
 	}
 
@@ -170,14 +345,17 @@ func (c *ConfigInput) VerifyNoiser() error {
 			continue
 		}
 
-		c.SelectedNoiser = noi.Disrupter
-
 		return nil
 	}
 
 	return fmt.Errorf("Noiser \"%s\" is undefined", c.Noiser)
 }
 
+// VerifyPipeline verify Pipeline Field
+func (c *ConfigInput) VerifyPipeline() error {
+	return c.stages.Verify(c.Pipeline)
+}
+
 // VerifyListenPort verify ListenPort Field
 func (c *ConfigInput) VerifyListenPort() error {
 	if c.ListenPort <= 0 {
@@ -235,6 +413,41 @@ func (c *ConfigInput) VerifyConnPersistent() error {
 	return nil
 }
 
+// VerifyFailureWait verify FailureWait Field
+func (c *ConfigInput) VerifyFailureWait() error {
+	c.failureWaitSet = true
+
+	return nil
+}
+
+// VerifyFallback verify Fallback Field
+func (c *ConfigInput) VerifyFallback() error {
+	_, port, splitErr := net.SplitHostPort(c.Fallback)
+
+	if splitErr != nil {
+		return fmt.Errorf("Invalid Fallback address \"%s\": %s",
+			c.Fallback, splitErr)
+	}
+
+	_, portErr := strconv.ParseUint(port, 10, 16)
+
+	if portErr != nil {
+		return fmt.Errorf("Invalid Fallback port \"%s\"", port)
+	}
+
+	return nil
+}
+
+// VerifyWebSocketPath verify WebSocketPath Field
+func (c *ConfigInput) VerifyWebSocketPath() error {
+	if !strings.HasPrefix(c.WebSocketPath, "/") {
+		return fmt.Errorf("WebSocket Path \"%s\" must start with \"/\"",
+			c.WebSocketPath)
+	}
+
+	return nil
+}
+
 // VerifyEncryptionKey verify EncryptionKey Field
 func (c *ConfigInput) VerifyEncryptionKey() error {
 	if len(c.EncryptionKey) < 16 {
@@ -262,6 +475,92 @@ func (c *ConfigInput) VerifyChannels() error {
 	return nil
 }
 
+// VerifyDestinationDefault verify DestinationDefault Field
+func (c *ConfigInput) VerifyDestinationDefault() error {
+	switch c.DestinationDefault {
+	case DestinationAllow:
+	case DestinationDeny:
+	default:
+		return fmt.Errorf("Destination Default must be either \"%s\" "+
+			"or \"%s\"", DestinationAllow, DestinationDeny)
+	}
+
+	return nil
+}
+
+// VerifyDNSServer verify DNSServer Field
+func (c *ConfigInput) VerifyDNSServer() error {
+	host, _, splitErr := net.SplitHostPort(dns.ServerAddress(c.DNSServer))
+
+	if splitErr != nil || host == "" {
+		return fmt.Errorf("Invalid DNS Server address \"%s\"", c.DNSServer)
+	}
+
+	return nil
+}
+
+// VerifyDNSNetwork verify DNSNetwork Field
+func (c *ConfigInput) VerifyDNSNetwork() error {
+	switch c.DNSNetwork {
+	case "udp":
+	case "tcp":
+	default:
+		return fmt.Errorf("DNS Network must be either \"udp\" or \"tcp\"")
+	}
+
+	return nil
+}
+
+// VerifyDNSPrefer verify DNSPrefer Field
+func (c *ConfigInput) VerifyDNSPrefer() error {
+	switch c.DNSPrefer {
+	case "ipv4":
+		c.SelectedDNSPrefer = dns.PreferIPv4
+
+	case "ipv6":
+		c.SelectedDNSPrefer = dns.PreferIPv6
+
+	default:
+		return fmt.Errorf("DNS Prefer must be either \"ipv4\" or \"ipv6\"")
+	}
+
+	return nil
+}
+
+// VerifyKeys verify Keys Field
+func (c *ConfigInput) VerifyKeys() error {
+	for _, key := range c.Keys {
+		if key.Name == DefaultKeyName ||
+			strings.HasPrefix(key.Name, PreviousKeyName) {
+			return fmt.Errorf("Key Name \"%s\" is reserved", key.Name)
+		}
+
+		commands, commandsErr := key.commands()
+
+		if commandsErr != nil {
+			return commandsErr
+		}
+
+		selectedKeyErr := c.SelectedKeys.Add(common.Key{
+			Name:     key.Name,
+			Commands: commands,
+			Channels: key.Channels,
+		})
+
+		if selectedKeyErr != nil {
+			return fmt.Errorf("Can't add Key \"%s\": %s",
+				key.Name, selectedKeyErr)
+		}
+
+		c.SelectedKeyring = append(c.SelectedKeyring, ccommon.Key{
+			Name: key.Name,
+			Key:  []byte(key.Key),
+		})
+	}
+
+	return nil
+}
+
 // Verify data ConfigInput after assign is completed
 func (c *ConfigInput) Verify() error {
 	if c.ListenAddr == "" {
@@ -284,21 +583,257 @@ func (c *ConfigInput) Verify() error {
 		c.ConnPersistent = true
 	}
 
-	if c.EncryptionAlgorithm == "" {
-		return errors.New("Encryption Algorithm must be defined")
+	for _, key := range c.Keys {
+		for _, command := range key.Commands {
+			if common.KeyCommands[command] != messaging.BindTCP || c.Bind {
+				continue
+			}
+
+			return fmt.Errorf("Key \"%s\" can't perform \"%s\" unless "+
+				"Bind is enabled", key.Name, command)
+		}
+	}
+
+	if !c.failureWaitSet {
+		c.FailureWait = c.IdleTimeout
+	}
+
+	if c.TLSCertificate != "" || c.TLSKey != "" {
+		if c.TLSCertificate == "" || c.TLSKey == "" {
+			return errors.New("TLS Certificate and TLS Key must be " +
+				"defined together")
+		}
+
+		if c.Multiplex {
+			return errors.New("TLS can't be used together with Multiplex")
+		}
+
+		cert, certErr := tls.LoadCertificate(c.TLSCertificate, c.TLSKey)
+
+		if certErr != nil {
+			return fmt.Errorf("Can't load TLS Certificate: %s", certErr)
+		}
+
+		c.SelectedTLSCertificate = cert
+	}
+
+	if c.WebSocket {
+		if c.Multiplex {
+			return errors.New(
+				"WebSocket can't be used together with Multiplex")
+		}
+
+		if c.WebSocketPath == "" {
+			c.WebSocketPath = "/"
+		}
+	} else if c.WebSocketPath != "" {
+		return errors.New(
+			"WebSocket Path requires WebSocket to be enabled")
+	}
+
+	if len(c.Pipeline) > 0 {
+		if c.EncryptionAlgorithm != "" || c.Noiser != "" {
+			return errors.New("Encryption Algorithm and Noiser must not " +
+				"be defined when Pipeline is defined")
+		}
+	} else {
+		if c.EncryptionAlgorithm == "" {
+			return errors.New("Encryption Algorithm must be defined")
+		}
+
+		if c.Noiser == "" {
+			return errors.New("Noiser must be defined")
+		}
+
+		// Encrypt data first, then disrupt the encrypted data
+		c.Pipeline = []wrapper.ConfigStage{
+			{Name: c.EncryptionAlgorithm},
+			{Name: c.Noiser, Setting: c.NoiserData},
+		}
 	}
 
-	if c.EncryptionKey == "" {
-		return errors.New("Encryption Key must be defined")
+	if c.DNSNetwork != "" && c.DNSServer == "" {
+		return errors.New("DNS Network requires DNS Server to be defined")
+	}
+
+	if c.DNSNetwork == "" {
+		c.DNSNetwork = "udp"
+	}
+
+	if c.DNSCacheSize == 0 {
+		c.DNSCacheSize = dns.DefaultCacheSize
+	}
+
+	c.SelectedDestinations = common.Destinations{
+		Rules: make([]common.Destination, 0, len(c.Destinations)),
+		Deny:  c.DestinationDefault == DestinationDeny,
+	}
+
+	for _, destination := range c.Destinations {
+		rule, ruleErr := destination.destination()
+
+		if ruleErr != nil {
+			return ruleErr
+		}
+
+		c.SelectedDestinations.Rules = append(
+			c.SelectedDestinations.Rules, rule)
+	}
+
+	if c.EncryptionKey == "" && len(c.Keys) <= 0 {
+		return errors.New("Encryption Key or Keys must be defined")
+	}
+
+	if c.EncryptionKey == "" && len(c.PreviousKeys) > 0 {
+		return errors.New(
+			"Encryption Key must be defined when Previous Keys is defined")
+	}
+
+	if c.EncryptionKey != "" {
+		// Key defined by Encryption Key is permitted to do everything
+		defaultKey := common.Key{
+			Name:     DefaultKeyName,
+			Commands: make([]ccmd.Command, 0, len(common.KeyCommands)),
+			Channels: make([]byte, 0, len(c.Channels)),
+		}
+
+		for _, cmd := range common.KeyCommands {
+			// BIND opens ports on this server, so it must be enabled
+			// explicitly
+			if cmd == messaging.BindTCP && !c.Bind {
+				continue
+			}
+
+			defaultKey.Commands = append(defaultKey.Commands, cmd)
+		}
+
+		for _, channel := range c.Channels {
+			defaultKey.Channels = append(defaultKey.Channels, channel.ID)
+		}
+
+		selectedKeyErr := c.SelectedKeys.Add(defaultKey)
+
+		if selectedKeyErr != nil {
+			return selectedKeyErr
+		}
+
+		// Put the default key to the front, so it will be tried first.
+		// Previous keys follow, with the same permission of the
+		// default key
+		defaultKeyring := ccommon.Keys{ccommon.Key{
+			Name:   DefaultKeyName,
+			Key:    []byte(c.EncryptionKey),
+			Expire: time.Time{},
+		}}
+
+		for idx, previous := range c.PreviousKeys {
+			defaultKey.Name = PreviousKeyName + strconv.Itoa(idx+1)
+
+			selectedKeyErr = c.SelectedKeys.Add(defaultKey)
+
+			if selectedKeyErr != nil {
+				return selectedKeyErr
+			}
+
+			defaultKeyring = append(defaultKeyring, ccommon.Key{
+				Name:   defaultKey.Name,
+				Key:    []byte(previous.Key),
+				Expire: previous.SelectedExpire,
+			})
+		}
+
+		c.SelectedKeyring = append(defaultKeyring, c.SelectedKeyring...)
 	}
 
-	if c.Noiser == "" {
-		return errors.New("Noiser must be defined")
+	return nil
+}
+
+// Destination actions
+const (
+	DestinationAllow = "allow"
+	DestinationDeny  = "deny"
+)
+
+// ConfigDestination is the bare configuration of a destination access
+// rule
+type ConfigDestination struct {
+	Action   string   `json:"action" cfg:"a,-action:Whether to allow or deny access to the destinations which matched this rule"`
+	Networks []string `json:"networks" cfg:"n,-networks:IP addresses or CIDRs (e.g. 10.0.0.0/8) of the destination"`
+	Ports    []string `json:"ports" cfg:"p,-ports:Ports (e.g. 80) or port ranges (e.g. 8000-8080) of the destination"`
+	Domains  []string `json:"domains" cfg:"d,-domains:Domain suffixes (e.g. example.com) of the destination, only matches requests which been made by host name"`
+}
+
+// VerifyAction verify Action field
+func (c *ConfigDestination) VerifyAction() error {
+	switch c.Action {
+	case DestinationAllow:
+	case DestinationDeny:
+	default:
+		return fmt.Errorf("Destination Action must be either \"%s\" or "+
+			"\"%s\"", DestinationAllow, DestinationDeny)
 	}
 
 	return nil
 }
 
+// destination returns the access rule described by the configuration.
+// The rule is copied into it's parent configuration before Verify is
+// called, so it must be built from the bare fields here
+func (c ConfigDestination) destination() (common.Destination, error) {
+	rule := common.Destination{
+		Allow:    c.Action == DestinationAllow,
+		Networks: make([]*net.IPNet, 0, len(c.Networks)),
+		Ports:    make([]common.PortRange, 0, len(c.Ports)),
+		Domains:  make([]string, 0, len(c.Domains)),
+	}
+
+	for _, network := range c.Networks {
+		ipNet, parseErr := common.ParseNetwork(network)
+
+		if parseErr != nil {
+			return common.Destination{}, fmt.Errorf(
+				"Invalid Destination Network \"%s\"", network)
+		}
+
+		rule.Networks = append(rule.Networks, ipNet)
+	}
+
+	for _, port := range c.Ports {
+		portRange, parseErr := common.ParsePortRange(port)
+
+		if parseErr != nil {
+			return common.Destination{}, fmt.Errorf(
+				"Invalid Destination Port \"%s\"", port)
+		}
+
+		rule.Ports = append(rule.Ports, portRange)
+	}
+
+	for _, domain := range c.Domains {
+		normalized, parseErr := common.ParseDomain(domain)
+
+		if parseErr != nil {
+			return common.Destination{}, fmt.Errorf(
+				"Invalid Destination Domain \"%s\"", domain)
+		}
+
+		rule.Domains = append(rule.Domains, normalized)
+	}
+
+	return rule, nil
+}
+
+// Verify verify current ConfigDestination object
+func (c *ConfigDestination) Verify() error {
+	if c.Action == "" {
+		return fmt.Errorf("Destination Action must be defined")
+	}
+
+	_, ruleErr := c.destination()
+
+	return ruleErr
+}
+
 // Role returns role registration information
 func Role() role.Registration {
 	return role.Registration{
@@ -310,6 +845,7 @@ func Role() role.Registration {
 			encryptAlgosList := []string{}
 			noisers := []wrapper.Disrupter{}
 			noisersList := []string{}
+			stages := wrapper.Stages{}
 
 			for _, c := range components {
 				switch component := c.(type) {
@@ -318,26 +854,37 @@ func Role() role.Registration {
 
 					encryptAlgos = append(encryptAlgos, cmp)
 					encryptAlgosList = append(encryptAlgosList, cmp.Name)
+					stages = append(stages, cmp.Stage())
 
 				case func() wrapper.Disrupter:
 					cmp := component()
 
 					noisers = append(noisers, cmp)
 					noisersList = append(noisersList, cmp.Name)
+					stages = append(stages, cmp.Stage())
+
+				case func() wrapper.Stage:
+					stages = append(stages, component())
 				}
 			}
 
 			return &ConfigInput{
-				encryptAlgos:        encryptAlgos,
-				encryptAlgosList:    encryptAlgosList,
-				noisers:             noisers,
-				noisersList:         noisersList,
-				connPersistentSet:   false,
-				SelectedEncryptAlgo: nil,
-				SelectedNoiser:      nil,
-				SelectedChannels:    common.Channels{},
-				ListenIface:         net.ParseIP("127.0.0.1"),
-				Channels:            []ConfigChannel{},
+				encryptAlgos:      encryptAlgos,
+				encryptAlgosList:  encryptAlgosList,
+				noisers:           noisers,
+				noisersList:       noisersList,
+				stages:            stages,
+				connPersistentSet: false,
+				failureWaitSet:    false,
+				SelectedChannels:  common.Channels{},
+				SelectedKeys:      common.Keys{},
+				SelectedKeyring:   ccommon.Keys{},
+				ListenIface:       net.ParseIP("127.0.0.1"),
+				Channels:          []ConfigChannel{},
+				Keys:              []ConfigKey{},
+				PreviousKeys:      []ConfigPreviousKey{},
+				Destinations:      []ConfigDestination{},
+				Pipeline:          []wrapper.ConfigStage{},
 			}
 		},
 		Generater: func(
@@ -347,20 +894,111 @@ func Role() role.Registration {
 		) (role.Role, error) {
 			cfg := config.(*ConfigInput)
 
-			tspServer := transporter.NewServer(tcp.NewServer(
-				cfg.ListenIface,
-				cfg.ListenPort,
-				time.Duration(cfg.ConnectTimeout)*time.Second,
-				time.Duration(cfg.IdleTimeout)*time.Second,
-				cfg.SelectedEncryptAlgo([]byte(cfg.EncryptionKey)),
-				cfg.SelectedNoiser([]byte(cfg.EncryptionKey)),
-			), cfg.ConnPersistent)
+			pipeline, pipelineErr := cfg.stages.Pipeline(
+				cfg.Pipeline, cfg.SelectedKeyring, log.Context("Pipeline"))
+
+			if pipelineErr != nil {
+				return nil, pipelineErr
+			}
+
+			var listener transporter.ServerConnListener
+
+			if cfg.TLSCertificate != "" {
+				log.Context("TLS").Infof("Certificate fingerprint: %s",
+					cfg.SelectedTLSCertificate.Fingerprint())
+			}
+
+			failure := ccommon.FailurePolicy{
+				MinWait:  time.Duration(cfg.FailureWait) * time.Second / 2,
+				MaxWait:  time.Duration(cfg.FailureWait) * time.Second,
+				Fallback: cfg.Fallback,
+			}
+
+			if cfg.Multiplex {
+				listener = mux.NewServer(
+					cfg.ListenIface,
+					cfg.ListenPort,
+					time.Duration(cfg.ConnectTimeout)*time.Second,
+					time.Duration(cfg.IdleTimeout)*time.Second,
+					pipeline,
+					failure,
+				)
+			} else if cfg.WebSocket {
+				var certificate *tls.Certificate
+
+				if cfg.TLSCertificate != "" {
+					certificate = &cfg.SelectedTLSCertificate
+				}
+
+				listener = websocket.NewServer(
+					cfg.ListenIface,
+					cfg.ListenPort,
+					time.Duration(cfg.ConnectTimeout)*time.Second,
+					time.Duration(cfg.IdleTimeout)*time.Second,
+					pipeline,
+					cfg.WebSocketPath,
+					certificate,
+					failure,
+				)
+			} else if cfg.TLSCertificate != "" {
+				listener = tls.NewServer(
+					cfg.ListenIface,
+					cfg.ListenPort,
+					time.Duration(cfg.ConnectTimeout)*time.Second,
+					time.Duration(cfg.IdleTimeout)*time.Second,
+					pipeline,
+					cfg.SelectedTLSCertificate,
+					failure,
+				)
+			} else {
+				listener = tcp.NewServer(
+					cfg.ListenIface,
+					cfg.ListenPort,
+					time.Duration(cfg.ConnectTimeout)*time.Second,
+					time.Duration(cfg.IdleTimeout)*time.Second,
+					pipeline,
+					failure,
+				)
+			}
+
+			var datagramServer *datagram.Server
+
+			if cfg.DatagramPort > 0 {
+				datagramServer = datagram.NewServer(
+					cfg.ListenIface, cfg.DatagramPort)
+			}
+
+			var dnsClient dns.Client
+
+			if cfg.DNSServer != "" {
+				var dnsClientErr error
+
+				dnsClient, dnsClientErr = dns.NewClient(
+					[]string{cfg.DNSServer}, cfg.DNSNetwork,
+					time.Duration(cfg.ConnectTimeout)*time.Second)
+
+				if dnsClientErr != nil {
+					return nil, dnsClientErr
+				}
+			}
+
+			tspServer := transporter.NewServer(listener, cfg.ConnPersistent)
 
 			return New(tspServer, Config{
 				Channels:       cfg.SelectedChannels,
+				Keys:           cfg.SelectedKeys,
+				Destinations:   cfg.SelectedDestinations,
 				Logger:         log.Context("Proxy"),
 				ConnectTimeout: time.Duration(cfg.ConnectTimeout) * time.Second,
 				IdleTimeout:    time.Duration(cfg.IdleTimeout) * time.Second,
+				Datagram:       datagramServer,
+				Resolver: dns.NewResolver(dns.ResolverConfig{
+					Client:      dnsClient,
+					Fallback:    false,
+					Prefer:      cfg.SelectedDNSPrefer,
+					NegativeTTL: dns.DefaultNegativeTTL,
+					CacheSize:   int(cfg.DNSCacheSize),
+				}),
 			}), nil
 		},
 	}
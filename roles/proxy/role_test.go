@@ -0,0 +1,150 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nickrio/coward/common/config"
+	"github.com/nickrio/coward/common/role"
+	"github.com/nickrio/coward/roles/common/network/communicator/common/wrapper"
+	"github.com/nickrio/coward/roles/common/network/messaging"
+	"github.com/nickrio/coward/roles/proxy/common"
+)
+
+const testConfigBase = "-la 127.0.0.1 -lp 18500 -it 60 -ct 10 " +
+	"-ea aes-gcm-256 -nr chaotic -ch {-i 1 -h example.com -p 80 -pr tcp} " +
+	"{-i 2 -h example.com -p 443 -pr tcp} "
+
+func testParseConfig(parameters string) (*ConfigInput, error) {
+	cfg := Role().Configurator(role.Components{
+		wrapper.AESGCM256, wrapper.Chaotic,
+	}).(*ConfigInput)
+
+	configurator, importErr := config.Import(cfg)
+
+	if importErr != nil {
+		return nil, importErr
+	}
+
+	parseErr := configurator.Parse([]byte(testConfigBase + parameters))
+
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	return cfg, nil
+}
+
+func TestConfigDefaultKey(t *testing.T) {
+	for _, bind := range []bool{false, true} {
+		parameters := "-ek 0123456789abcdef0"
+
+		if bind {
+			parameters += " -bd true"
+		}
+
+		cfg, cfgErr := testParseConfig(parameters)
+
+		if cfgErr != nil {
+			t.Error("Failed to parse config due to error:", cfgErr)
+
+			return
+		}
+
+		key, keyErr := cfg.SelectedKeys.Get(DefaultKeyName)
+
+		if keyErr != nil {
+			t.Error("Failed to get the default key due to error:", keyErr)
+
+			return
+		}
+
+		for name, cmd := range common.KeyCommands {
+			expected := cmd != messaging.BindTCP || bind
+
+			if key.Command(cmd) != expected {
+				t.Errorf("Expecting the default key to be permitted to "+
+					"%s: %t, got %t", name, expected, !expected)
+
+				return
+			}
+		}
+
+		for _, id := range []byte{1, 2} {
+			if !key.Channel(id) {
+				t.Errorf("Expecting the default key to be permitted to "+
+					"open channel %d", id)
+
+				return
+			}
+		}
+
+		if key.Channel(3) {
+			t.Error("Expecting undefined channel 3 to be not permitted")
+
+			return
+		}
+	}
+}
+
+func TestConfigPreviousKeys(t *testing.T) {
+	cfg, cfgErr := testParseConfig("-ek 0123456789abcdef0 " +
+		"-pk {-k 0123456789abcdef1 -e 2017-01-02T15:04:05Z}")
+
+	if cfgErr != nil {
+		t.Error("Failed to parse config due to error:", cfgErr)
+
+		return
+	}
+
+	expire, _ := time.Parse(time.RFC3339, "2017-01-02T15:04:05Z")
+	previousName := PreviousKeyName + "1"
+
+	if len(cfg.SelectedKeyring) != 2 ||
+		cfg.SelectedKeyring[1].Name != previousName ||
+		!cfg.SelectedKeyring[1].Expire.Equal(expire) {
+		t.Errorf("Expecting previous key %s to expire at %s, got %v",
+			previousName, expire, cfg.SelectedKeyring)
+
+		return
+	}
+
+	_, keyErr := cfg.SelectedKeys.Get(previousName)
+
+	if keyErr != nil {
+		t.Error("Failed to get the previous key due to error:", keyErr)
+
+		return
+	}
+
+	for _, expire := range []string{"", "2017-01-02", "tomorrow"} {
+		_, cfgErr = testParseConfig("-ek 0123456789abcdef0 " +
+			"-pk {-k 0123456789abcdef1 -e \"" + expire + "\"}")
+
+		if cfgErr == nil {
+			t.Errorf("Expecting expire time \"%s\" to be invalid", expire)
+
+			return
+		}
+	}
+}
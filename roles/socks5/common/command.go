@@ -36,13 +36,14 @@ var (
 type CTYPE byte
 
 // CTYPES is how many CTYPE there is
-const CTYPES = 5
+const CTYPES = 0xF1
 
 // Consts used by command
 const (
 	Connect CTYPE = 0x01
 	Bind    CTYPE = 0x02
 	UDP     CTYPE = 0x03
+	Resolve CTYPE = 0xF0 // Tor extension, resolves the host name remotely
 )
 
 // Commander is the command runer
@@ -0,0 +1,74 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package common
+
+import "errors"
+
+const (
+	// Version4 is the version of Socks 4 and 4a clients
+	Version4 byte = 4
+
+	// Socks4ReplyVersion is the VN of Socks 4 server reply
+	Socks4ReplyVersion byte = 0
+
+	// Socks4Connect is the CONNECT command of Socks 4
+	Socks4Connect byte = 0x01
+
+	// Socks4MaxStringLength is the max length of the USERID and the
+	// host name which carried by the Socks 4 request
+	Socks4MaxStringLength = 255
+)
+
+var (
+	// ErrSocks4AuthRequired is throwed when a Socks 4 client is trying
+	// to connect to a server which requires password authentication
+	ErrSocks4AuthRequired = errors.New(
+		"Socks4 client can't provide the required authentication")
+
+	// ErrUnsupportedSocks4Command is throwed when the Socks 4 command
+	// is not supported
+	ErrUnsupportedSocks4Command = errors.New(
+		"Unsupported Socks4 command type")
+
+	// ErrFailedToReadSocks4Request is throwed when server is failed to
+	// read all Socks 4 request bytes
+	ErrFailedToReadSocks4Request = errors.New(
+		"Failed to read all Socks4 request bytes")
+
+	// ErrSocks4StringTooLong is throwed when the USERID or the host name
+	// of the Socks 4 request is too long
+	ErrSocks4StringTooLong = errors.New(
+		"Socks4 USERID or host name is too long")
+
+	// ErrSocks4EmptyHost is throwed when the Socks 4a request carries an
+	// empty host name
+	ErrSocks4EmptyHost = errors.New(
+		"Socks4a host name is empty")
+)
+
+// CD is the CD in Socks 4 server respond
+type CD byte
+
+// CDs
+const (
+	Socks4Granted  CD = 0x5A
+	Socks4Rejected CD = 0x5B
+)
@@ -30,9 +30,11 @@ import (
 
 // Config is the configuration of Socks 5 server
 type Config struct {
-	Auth      common.AutherUserVerifier
-	Timeout   time.Duration
-	Interface net.IP
-	Port      uint16
-	Logger    logger.Logger
+	Auth          common.AutherUserVerifier
+	Timeout       time.Duration
+	Interface     net.IP
+	Port          uint16
+	RemoteResolve bool
+	Probe         bool
+	Logger        logger.Logger
 }
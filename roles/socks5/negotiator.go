@@ -53,10 +53,12 @@ type negotiator struct {
 	atypeBlock  *common.Address
 	proc        ccommon.Proccessors
 	cmd         common.Command
+	resolve     bool
 	current     negotiation
 	buffer      []byte
 	steps       [3]func(net.Conn) error
 	request     func(string, balancer.DelayFeedingbackRequestBuilder) error
+	probe       func(string, balancer.DelayFeedingbackRequestBuilder)
 }
 
 func (n *negotiator) Inital() {
@@ -70,6 +72,8 @@ func (n *negotiator) Inital() {
 			target []byte,
 			rw net.Conn,
 		) error {
+			n.probeConnect(aType, addr, port, target)
+
 			return n.request(
 				"TCP"+string(target),
 				func(
@@ -100,6 +104,50 @@ func (n *negotiator) Inital() {
 				})
 		})
 
+	n.cmd.Register(common.Bind,
+		func(
+			aType common.ATYPE,
+			addr []byte,
+			port []byte,
+			target []byte,
+			rw net.Conn,
+		) error {
+			return n.request(
+				"BND"+string(target),
+				func(
+					cfg transporter.HandlerConfig,
+					delayBack func(time.Duration),
+				) transporter.Handler {
+					return request.NewBindRequest(cfg, n.proc, n.atypeBlock,
+						rw, aType, addr, port, delayBack)
+				})
+		})
+
+	if n.resolve {
+		n.cmd.Register(common.Resolve,
+			func(
+				aType common.ATYPE,
+				addr []byte,
+				port []byte,
+				target []byte,
+				rw net.Conn,
+			) error {
+				if aType != common.Domain {
+					return common.ErrUnsupportedAddressType
+				}
+
+				return n.request(
+					"RES"+string(addr),
+					func(
+						cfg transporter.HandlerConfig,
+						delayBack func(time.Duration),
+					) transporter.Handler {
+						return request.NewResolveRequest(cfg, n.proc,
+							n.atypeBlock, rw, addr, delayBack)
+					})
+			})
+	}
+
 	// Steps
 	n.steps = [3]func(net.Conn) error{
 		// Handshake
@@ -130,7 +178,17 @@ func (n *negotiator) Inital() {
 				return common.ErrFailedToReadHandshakeHead
 			}
 
-			if n.buffer[0] != common.Version {
+			switch n.buffer[0] {
+			case common.Version:
+
+			case common.Version4:
+				// Socks 4 has no handshake, the request head is
+				// already in the buffer
+				n.current = finish
+
+				return n.socks4(rw)
+
+			default:
 				return common.ErrUnsupportedSocksVersion
 			}
 
@@ -275,6 +333,34 @@ func (n *negotiator) Inital() {
 	}
 }
 
+// probeConnect probes the CONNECT destination through all remotes
+// when probing is enabled
+func (n *negotiator) probeConnect(
+	aType common.ATYPE,
+	addr []byte,
+	port []byte,
+	target []byte,
+) {
+	if n.probe == nil {
+		return
+	}
+
+	// The probe requests may outlive current request, so they can't
+	// share the buffer
+	probeAddr := append([]byte{}, addr...)
+	probePort := append([]byte{}, port...)
+
+	n.probe(
+		"TCP"+string(target),
+		func(
+			cfg transporter.HandlerConfig,
+			delayBack func(time.Duration),
+		) transporter.Handler {
+			return request.NewPingRequest(cfg, aType,
+				probeAddr, probePort, delayBack)
+		})
+}
+
 func (n *negotiator) Loop(rw net.Conn) error {
 	currentErr := n.steps[n.current](rw)
 
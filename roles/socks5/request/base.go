@@ -58,8 +58,8 @@ func (b *base) errorRespond(
 	// +----+-----+-------+------+----------+----------+
 	//
 	buffer[0] = common.Version // VER
-	buffer[1] = 0              // REP
-	buffer[2] = byte(err)      // RSV
+	buffer[1] = byte(err)      // REP
+	buffer[2] = 0              // RSV
 	buffer[3] = 1              // ATYP: Make up a Address. IPv4 0.0.0.0:0
 	buffer[4] = 0              // BND.ADDR IPv4: 0
 	buffer[5] = 0              // BND.ADDR IPv4: 0
@@ -0,0 +1,306 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package request
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	ccommon "github.com/nickrio/coward/common"
+	"github.com/nickrio/coward/common/types"
+	"github.com/nickrio/coward/roles/common/network"
+	"github.com/nickrio/coward/roles/common/network/address"
+	"github.com/nickrio/coward/roles/common/network/conn"
+	"github.com/nickrio/coward/roles/common/network/messaging"
+	"github.com/nickrio/coward/roles/common/network/relay"
+	"github.com/nickrio/coward/roles/common/network/transporter"
+	"github.com/nickrio/coward/roles/socks5/common"
+)
+
+// Bind request errors
+var (
+	ErrInvalidBindReply = errors.New(
+		"Invalid bind reply from server")
+
+	ErrBindClientGone = errors.New(
+		"Client has gone before the inbound connection")
+)
+
+const (
+	// bindWatchInterval is how often the client watcher checks whether
+	// or not it should stop
+	bindWatchInterval = 200 * time.Millisecond
+)
+
+type bind struct {
+	base
+
+	addresser *common.Address
+	addrType  address.Type
+	addr      []byte
+	port      uint16
+}
+
+// NewBindRequest creates a new bind request, which asks the server to
+// accept an inbound connection for the client
+func NewBindRequest(
+	config transporter.HandlerConfig,
+	proc ccommon.Proccessors,
+	addresser *common.Address,
+	client net.Conn,
+	targetType common.ATYPE,
+	targetAddr []byte,
+	targetPort []byte,
+	delayFeedback func(time.Duration),
+) transporter.Handler {
+	var addrType address.Type
+
+	switch targetType {
+	case common.IPv4:
+		addrType = address.IPv4
+
+	case common.IPv6:
+		addrType = address.IPv6
+
+	case common.Domain:
+		addrType = address.Domain
+
+	default:
+		panic(fmt.Sprintf("Unknown target type: %v", targetType))
+	}
+
+	port := types.EncodableUint16(0)
+
+	port.DecodeBytes(targetPort)
+
+	return &bind{
+		base: base{
+			buffer:        config.Buffer,
+			proc:          proc,
+			address:       nil,
+			server:        config.Server,
+			client:        client,
+			delayFeedback: delayFeedback,
+			retryRequest:  false,
+			resetTspConn:  false,
+		},
+		addresser: addresser,
+		addrType:  addrType,
+		addr:      append([]byte{}, targetAddr...),
+		port:      uint16(port),
+	}
+}
+
+// reply tells the client the address carried by the server reply
+func (b *bind) reply(addrData []byte) error {
+	addr := address.TCP{}
+
+	_, decodeErr := addr.Decode(addrData)
+
+	if decodeErr != nil {
+		return ErrInvalidBindReply
+	}
+
+	//  +-----+-----+-------+------+----------+----------+
+	//  | VER | REP |  RSV  | ATYP | BND.ADDR | BND.PORT |
+	//  +-----+-----+-------+------+----------+----------+
+	//  |  1  |  1  | X'00' |  1   | Variable |    2     |
+	//  +-----+-----+-------+------+----------+----------+
+	buf := b.buffer.Client.ExtendedBuffer
+
+	buf[0] = common.Version // VER
+	buf[1] = 0              // REP
+	buf[2] = 0              // RSV
+
+	pLen, pErr := b.addresser.PackIP(addr.IP, uint16(addr.Port), buf[3:])
+
+	if pErr != nil {
+		return ErrFailedToEncodeAddress
+	}
+
+	_, wErr := b.client.Write(buf[:pLen+3])
+
+	if wErr != nil {
+		return ErrFailedSendReadySignalToClient
+	}
+
+	return nil
+}
+
+// waitPeer waits for the second reply of the server. Closed will be
+// sent to the server when the client has gone or the reply is an
+// error, as the server is waiting for our answer
+func (b *bind) waitPeer(proc ccommon.Proccessors) error {
+	replied := make(chan error, 1)
+	watched := make(chan error, 1)
+	stopWatch := make(chan struct{})
+
+	go func() {
+		replied <- b.Dispatch(b.server, b.buffer.Server.Buffer, proc)
+	}()
+
+	go func() {
+		watched <- b.watchClient(stopWatch)
+	}()
+
+	select {
+	case dispErr := <-replied:
+		close(stopWatch)
+
+		watchErr := <-watched
+
+		if dispErr == nil {
+			dispErr = watchErr
+		}
+
+		if dispErr != nil {
+			b.Write(b.server, messaging.Closed, nil,
+				b.buffer.Server.ExtendedBuffer)
+		}
+
+		return dispErr
+
+	case watchErr := <-watched:
+		b.Write(b.server, messaging.Closed, nil,
+			b.buffer.Server.ExtendedBuffer)
+
+		// The server may have replied already, so the transport can't
+		// be reused
+		return watchErr
+	}
+}
+
+// watchClient reads the client until stop is closed. The client should
+// send nothing before the second reply, so it's considered as gone
+// once the read returned
+func (b *bind) watchClient(stop chan struct{}) error {
+	buf := [1]byte{}
+
+	for {
+		// The read is waken up periodically as the deadline of the
+		// client can't be changed while it's reading
+		b.client.SetReadDeadline(time.Now().Add(bindWatchInterval))
+
+		_, rErr := b.client.Read(buf[:])
+
+		netErr, isNetErr := rErr.(net.Error)
+
+		if rErr != conn.ErrReadTimeout &&
+			(!isNetErr || !netErr.Timeout()) {
+			return ErrBindClientGone
+		}
+
+		select {
+		case <-stop:
+			return nil
+
+		default:
+		}
+	}
+}
+
+func (b *bind) Handle() error {
+	var replied []byte
+
+	startTime := time.Now()
+
+	// Enable retry and reset
+	b.resetTspConn = true
+	b.retryRequest = true
+
+	addrLen, addrErr := address.Default.Pack(
+		b.addrType, b.addr, b.port, b.buffer.Client.Buffer)
+
+	if addrErr != nil {
+		return addrErr
+	}
+
+	_, writeErr := b.Write(b.server, messaging.BindTCP,
+		b.buffer.Client.Buffer[:addrLen], b.buffer.Server.ExtendedBuffer)
+
+	if writeErr != nil {
+		return writeErr
+	}
+
+	proc := network.GetDefaultProc().Register(messaging.OK,
+		func(buffer []byte, rw io.ReadWriter, size uint16) error {
+			_, rErr := io.ReadFull(rw, buffer[:size])
+
+			if rErr != nil {
+				return rErr
+			}
+
+			replied = buffer[:size]
+
+			return nil
+		})
+
+	// First reply: The address which the server is listening
+	dispErr := b.Dispatch(b.server, b.buffer.Server.Buffer, proc)
+
+	if dispErr != nil {
+		return dispErr
+	}
+
+	b.delayFeedback(time.Now().Sub(startTime))
+
+	// Server is waiting for the inbound connection now, retry is no
+	// longer possible
+	b.retryRequest = false
+
+	replyErr := b.reply(replied)
+
+	if replyErr != nil {
+		return replyErr
+	}
+
+	// Second reply: The address of the connected peer. The client
+	// is watched meanwhile, so the server can stop waiting for the
+	// peer once the client is gone
+	dispErr = b.waitPeer(proc)
+
+	if dispErr != nil {
+		return dispErr
+	}
+
+	// Tell the server we're ready for the relay
+	_, writeErr = b.Write(b.server, messaging.OK, nil,
+		b.buffer.Server.ExtendedBuffer)
+
+	if writeErr != nil {
+		return writeErr
+	}
+
+	b.resetTspConn = false
+
+	replyErr = b.reply(replied)
+
+	if replyErr != nil {
+		b.Write(b.server, messaging.EOF, nil, b.buffer.Server.ExtendedBuffer)
+
+		return replyErr
+	}
+
+	return relay.NewTCPRelay(b.client, b.server, b.buffer, nil).Relay()
+}
@@ -0,0 +1,291 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package request
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	ccommon "github.com/nickrio/coward/common"
+	"github.com/nickrio/coward/common/types"
+	"github.com/nickrio/coward/roles/common/network"
+	"github.com/nickrio/coward/roles/common/network/address"
+	"github.com/nickrio/coward/roles/common/network/buffer"
+	"github.com/nickrio/coward/roles/common/network/messaging"
+	"github.com/nickrio/coward/roles/common/network/transporter"
+	"github.com/nickrio/coward/roles/socks5/common"
+)
+
+// testBind is a bind request which talks to Server and Client
+type testBind struct {
+	Server net.Conn
+	Client net.Conn
+	result chan error
+	buf    [messaging.HeadSize + 1024]byte
+}
+
+func testNewBind() *testBind {
+	server, serverPeer := net.Pipe()
+	client, clientPeer := net.Pipe()
+	buf := &buffer.Buffer{}
+	b := &testBind{
+		Server: serverPeer,
+		Client: clientPeer,
+		result: make(chan error, 1),
+	}
+
+	handler := NewBindRequest(transporter.HandlerConfig{
+		Server: server,
+		Buffer: buf.Slice(),
+	}, network.GetDefaultProc(), &common.Address{}, client, common.IPv4,
+		[]byte{192, 0, 2, 1}, []byte{0, 0}, func(time.Duration) {})
+
+	go func() {
+		b.result <- handler.Handle()
+
+		server.Close()
+		client.Close()
+	}()
+
+	return b
+}
+
+// Result waits for the request to be handled
+func (t *testBind) Result() error {
+	select {
+	case err := <-t.result:
+		return err
+
+	case <-time.After(time.Second):
+		return io.ErrNoProgress
+	}
+}
+
+// Write writes a message to the request as the server
+func (t *testBind) Write(cmd ccommon.Command, data []byte) error {
+	_, wErr := (&messaging.Messaging{}).Write(t.Server, cmd, data, t.buf[:])
+
+	return wErr
+}
+
+// WriteAddr writes a message which carries addr to the request
+func (t *testBind) WriteAddr(cmd ccommon.Command, addr *net.TCPAddr) error {
+	addrBuf := [32]byte{}
+	tcpAddr := address.TCP(*addr)
+
+	encodeLen, encodeErr := tcpAddr.Encode(addrBuf[:])
+
+	if encodeErr != nil {
+		return encodeErr
+	}
+
+	return t.Write(cmd, addrBuf[:encodeLen])
+}
+
+// Read reads a message which the request sent to the server
+func (t *testBind) Read() (ccommon.Command, []byte, error) {
+	t.Server.SetReadDeadline(time.Now().Add(time.Second))
+
+	_, rErr := io.ReadFull(t.Server, t.buf[:messaging.HeadSize])
+
+	if rErr != nil {
+		return 0, nil, rErr
+	}
+
+	size := types.EncodableUint16(0)
+
+	decodeErr := size.DecodeBytes(t.buf[1:messaging.HeadSize])
+
+	if decodeErr != nil {
+		return 0, nil, decodeErr
+	}
+
+	data := make([]byte, size)
+
+	_, rErr = io.ReadFull(t.Server, data)
+
+	if rErr != nil {
+		return 0, nil, rErr
+	}
+
+	return ccommon.Command(t.buf[0]), data, nil
+}
+
+// ReadReply reads a reply which the request sent to the client
+func (t *testBind) ReadReply() ([]byte, error) {
+	// VER, REP, RSV, ATYP, 4 bytes of IPv4 address and 2 bytes of port
+	reply := make([]byte, 10)
+
+	t.Client.SetReadDeadline(time.Now().Add(time.Second))
+
+	_, rErr := io.ReadFull(t.Client, reply)
+
+	return reply, rErr
+}
+
+// Close closes the test connections
+func (t *testBind) Close() error {
+	t.Client.Close()
+
+	return t.Server.Close()
+}
+
+func testBindExpect(t *testing.T, b *testBind, expected ccommon.Command) bool {
+	cmd, _, rErr := b.Read()
+
+	if rErr != nil {
+		t.Error("Failed to read due to error:", rErr)
+
+		return false
+	}
+
+	if cmd != expected {
+		t.Errorf("Expecting message %d, got %d", expected, cmd)
+
+		return false
+	}
+
+	return true
+}
+
+func testBindListening(t *testing.T, b *testBind) bool {
+	if !testBindExpect(t, b, messaging.BindTCP) {
+		return false
+	}
+
+	wErr := b.WriteAddr(messaging.OK, &net.TCPAddr{
+		IP:   net.IPv4(192, 0, 2, 2).To4(),
+		Port: 1080,
+	})
+
+	if wErr != nil {
+		t.Error("Failed to write due to error:", wErr)
+
+		return false
+	}
+
+	reply, rErr := b.ReadReply()
+
+	if rErr != nil {
+		t.Error("Failed to read the first reply due to error:", rErr)
+
+		return false
+	}
+
+	if reply[1] != 0 || reply[8] != 0x04 || reply[9] != 0x38 {
+		t.Errorf("Unexpected first reply: %v", reply)
+
+		return false
+	}
+
+	return true
+}
+
+func TestBindClientGone(t *testing.T) {
+	b := testNewBind()
+
+	defer b.Close()
+
+	if !testBindListening(t, b) {
+		return
+	}
+
+	b.Client.Close()
+
+	if !testBindExpect(t, b, messaging.Closed) {
+		return
+	}
+
+	result := b.Result()
+
+	if result != ErrBindClientGone {
+		t.Errorf("Expecting error %s, got %v", ErrBindClientGone, result)
+
+		return
+	}
+}
+
+func TestBindErrorReply(t *testing.T) {
+	b := testNewBind()
+
+	defer b.Close()
+
+	if !testBindListening(t, b) {
+		return
+	}
+
+	b.Write(messaging.Timeout, nil)
+
+	if !testBindExpect(t, b, messaging.Closed) {
+		return
+	}
+
+	result := b.Result()
+
+	if result != network.ErrProcTimeout {
+		t.Errorf("Expecting error %s, got %v",
+			network.ErrProcTimeout, result)
+
+		return
+	}
+}
+
+func TestBindConnected(t *testing.T) {
+	b := testNewBind()
+
+	defer b.Close()
+
+	if !testBindListening(t, b) {
+		return
+	}
+
+	wErr := b.WriteAddr(messaging.OK, &net.TCPAddr{
+		IP:   net.IPv4(192, 0, 2, 1).To4(),
+		Port: 80,
+	})
+
+	if wErr != nil {
+		t.Error("Failed to write due to error:", wErr)
+
+		return
+	}
+
+	// The server must be told before the peer is relayed
+	if !testBindExpect(t, b, messaging.OK) {
+		return
+	}
+
+	reply, rErr := b.ReadReply()
+
+	if rErr != nil {
+		t.Error("Failed to read the second reply due to error:", rErr)
+
+		return
+	}
+
+	if reply[1] != 0 || reply[4] != 192 || reply[7] != 1 || reply[9] != 80 {
+		t.Errorf("Unexpected second reply: %v", reply)
+
+		return
+	}
+}
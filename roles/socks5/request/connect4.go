@@ -0,0 +1,193 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package request
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	ccommon "github.com/nickrio/coward/common"
+	"github.com/nickrio/coward/common/codec"
+	"github.com/nickrio/coward/roles/common/network"
+	"github.com/nickrio/coward/roles/common/network/messaging"
+	"github.com/nickrio/coward/roles/common/network/relay"
+	"github.com/nickrio/coward/roles/common/network/transporter"
+	"github.com/nickrio/coward/roles/socks5/common"
+)
+
+type connect4 struct {
+	base
+
+	command ccommon.Command
+}
+
+// NewConnect4Request creates a new connect request for Socks 4 and 4a
+// clients
+func NewConnect4Request(
+	config transporter.HandlerConfig,
+	proc ccommon.Proccessors,
+	client net.Conn,
+	targetType common.ATYPE,
+	targetAddr []byte,
+	targetPort []byte,
+	delayFeedback func(time.Duration),
+) transporter.Handler {
+	var cmdType ccommon.Command
+
+	switch targetType {
+	case common.IPv4:
+		cmdType = messaging.ConnectIPv4
+
+	case common.Domain:
+		cmdType = messaging.ConnectHost
+
+	default:
+		panic(fmt.Sprintf("Unknown target type: %v", targetType))
+	}
+
+	return &connect4{
+		base: base{
+			buffer:        config.Buffer,
+			proc:          proc,
+			address:       append(targetAddr, targetPort...),
+			server:        config.Server,
+			client:        client,
+			delayFeedback: delayFeedback,
+			retryRequest:  false,
+			resetTspConn:  false,
+		},
+		command: cmdType,
+	}
+}
+
+func (c *connect4) respond(cd common.CD) error {
+	// Respond format:
+	//
+	// +----+----+---------+-------+
+	// | VN | CD | DSTPORT | DSTIP |
+	// +----+----+---------+-------+
+	// | 1  | 1  |    2    |   4   |
+	// +----+----+---------+-------+
+	//
+	// DSTPORT and DSTIP will be ignored by the client for CONNECT
+	buf := c.buffer.Client.ExtendedBuffer
+
+	buf[0] = common.Socks4ReplyVersion // VN
+	buf[1] = byte(cd)                  // CD
+	buf[2] = 0                         // DSTPORT
+	buf[3] = 0                         // DSTPORT
+	buf[4] = 0                         // DSTIP
+	buf[5] = 0                         // DSTIP
+	buf[6] = 0                         // DSTIP
+	buf[7] = 0                         // DSTIP
+
+	wLen, wErr := c.client.Write(buf[:8])
+
+	if wErr != nil {
+		return wErr
+	}
+
+	if wLen != 8 {
+		return ErrFailedToSendAllDataToClient
+	}
+
+	return nil
+}
+
+func (c *connect4) Error(err error) (bool, bool, error) {
+	handleErr := err
+	tspErr, isTSPErr := handleErr.(transporter.Error)
+
+	if isTSPErr {
+		handleErr = tspErr.Raw()
+	}
+
+	switch e := handleErr.(type) {
+	case codec.Error:
+		return false, true, err
+
+	default:
+		switch e {
+		case io.EOF:
+			return c.retryRequest, c.resetTspConn, nil
+
+		case network.ErrProcRemoteTargetClosed:
+
+		case network.ErrProcServerInternalError:
+			fallthrough
+		case network.ErrProcUnsupported:
+			fallthrough
+		case network.ErrProcInvalid:
+			fallthrough
+		case network.ErrProcServerRefused:
+			fallthrough
+		case network.ErrProcTimeout:
+			fallthrough
+		case network.ErrProcRemoteTargetUnconnectable:
+			fallthrough
+		case network.ErrProcUnsupportedCommand:
+			// Socks 4 has only one code for all kinds of failures
+			c.respond(common.Socks4Rejected)
+
+			return false, false, err
+		}
+	}
+
+	return c.retryRequest, c.resetTspConn, err
+}
+
+func (c *connect4) Handle() error {
+	startTime := time.Now()
+
+	// Enable retry and reset
+	c.resetTspConn = true
+	c.retryRequest = true
+
+	_, writeErr := c.Write(
+		c.server, c.command, c.address, c.buffer.Server.ExtendedBuffer)
+
+	if writeErr != nil {
+		return writeErr
+	}
+
+	dispErr := c.Dispatch(c.server, c.buffer.Server.Buffer, c.proc)
+
+	if dispErr != nil {
+		return dispErr
+	}
+
+	c.delayFeedback(time.Now().Sub(startTime))
+
+	c.retryRequest = false
+	c.resetTspConn = false
+
+	wErr := c.respond(common.Socks4Granted)
+
+	if wErr != nil {
+		c.Write(c.server, messaging.EOF, nil, c.buffer.Server.ExtendedBuffer)
+
+		return ErrFailedSendReadySignalToClient
+	}
+
+	return relay.NewTCPRelay(c.client, c.server, c.buffer, nil).Relay()
+}
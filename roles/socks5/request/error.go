@@ -29,6 +29,9 @@ var (
 
 	ErrFailedToEncodeAddress = errors.New(
 		"Failed to encode IP address")
+
+	ErrNoResolvedAddress = errors.New(
+		"Server resolved no address")
 )
 
 var (
@@ -0,0 +1,163 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package request
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	ccommon "github.com/nickrio/coward/common"
+	"github.com/nickrio/coward/common/codec"
+	"github.com/nickrio/coward/roles/common/network"
+	"github.com/nickrio/coward/roles/common/network/messaging"
+	"github.com/nickrio/coward/roles/common/network/transporter"
+	"github.com/nickrio/coward/roles/socks5/common"
+)
+
+type ping struct {
+	messaging.Messaging
+
+	buffer        []byte
+	extBuffer     []byte
+	address       []byte
+	server        io.ReadWriter
+	command       ccommon.Command
+	delayFeedback func(time.Duration)
+	resetTspConn  bool
+}
+
+// NewPingRequest creates a new ping request, which asks the server to
+// measure how long it takes to connect the destination. The socks
+// client will not be touched
+func NewPingRequest(
+	config transporter.HandlerConfig,
+	targetType common.ATYPE,
+	targetAddr []byte,
+	targetPort []byte,
+	delayFeedback func(time.Duration),
+) transporter.Handler {
+	var cmdType ccommon.Command
+
+	switch targetType {
+	case common.IPv4:
+		cmdType = messaging.PingIPv4
+
+	case common.IPv6:
+		cmdType = messaging.PingIPv6
+
+	case common.Domain:
+		cmdType = messaging.PingHost
+
+	default:
+		panic(fmt.Sprintf("Unknown target type: %v", targetType))
+	}
+
+	address := make([]byte, 0, len(targetAddr)+len(targetPort))
+
+	return &ping{
+		buffer:        config.Buffer.Server.Buffer,
+		extBuffer:     config.Buffer.Server.ExtendedBuffer,
+		address:       append(append(address, targetAddr...), targetPort...),
+		server:        config.Server,
+		command:       cmdType,
+		delayFeedback: delayFeedback,
+		resetTspConn:  false,
+	}
+}
+
+func (p *ping) Handle() error {
+	var latencyErr error
+
+	startTime := time.Now()
+
+	p.resetTspConn = true
+
+	_, writeErr := p.Write(p.server, p.command, p.address, p.extBuffer)
+
+	if writeErr != nil {
+		return writeErr
+	}
+
+	proc := network.GetDefaultProc().Register(messaging.OK,
+		func(buffer []byte, rw io.ReadWriter, size uint16) error {
+			_, rErr := io.ReadFull(rw, buffer[:size])
+
+			if rErr != nil {
+				return rErr
+			}
+
+			_, latencyErr = messaging.DecodePingLatency(buffer[:size])
+
+			return nil
+		})
+
+	dispErr := p.Dispatch(p.server, p.buffer, proc)
+
+	if dispErr != nil {
+		return dispErr
+	}
+
+	p.resetTspConn = false
+
+	if latencyErr != nil {
+		return latencyErr
+	}
+
+	// Feed back the whole round trip, so it can be compared with the
+	// delay of the real requests
+	p.delayFeedback(time.Now().Sub(startTime))
+
+	return nil
+}
+
+func (p *ping) Error(err error) (bool, bool, error) {
+	handleErr := err
+	tspErr, isTSPErr := handleErr.(transporter.Error)
+
+	if isTSPErr {
+		handleErr = tspErr.Raw()
+	}
+
+	switch handleErr.(type) {
+	case codec.Error:
+		return false, true, err
+	}
+
+	switch handleErr {
+	case network.ErrProcServerInternalError:
+		fallthrough
+	case network.ErrProcServerRefused:
+		fallthrough
+	case network.ErrProcRemoteTargetUnconnectable:
+		fallthrough
+	case network.ErrProcUnsupportedCommand:
+		fallthrough
+	case network.ErrProcInvalid:
+		return false, false, err
+	}
+
+	return false, p.resetTspConn, err
+}
+
+func (p *ping) Close() error {
+	return nil
+}
@@ -0,0 +1,177 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package request
+
+import (
+	"io"
+	"net"
+	"time"
+
+	ccommon "github.com/nickrio/coward/common"
+	"github.com/nickrio/coward/roles/common/network"
+	"github.com/nickrio/coward/roles/common/network/messaging"
+	"github.com/nickrio/coward/roles/common/network/transporter"
+	"github.com/nickrio/coward/roles/socks5/common"
+)
+
+type resolve struct {
+	base
+
+	addresser *common.Address
+}
+
+// NewResolveRequest creates a new resolve request, which asks the
+// server to resolve the host name so it never been resolved through
+// the local network
+func NewResolveRequest(
+	config transporter.HandlerConfig,
+	proc ccommon.Proccessors,
+	addresser *common.Address,
+	client net.Conn,
+	targetHost []byte,
+	delayFeedback func(time.Duration),
+) transporter.Handler {
+	return &resolve{
+		base: base{
+			buffer:        config.Buffer,
+			proc:          proc,
+			address:       targetHost,
+			server:        config.Server,
+			client:        client,
+			delayFeedback: delayFeedback,
+			retryRequest:  false,
+			resetTspConn:  false,
+		},
+		addresser: addresser,
+	}
+}
+
+func (r *resolve) Handle() error {
+	var records []messaging.ResolveRecord
+	var recordsErr error
+
+	startTime := time.Now()
+
+	// Enable retry and reset
+	r.resetTspConn = true
+	r.retryRequest = true
+
+	_, writeErr := r.Write(r.server, messaging.ResolveHost, r.address,
+		r.buffer.Server.ExtendedBuffer)
+
+	if writeErr != nil {
+		return writeErr
+	}
+
+	proc := network.GetDefaultProc().Register(messaging.OK,
+		func(buffer []byte, rw io.ReadWriter, size uint16) error {
+			_, rErr := io.ReadFull(rw, buffer[:size])
+
+			if rErr != nil {
+				return rErr
+			}
+
+			records, recordsErr = messaging.DecodeResolveRecords(
+				buffer[:size])
+
+			return nil
+		})
+
+	dispErr := r.Dispatch(r.server, r.buffer.Server.Buffer, proc)
+
+	if dispErr != nil {
+		return dispErr
+	}
+
+	r.delayFeedback(time.Now().Sub(startTime))
+
+	// The request is completed on the server side, nothing to retry
+	r.retryRequest = false
+	r.resetTspConn = false
+
+	if recordsErr != nil {
+		return recordsErr
+	}
+
+	// Socks 5 reply can only carry one address, pick the first IPv4
+	// one as most of the clients expecting that
+	var selected net.IP
+
+	for _, record := range records {
+		if record.IP.To4() == nil {
+			continue
+		}
+
+		selected = record.IP
+
+		break
+	}
+
+	if selected == nil && len(records) > 0 {
+		selected = records[0].IP
+	}
+
+	if selected == nil {
+		r.errorRespond(r.client, r.buffer.Client.ExtendedBuffer,
+			common.ErrorHostUnreachable)
+
+		return ErrNoResolvedAddress
+	}
+
+	//  +-----+-----+-------+------+----------+----------+
+	//  | VER | REP |  RSV  | ATYP | BND.ADDR | BND.PORT |
+	//  +-----+-----+-------+------+----------+----------+
+	//  |  1  |  1  | X'00' |  1   | Variable |    2     |
+	//  +-----+-----+-------+------+----------+----------+
+	r.buffer.Client.Buffer[0] = common.Version // VER
+	r.buffer.Client.Buffer[1] = 0              // REP
+	r.buffer.Client.Buffer[2] = 0              // RSV
+
+	pLen, pErr := r.addresser.PackIP(selected, 0,
+		r.buffer.Client.Buffer[3:])
+
+	if pErr != nil {
+		return ErrFailedToEncodeAddress
+	}
+
+	_, wErr := r.client.Write(r.buffer.Client.Buffer[:pLen+3])
+
+	return wErr
+}
+
+func (r *resolve) Error(err error) (bool, bool, error) {
+	handleErr := err
+	tspErr, isTSPErr := handleErr.(transporter.Error)
+
+	if isTSPErr {
+		handleErr = tspErr.Raw()
+	}
+
+	// Server replies Unconnectable when the host can't be resolved
+	if handleErr == network.ErrProcRemoteTargetUnconnectable {
+		r.errorRespond(r.client, r.buffer.Client.ExtendedBuffer,
+			common.ErrorHostUnreachable)
+
+		return false, false, err
+	}
+
+	return r.base.Error(err)
+}
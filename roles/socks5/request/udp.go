@@ -22,11 +22,14 @@ package request
 
 import (
 	"errors"
+	"io"
 	"net"
 	"sync"
 	"time"
 
 	ccommon "github.com/nickrio/coward/common"
+	"github.com/nickrio/coward/roles/common/network"
+	"github.com/nickrio/coward/roles/common/network/communicator/datagram"
 	"github.com/nickrio/coward/roles/common/network/messaging"
 	"github.com/nickrio/coward/roles/common/network/relay"
 	"github.com/nickrio/coward/roles/common/network/transporter"
@@ -44,6 +47,7 @@ type udp struct {
 
 	addresser *common.Address
 	addrType  common.ATYPE
+	datagram  transporter.DatagramDialer
 }
 
 // NewUDPRequest creates a new UDP request
@@ -70,6 +74,7 @@ func NewUDPRequest(
 		},
 		addresser: addresser,
 		addrType:  targetType,
+		datagram:  config.Datagram,
 	}
 }
 
@@ -137,19 +142,60 @@ func (u *udp) Handle() error {
 	defer udpListener.Close()
 
 	// 4, Ask server to open UDP port
+	var request []byte
+	var datagramOffer []byte
+
+	proc := u.proc
+
+	if u.datagram != nil {
+		request = datagram.Request
+		proc = network.GetDefaultProc().Register(messaging.OK,
+			func(buffer []byte, rw io.ReadWriter, size uint16) error {
+				if size <= 0 {
+					return nil
+				}
+
+				_, rErr := io.ReadFull(rw, buffer[:size])
+
+				if rErr != nil {
+					return rErr
+				}
+
+				datagramOffer = buffer[:size]
+
+				return nil
+			})
+	}
+
 	_, wErr := u.Write(u.server, messaging.RelayUDP,
-		nil, u.buffer.Server.ExtendedBuffer)
+		request, u.buffer.Server.ExtendedBuffer)
 
 	if wErr != nil {
 		return wErr
 	}
 
-	dispErr := u.Dispatch(u.server, u.buffer.Server.Buffer, u.proc)
+	dispErr := u.Dispatch(u.server, u.buffer.Server.Buffer, proc)
 
 	if dispErr != nil {
 		return dispErr
 	}
 
+	// Server offered a datagram session, packets will be exchanged
+	// through it instead of the Transporter connection
+	var datagramConn io.ReadWriteCloser
+	var datagramErr error
+
+	if datagramOffer != nil {
+		datagramConn, datagramErr = u.dialDatagram(datagramOffer)
+
+		if datagramErr != nil {
+			u.Write(u.server, messaging.EOF, nil,
+				u.buffer.Server.ExtendedBuffer)
+
+			return datagramErr
+		}
+	}
+
 	u.delayFeedback(time.Now().Sub(startTime))
 
 	// 5, Start to monitoring the client TCP connection, exit relay
@@ -184,32 +230,59 @@ func (u *udp) Handle() error {
 	u.resetTspConn = false
 
 	// 7, Start data sync form proxy to server
-	return relay.NewUDPRelay(
-		&udpHandler{
-			quitter: relayQuitterChan,
-			onReady: func() error {
-				// 3, Notifiy the client that it can send UDP packets there
-				replyErr := u.notifiyBind(
-					udpListener.LocalAddr().(*net.UDPAddr),
-					u.buffer.Client.Buffer)
-
-				if replyErr != nil {
-					u.Write(u.server, messaging.EOF, nil,
-						u.buffer.Server.ExtendedBuffer)
-
-					udpListener.Close()
-
-					return replyErr
-				}
+	handler := &udpHandler{
+		quitter: relayQuitterChan,
+		onReady: func() error {
+			// 3, Notifiy the client that it can send UDP packets there
+			replyErr := u.notifiyBind(
+				udpListener.LocalAddr().(*net.UDPAddr),
+				u.buffer.Client.Buffer)
 
-				return nil
-			},
-			clientIP:  u.client.RemoteAddr().(*net.TCPAddr).IP,
-			addresser: u.addresser,
+			if replyErr != nil {
+				u.Write(u.server, messaging.EOF, nil,
+					u.buffer.Server.ExtendedBuffer)
+
+				udpListener.Close()
+
+				return replyErr
+			}
+
+			return nil
 		},
-		udpListener,
-		u.server,
-		u.buffer,
-		nil,
-	).Relay()
+		clientIP:  u.client.RemoteAddr().(*net.TCPAddr).IP,
+		addresser: u.addresser,
+	}
+
+	if datagramConn != nil {
+		return relay.NewDatagramRelay(handler, udpListener, u.server,
+			datagramConn, u.buffer, nil).Relay()
+	}
+
+	return relay.NewUDPRelay(handler, udpListener, u.server, u.buffer,
+		nil).Relay()
+}
+
+// dialDatagram opens the datagram session offered by the server
+func (u *udp) dialDatagram(offerData []byte) (io.ReadWriteCloser, error) {
+	offer, offerErr := datagram.DecodeOffer(offerData)
+
+	if offerErr != nil {
+		return nil, offerErr
+	}
+
+	conn, dialErr := u.datagram(offer.Port)
+
+	if dialErr != nil {
+		return nil, dialErr
+	}
+
+	client, clientErr := datagram.NewClient(conn, offer.Session)
+
+	if clientErr != nil {
+		conn.Close()
+
+		return nil, clientErr
+	}
+
+	return client, nil
 }
@@ -0,0 +1,191 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package socks5
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"github.com/nickrio/coward/roles/common/network/transporter"
+	"github.com/nickrio/coward/roles/socks5/common"
+	"github.com/nickrio/coward/roles/socks5/request"
+)
+
+// socks4String reads a NULL terminated string of a Socks 4 request
+func socks4String(rw io.Reader, buf []byte) ([]byte, error) {
+	// Leave one byte for the NULL
+	maxLen := len(buf)
+
+	if maxLen > common.Socks4MaxStringLength+1 {
+		maxLen = common.Socks4MaxStringLength + 1
+	}
+
+	for strLen := 0; strLen < maxLen; strLen++ {
+		_, rErr := io.ReadFull(rw, buf[strLen:strLen+1])
+
+		if rErr != nil {
+			return nil, common.ErrFailedToReadSocks4Request
+		}
+
+		if buf[strLen] != 0 {
+			continue
+		}
+
+		return buf[:strLen], nil
+	}
+
+	return nil, common.ErrSocks4StringTooLong
+}
+
+// socks4Reject tells the Socks 4 client that the request is rejected
+func socks4Reject(rw io.Writer, buf []byte) error {
+	// Respond format:
+	//
+	// +----+----+---------+-------+
+	// | VN | CD | DSTPORT | DSTIP |
+	// +----+----+---------+-------+
+	// | 1  | 1  |    2    |   4   |
+	// +----+----+---------+-------+
+	buf[0] = common.Socks4ReplyVersion
+	buf[1] = byte(common.Socks4Rejected)
+	buf[2] = 0
+	buf[3] = 0
+	buf[4] = 0
+	buf[5] = 0
+	buf[6] = 0
+	buf[7] = 0
+
+	_, wErr := rw.Write(buf[:8])
+
+	return wErr
+}
+
+// socks4Target reads the rest of a Socks 4 or 4a request, which is
+// the part after the VN and CD, into buf. The address is rebuilt after
+// the DSTIP so it looks just like the Socks 5 ones: The address
+// followed by the port
+func socks4Target(rw io.Reader, buf []byte) (common.ATYPE, []byte, error) {
+	// Request format:
+	//
+	// +----+----+---------+-------+----------+------+
+	// | VN | CD | DSTPORT | DSTIP |  USERID  | NULL |
+	// +----+----+---------+-------+----------+------+
+	// | 1  | 1  |    2    |   4   | Variable |  1   |
+	// +----+----+---------+-------+----------+------+
+	//
+	// For Socks 4a, the DSTIP is set to 0.0.0.x (x is not zero), and
+	// the host name is followed after the USERID:
+	//
+	// +----------+------+
+	// | HOSTNAME | NULL |
+	// +----------+------+
+	// | Variable |  1   |
+	// +----------+------+
+
+	// Read DSTPORT and DSTIP
+	_, rErr := io.ReadFull(rw, buf[:6])
+
+	if rErr != nil {
+		return 0, nil, common.ErrFailedToReadSocks4Request
+	}
+
+	// USERID is read but not used. It carries no password, so it can't
+	// be checked against the auth users. Socks 4 clients are only
+	// allowed when no authentication is required
+	_, userErr := socks4String(rw, buf[6:])
+
+	if userErr != nil {
+		return 0, nil, userErr
+	}
+
+	var addrType common.ATYPE
+	var addrLen int
+
+	if buf[2] == 0 && buf[3] == 0 && buf[4] == 0 && buf[5] != 0 {
+		host, hostErr := socks4String(rw, buf[6:])
+
+		if hostErr != nil {
+			return 0, nil, hostErr
+		}
+
+		if len(host) <= 0 {
+			return 0, nil, common.ErrSocks4EmptyHost
+		}
+
+		addrType = common.Domain
+		addrLen = len(host)
+	} else {
+		addrType = common.IPv4
+		addrLen = copy(buf[6:10], buf[2:6])
+	}
+
+	copy(buf[6+addrLen:8+addrLen], buf[:2])
+
+	return addrType, buf[6 : 8+addrLen], nil
+}
+
+// socks4 handles Socks 4 and 4a requests. The VN and CD has already
+// been read into the buffer
+func (n *negotiator) socks4(rw net.Conn) error {
+	cmd := n.buffer[1]
+
+	addrType, target, targetErr := socks4Target(rw, n.buffer)
+
+	switch targetErr {
+	case nil:
+
+	case common.ErrSocks4EmptyHost:
+		socks4Reject(rw, n.buffer)
+
+		return targetErr
+
+	default:
+		return targetErr
+	}
+
+	if !n.auther.Has(common.NoAuth) {
+		socks4Reject(rw, n.buffer)
+
+		return common.ErrSocks4AuthRequired
+	}
+
+	if cmd != common.Socks4Connect {
+		socks4Reject(rw, n.buffer)
+
+		return common.ErrUnsupportedSocks4Command
+	}
+
+	addr := target[:len(target)-2]
+	port := target[len(target)-2:]
+
+	n.probeConnect(addrType, addr, port, target)
+
+	return n.request(
+		"TCP"+string(target),
+		func(
+			cfg transporter.HandlerConfig,
+			delayBack func(time.Duration),
+		) transporter.Handler {
+			return request.NewConnect4Request(cfg, n.proc, rw, addrType,
+				addr, port, delayBack)
+		})
+}
@@ -0,0 +1,166 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2017 NI Rui <nickriose@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package socks5
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nickrio/coward/roles/socks5/common"
+)
+
+func testSocks4Request(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestSocks4Target(t *testing.T) {
+	longUserID := bytes.Repeat([]byte("u"), common.Socks4MaxStringLength+1)
+
+	tests := []struct {
+		Name     string
+		Request  []byte
+		AddrType common.ATYPE
+		Target   []byte
+		Err      error
+	}{
+		{
+			"Socks 4 without USERID",
+			testSocks4Request(
+				[]byte{0, 80, 93, 184, 216, 34}, []byte{0}),
+			common.IPv4,
+			[]byte{93, 184, 216, 34, 0, 80},
+			nil,
+		},
+		{
+			"Socks 4 with USERID",
+			testSocks4Request(
+				[]byte{0, 80, 93, 184, 216, 34}, []byte("alice\x00")),
+			common.IPv4,
+			[]byte{93, 184, 216, 34, 0, 80},
+			nil,
+		},
+		{
+			"Socks 4 to 0.0.0.0 is not Socks 4a",
+			testSocks4Request(
+				[]byte{0, 80, 0, 0, 0, 0}, []byte("alice\x00")),
+			common.IPv4,
+			[]byte{0, 0, 0, 0, 0, 80},
+			nil,
+		},
+		{
+			"Socks 4a without USERID",
+			testSocks4Request(
+				[]byte{1, 187, 0, 0, 0, 1}, []byte{0},
+				[]byte("example.com\x00")),
+			common.Domain,
+			testSocks4Request([]byte("example.com"), []byte{1, 187}),
+			nil,
+		},
+		{
+			"Socks 4a with USERID",
+			testSocks4Request(
+				[]byte{1, 187, 0, 0, 0, 255}, []byte("bob\x00"),
+				[]byte("example.com\x00")),
+			common.Domain,
+			testSocks4Request([]byte("example.com"), []byte{1, 187}),
+			nil,
+		},
+		{
+			"Socks 4a with empty host name",
+			testSocks4Request(
+				[]byte{1, 187, 0, 0, 0, 1}, []byte("bob\x00"), []byte{0}),
+			0,
+			nil,
+			common.ErrSocks4EmptyHost,
+		},
+		{
+			"Socks 4a without host name",
+			testSocks4Request(
+				[]byte{1, 187, 0, 0, 0, 1}, []byte("bob\x00")),
+			0,
+			nil,
+			common.ErrFailedToReadSocks4Request,
+		},
+		{
+			"USERID too long",
+			testSocks4Request(
+				[]byte{0, 80, 93, 184, 216, 34}, longUserID, []byte{0}),
+			0,
+			nil,
+			common.ErrSocks4StringTooLong,
+		},
+		{
+			"USERID without NULL",
+			testSocks4Request(
+				[]byte{0, 80, 93, 184, 216, 34}, []byte("alice")),
+			0,
+			nil,
+			common.ErrFailedToReadSocks4Request,
+		},
+		{
+			"Incomplete DSTIP",
+			[]byte{0, 80, 93, 184},
+			0,
+			nil,
+			common.ErrFailedToReadSocks4Request,
+		},
+	}
+
+	for _, test := range tests {
+		reader := bytes.NewReader(test.Request)
+
+		addrType, target, targetErr := socks4Target(
+			reader, make([]byte, 4096))
+
+		if targetErr != test.Err {
+			t.Errorf("%s: Expecting error %v, got %v",
+				test.Name, test.Err, targetErr)
+
+			return
+		}
+
+		if targetErr != nil {
+			continue
+		}
+
+		if addrType != test.AddrType {
+			t.Errorf("%s: Expecting address type %d, got %d",
+				test.Name, test.AddrType, addrType)
+
+			return
+		}
+
+		if !bytes.Equal(target, test.Target) {
+			t.Errorf("%s: Expecting target %v, got %v",
+				test.Name, test.Target, target)
+
+			return
+		}
+
+		// The whole request must be consumed, including the USERID
+		if reader.Len() != 0 {
+			t.Errorf("%s: Expecting the request to be fully read, "+
+				"%d bytes left", test.Name, reader.Len())
+
+			return
+		}
+	}
+}
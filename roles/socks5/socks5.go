@@ -237,9 +237,11 @@ func (s *socks5) handle(client net.Conn, log logger.Logger) error {
 		atypeBlock:  &s.atypeBlock,
 		proc:        s.proc,
 		cmd:         common.Command{},
+		resolve:     s.config.RemoteResolve,
 		current:     handshake,
 		buffer:      buf.Client.Buffer[:],
 		steps:       [3]func(net.Conn) error{},
+		probe:       nil,
 		request: func(
 			addr string,
 			builder balancer.DelayFeedingbackRequestBuilder,
@@ -276,6 +278,10 @@ func (s *socks5) handle(client net.Conn, log logger.Logger) error {
 		},
 	}
 
+	if s.config.Probe {
+		n.probe = s.connector.Probe
+	}
+
 	n.Inital()
 
 	for {